@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/kumarabd/policy-machine/pkg/service"
+	"github.com/kumarabd/policy-machine/pkg/store"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes of the check command
+const (
+	checkAllowed = 0
+	checkDenied  = 1
+	checkFailed  = 2
+)
+
+var (
+	checkUser    string
+	checkObject  string
+	checkOp      string
+	checkAsOf    uint64
+	checkPolicy  string
+	checkServer  string
+	checkExplain bool
+	checkJSON    bool
+)
+
+// Add check command
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Decide whether a user may perform an operation on an object",
+	Long: "Decide a request against a policy file, the store in store.dir or a running server.\n" +
+		"Exits with status 0 when allowed, 1 when denied and 2 when no decision could be made.",
+	Example: "  policy-machine check --user alice --object rec_1 --op read --store.dir ./data\n" +
+		"  policy-machine check --user alice --object rec_1 --op read --policy policy.pml --explain\n" +
+		"  policy-machine check --user bob --object rec_2 --op delete --server http://localhost:8000 --json",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		os.Exit(check())
+	},
+}
+
+func init() {
+	checkCmd.Flags().StringVar(&checkUser, "user", "", "User making the request")
+	checkCmd.Flags().StringVar(&checkObject, "object", "", "Object of the request")
+	checkCmd.Flags().StringVar(&checkOp, "op", "", "Operation requested")
+	checkCmd.Flags().Uint64Var(&checkAsOf, "as-of", 0, "Decide against the graph as of this revision")
+	checkCmd.Flags().StringVar(&checkPolicy, "policy", "", "Decide against a pml, json or yaml policy file instead of the store")
+	checkCmd.Flags().StringVar(&checkServer, "server", "", "Base URL of a running server to ask instead of the local store")
+	checkCmd.Flags().BoolVar(&checkExplain, "explain", false, "Print the associations and prohibitions behind the decision")
+	checkCmd.Flags().BoolVar(&checkJSON, "json", false, "Print the decision as JSON")
+}
+
+func check() int {
+	if checkUser == "" || checkObject == "" || checkOp == "" {
+		fmt.Fprintln(os.Stderr, "--user, --object and --op are required")
+		return checkFailed
+	}
+	if checkPolicy != "" && checkServer != "" {
+		fmt.Fprintln(os.Stderr, "--policy and --server cannot be used together")
+		return checkFailed
+	}
+	req := service.CheckRequest{User: checkUser, Object: checkObject, Operation: checkOp, AsOf: checkAsOf}
+
+	exp, err := decide(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to decide: %v\n", err)
+		return checkFailed
+	}
+
+	if checkJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if checkExplain {
+			enc.Encode(exp)
+		} else {
+			enc.Encode(exp.Decision)
+		}
+	} else {
+		printDecision(req, exp)
+	}
+	if exp.Allowed {
+		return checkAllowed
+	}
+	return checkDenied
+}
+
+// decide asks the configured source, explaining the decision when asked to
+func decide(req service.CheckRequest) (*service.Explanation, error) {
+	if checkServer != "" {
+		return decideRemote(req)
+	}
+
+	open := openLocal
+	if checkPolicy != "" {
+		open = func() (*store.Handler, *service.Handler, error) { return openPolicyFile(checkPolicy) }
+	}
+	dHandler, svc, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer dHandler.Close()
+
+	ctx := context.Background()
+	if checkExplain {
+		return svc.Explain(ctx, req)
+	}
+	d, err := svc.Check(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &service.Explanation{Decision: *d}, nil
+}
+
+func decideRemote(req service.CheckRequest) (*service.Explanation, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	path := "/pdp/v1/check"
+	if checkExplain {
+		path = "/pdp/v1/explain"
+	}
+	body, err := remoteCall(checkServer, http.MethodPost, path, payload, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	var exp service.Explanation
+	if err := json.Unmarshal(body, &exp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &exp, nil
+}
+
+func printDecision(req service.CheckRequest, exp *service.Explanation) {
+	fmt.Printf("%s %s %s %s (revision %d)\n", strings.ToUpper(exp.Decision.Decision), req.User, req.Operation, req.Object, exp.Revision)
+	fmt.Printf("reason: %s\n", exp.Reason)
+	if len(exp.Obligations) > 0 {
+		fmt.Println("obligations:")
+		for _, o := range exp.Obligations {
+			b, _ := json.Marshal(o)
+			fmt.Printf("  - %s\n", b)
+		}
+	}
+	if !checkExplain {
+		return
+	}
+
+	fmt.Println("explanation:")
+	if len(exp.PolicyClasses) == 0 {
+		fmt.Println("  no policy class contains the object")
+	}
+	for _, pc := range exp.PolicyClasses {
+		status := "not satisfied"
+		if pc.Satisfied {
+			status = "satisfied"
+		}
+		fmt.Printf("  policy class %s: %s\n", pc.PolicyClass, status)
+		for _, g := range pc.Grants {
+			fmt.Printf("    %s -> %s [%s]\n", g.UA, g.Target, strings.Join(g.Operations, ", "))
+			fmt.Printf("      user:   %s\n", strings.Join(g.UserPath, " > "))
+			fmt.Printf("      object: %s\n", strings.Join(g.ObjectPath, " > "))
+		}
+	}
+	for _, p := range exp.Prohibitions {
+		fmt.Printf("  prohibited by %s\n", p)
+	}
+}
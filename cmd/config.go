@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var configJSON bool
+
+// Add config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration",
+}
+
+var configEnvCmd = &cobra.Command{
+	Use:   "env",
+	Short: "List every configuration key with its environment variable and value",
+	Long: "List every configuration key, the PM_* environment variable overriding it, its type\n" +
+		"and its value after the config file, the environment and flags are applied.\n" +
+		"Secrets are shown as (set).",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		configEnv()
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration",
+	Long: "Check the config file, the environment and flags. Invalid configurations are\n" +
+		"reported with every invalid key and exit with status 1.",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		// The configuration was validated when it was loaded
+		fmt.Println("configuration is valid")
+	},
+}
+
+func init() {
+	configEnvCmd.Flags().BoolVar(&configJSON, "json", false, "Print the keys as JSON")
+	configCmd.AddCommand(configEnvCmd)
+	configCmd.AddCommand(configValidateCmd)
+}
+
+func configEnv() {
+	keys := configHandler.Keys()
+	if configJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(keys)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tENV\tTYPE\tVALUE")
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", k.Path, k.Env, k.Type, k.Value)
+	}
+	w.Flush()
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/kumarabd/policy-machine/pkg/export"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportFormat string
+	exportPC     string
+	exportAsOf   uint64
+	exportOutput string
+	importFormat string
+)
+
+var policyExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the policy graph in canonical pml, json or yaml form",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		policyExport()
+	},
+}
+
+var policyImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Replace the policy graph with the contents of an exported file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		policyImport(args[0])
+	},
+}
+
+func init() {
+	policyExportCmd.Flags().StringVar(&exportFormat, "format", string(export.PML), "Output format: pml, json or yaml")
+	policyExportCmd.Flags().StringVar(&exportPC, "pc", "", "Export only the subgraph rooted at this policy class")
+	policyExportCmd.Flags().Uint64Var(&exportAsOf, "as-of", 0, "Export the graph as of this revision")
+	policyExportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Write to this file instead of stdout")
+	policyImportCmd.Flags().StringVar(&importFormat, "format", "", "Input format; inferred from the file extension when empty")
+	policyImportCmd.Flags().StringVar(&policyAuthor, "author", os.Getenv("USER"), "Author recorded on the revision by the local store; a server records the token's name")
+	policyImportCmd.Flags().StringVar(&policyMessage, "message", "", "Message recorded on the revision")
+	policyCmd.AddCommand(policyExportCmd)
+	policyCmd.AddCommand(policyImportCmd)
+}
+
+func policyExport() {
+	format, err := export.ParseFormat(exportFormat)
+	if err != nil {
+		log.Error().Err(err).Msg("")
+		os.Exit(1)
+	}
+
+	var out []byte
+	if policyServer != "" {
+		query := url.Values{"format": {string(format)}}
+		if exportPC != "" {
+			query.Set("pc", exportPC)
+		}
+		if exportAsOf > 0 {
+			query.Set("as_of", strconv.FormatUint(exportAsOf, 10))
+		}
+		out, err = remoteCall(policyServer, http.MethodGet, "/admin/v1/export?"+query.Encode(), nil, http.StatusOK)
+	} else {
+		out, err = exportLocal(format)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("unable to export policy graph")
+		os.Exit(1)
+	}
+
+	if exportOutput == "" {
+		os.Stdout.Write(out)
+		return
+	}
+	if err := os.WriteFile(exportOutput, out, 0o644); err != nil {
+		log.Error().Err(err).Msg("unable to write export")
+		os.Exit(1)
+	}
+}
+
+func exportLocal(format export.Format) ([]byte, error) {
+	dHandler, svc, err := openLocal()
+	if err != nil {
+		return nil, err
+	}
+	defer dHandler.Close()
+
+	g, _, err := svc.Graph(context.Background(), exportAsOf)
+	if err != nil {
+		return nil, err
+	}
+	if exportPC != "" {
+		if g, err = g.Subgraph(exportPC); err != nil {
+			return nil, err
+		}
+	}
+	return export.Marshal(g, format)
+}
+
+func policyImport(path string) {
+	format, err := export.FormatFromPath(path)
+	if importFormat != "" {
+		format, err = export.ParseFormat(importFormat)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("")
+		os.Exit(1)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to read import file")
+		os.Exit(1)
+	}
+	message := policyMessage
+	if message == "" {
+		message = "import " + path
+	}
+
+	if policyServer != "" {
+		query := url.Values{"format": {string(format)}, "message": {message}}
+		_, err = remoteCall(policyServer, http.MethodPost, "/admin/v1/import?"+query.Encode(), data, http.StatusOK, http.StatusCreated)
+		if err == nil {
+			fmt.Printf("imported %s\n", path)
+		}
+	} else {
+		err = importLocal(path, data, format, message)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		os.Exit(1)
+	}
+}
+
+func importLocal(path string, data []byte, format export.Format, message string) error {
+	g, err := export.Unmarshal(data, format)
+	if err != nil {
+		return err
+	}
+	dHandler, svc, err := openLocal()
+	if err != nil {
+		return err
+	}
+	defer dHandler.Close()
+
+	rev, err := svc.Replace(context.Background(), policyAuthor, message, nil, g)
+	if err != nil {
+		return err
+	}
+	if rev == nil {
+		fmt.Printf("%s: graph already up to date\n", path)
+		return nil
+	}
+	fmt.Printf("imported %s as revision %d (%d changes)\n", path, rev.Number, len(rev.Changes))
+	return nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/kumarabd/policy-machine/pkg/export"
+	"github.com/kumarabd/policy-machine/pkg/gitops"
+	"github.com/kumarabd/policy-machine/pkg/model"
+	"github.com/kumarabd/policy-machine/pkg/pml"
+	"github.com/kumarabd/policy-machine/pkg/server"
+	"github.com/kumarabd/policy-machine/pkg/service"
+	"github.com/spf13/cobra"
+)
+
+var (
+	impactFilter service.ImpactFilter
+	impactJSON   bool
+)
+
+var policyImpactCmd = &cobra.Command{
+	Use:   "impact <file|dir>",
+	Short: "Report whose access a proposed policy change grants and revokes",
+	Long: "Compare the current graph with the graph a proposed change would produce and list the\n" +
+		"(user, object, operation) access gained and lost. A .pml file is applied on top of the\n" +
+		"current graph as policy apply would; a .json or .yaml export replaces the graph as policy\n" +
+		"import would; a directory replaces it as policy sync would. Nothing is committed.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		policyImpact(args[0])
+	},
+}
+
+func init() {
+	policyImpactCmd.Flags().BoolVar(&impactJSON, "json", false, "Print the preview as JSON")
+	for _, c := range []*cobra.Command{policyImpactCmd, policySyncCmd} {
+		c.Flags().StringVar(&impactFilter.User, "user", "", "Only report access of this user")
+		c.Flags().StringVar(&impactFilter.Object, "object", "", "Only report access to this object")
+		c.Flags().StringVar(&impactFilter.Operation, "op", "", "Only report this operation")
+	}
+	policyCmd.AddCommand(policyImpactCmd)
+}
+
+func policyImpact(path string) {
+	var (
+		preview *service.Preview
+		err     error
+	)
+	if policyServer != "" {
+		preview, err = impactRemote(path)
+	} else {
+		preview, err = impactLocal(path)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		os.Exit(1)
+	}
+
+	if impactJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(preview)
+		return
+	}
+	fmt.Printf("revision: %d\n", preview.Base)
+	fmt.Printf("%d changes\n", len(preview.Changes))
+	for _, ch := range preview.Changes {
+		fmt.Printf("  %s\n", ch)
+	}
+	printImpact(preview.Impact)
+}
+
+func impactLocal(path string) (*service.Preview, error) {
+	dHandler, svc, err := openLocal()
+	if err != nil {
+		return nil, err
+	}
+	defer dHandler.Close()
+
+	ctx := context.Background()
+	current, _, err := svc.Graph(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := proposedChanges(current, path)
+	if err != nil {
+		return nil, err
+	}
+	return svc.Preview(ctx, changes, impactFilter)
+}
+
+func impactRemote(path string) (*service.Preview, error) {
+	body, err := remoteCall(policyServer, http.MethodGet, "/admin/v1/graph", nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	var graph server.GraphResponse
+	if err := json.Unmarshal(body, &graph); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	current, err := model.FromDocument(graph.Document)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := proposedChanges(current, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The server previews the changes against its latest graph
+	payload, err := json.Marshal(server.TransactionRequest{Changes: changes})
+	if err != nil {
+		return nil, err
+	}
+	body, err = remoteCall(policyServer, http.MethodPost, "/admin/v1/transactions/preview?"+impactQuery().Encode(), payload, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	var preview service.Preview
+	if err := json.Unmarshal(body, &preview); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &preview, nil
+}
+
+// proposedChanges computes the changes a policy file or directory makes to
+// the current graph
+func proposedChanges(current *model.Graph, path string) ([]model.Change, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		src, err := gitops.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		return model.Diff(current, src.Graph), nil
+	}
+
+	format, err := export.FormatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if format == export.PML {
+		stmts, err := pml.Parse(string(data))
+		if err != nil {
+			return nil, err
+		}
+		return pml.Compile(current, stmts)
+	}
+	g, err := export.Unmarshal(data, format)
+	if err != nil {
+		return nil, err
+	}
+	return model.Diff(current, g), nil
+}
+
+// impactQuery encodes the filter flags as query parameters
+func impactQuery() url.Values {
+	query := url.Values{}
+	for key, value := range map[string]string{
+		"user":      impactFilter.User,
+		"object":    impactFilter.Object,
+		"operation": impactFilter.Operation,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	return query
+}
+
+func printImpact(impact *service.Impact) {
+	if impact == nil {
+		return
+	}
+	s := impact.Summary
+	fmt.Printf("access: %d gained, %d lost, %d users, %d objects\n", s.Gained, s.Lost, s.Users, s.Objects)
+	for _, a := range impact.Gained {
+		fmt.Printf("  + %s\n", formatAccess(a))
+	}
+	for _, a := range impact.Lost {
+		fmt.Printf("  - %s\n", formatAccess(a))
+	}
+}
+
+func formatAccess(a service.Access) string {
+	return strings.Join([]string{a.User, a.Operation, a.Object}, " ")
+}
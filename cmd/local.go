@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/kumarabd/gokit/logger"
+	"github.com/kumarabd/policy-machine/internal/config"
+	"github.com/kumarabd/policy-machine/internal/metrics"
+	"github.com/kumarabd/policy-machine/pkg/export"
+	"github.com/kumarabd/policy-machine/pkg/model"
+	"github.com/kumarabd/policy-machine/pkg/service"
+	"github.com/kumarabd/policy-machine/pkg/store"
+	"github.com/rs/zerolog"
+)
+
+// openLocal opens the configured store directory and a service on top of it
+// for commands that work without a running server. Dynamic separation of
+// duty is decided against the history kept in the directory.
+func openLocal() (*store.Handler, *service.Handler, error) {
+	if configHandler.Store.Dir == "" {
+		return nil, nil, fmt.Errorf("store.dir is not configured; pass --store.dir or use --server")
+	}
+	dHandler, err := store.New(configHandler.Store)
+	if errors.Is(err, store.ErrLocked) {
+		return nil, nil, fmt.Errorf("%w; stop the server or pass --server to go through it", err)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	warnRepairs(dHandler)
+	metricsHandler, err := metrics.New(config.ApplicationName, configHandler.Metrics)
+	if err != nil {
+		dHandler.Close()
+		return nil, nil, err
+	}
+	svc, err := service.New(log, metricsHandler, dHandler, configHandler.Service)
+	if err != nil {
+		dHandler.Close()
+		return nil, nil, err
+	}
+	svc.SetHistory(dHandler)
+	return dHandler, svc, nil
+}
+
+// warnRepairs logs what opening the store repaired after a crash
+func warnRepairs(st *store.Handler) {
+	for _, r := range st.Repairs() {
+		log.Warn().Str("dir", configHandler.Store.Dir).Msg(r)
+	}
+}
+
+// openPolicyFile loads a policy file in pml, json or yaml form into a memory
+// only store and a service on top of it
+func openPolicyFile(path string) (*store.Handler, *service.Handler, error) {
+	format, err := export.FormatFromPath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	g, err := export.Unmarshal(data, format)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return openGraph(g)
+}
+
+// openGraph stores a graph in a memory only store and opens a service on
+// top of it
+func openGraph(g *model.Graph) (*store.Handler, *service.Handler, error) {
+	dHandler, err := store.New(&store.Config{})
+	if err != nil {
+		return nil, nil, err
+	}
+	metricsHandler, err := metrics.New(config.ApplicationName, configHandler.Metrics)
+	if err != nil {
+		dHandler.Close()
+		return nil, nil, err
+	}
+	// The store is thrown away, so loading the graph is not worth logging
+	quiet := &logger.Handler{Logger: zerolog.Nop()}
+	svc, err := service.New(quiet, metricsHandler, dHandler, configHandler.Service)
+	if err != nil {
+		dHandler.Close()
+		return nil, nil, err
+	}
+	if _, err := svc.Replace(context.Background(), "local", "load policy", nil, g); err != nil {
+		dHandler.Close()
+		return nil, nil, err
+	}
+	return dHandler, svc, nil
+}
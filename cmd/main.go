@@ -26,11 +26,13 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Create a root command for handling flags
+	// Create a root command for handling flags. Config parsing runs before
+	// subcommands are added, so their flags are unknown at that point.
 	cmd = &cobra.Command{
-		Use:   config.ApplicationName,
-		Short: config.ApplicationName,
-		Run:   func(cmd *cobra.Command, args []string) {},
+		Use:                config.ApplicationName,
+		Short:              config.ApplicationName,
+		Run:                func(cmd *cobra.Command, args []string) {},
+		FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
 	}
 
 	// Config init and seed
@@ -39,9 +41,17 @@ func main() {
 		log.Error().Err(err).Msg("unable to initialize config")
 		os.Exit(1)
 	}
+	configHandler.Log.ApplyLevel()
+
+	cmd.PersistentFlags().StringVar(&token, "token", "", "Bearer token of --server requests, $"+tokenEnv+" by default")
 
 	// Add commands to root
 	cmd.AddCommand(runCmd)
+	cmd.AddCommand(policyCmd)
+	cmd.AddCommand(auditCmd)
+	cmd.AddCommand(configCmd)
+	cmd.AddCommand(checkCmd)
+	cmd.AddCommand(testCmd)
 
 	// Execute the root command
 	if err := cmd.Execute(); err != nil {
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+	"github.com/kumarabd/policy-machine/pkg/pml"
+	"github.com/spf13/cobra"
+)
+
+var (
+	policyServer  string
+	policyAuthor  string
+	policyMessage string
+)
+
+// Add policy command
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Manage the policy graph",
+}
+
+var policyApplyCmd = &cobra.Command{
+	Use:   "apply <file.pml>",
+	Short: "Apply a PML policy file as a single revision",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		policyApply(args[0])
+	},
+}
+
+func init() {
+	policyCmd.PersistentFlags().StringVar(&policyServer, "server", "", "Base URL of a running server to use instead of the local store")
+	policyApplyCmd.Flags().StringVar(&policyAuthor, "author", os.Getenv("USER"), "Author recorded on the revision by the local store; a server records the token's name")
+	policyApplyCmd.Flags().StringVar(&policyMessage, "message", "", "Message recorded on the revision")
+	policyCmd.AddCommand(policyApplyCmd)
+}
+
+func policyApply(path string) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to read policy file")
+		os.Exit(1)
+	}
+	message := policyMessage
+	if message == "" {
+		message = "apply " + path
+	}
+
+	var rev *model.Revision
+	if policyServer != "" {
+		rev, err = applyRemote(policyServer, message, src)
+	} else {
+		rev, err = applyLocal(policyAuthor, message, src)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		os.Exit(1)
+	}
+	fmt.Printf("applied %s as revision %d (%d changes)\n", path, rev.Number, len(rev.Changes))
+}
+
+func applyLocal(author, message string, src []byte) (*model.Revision, error) {
+	dHandler, svc, err := openLocal()
+	if err != nil {
+		return nil, err
+	}
+	defer dHandler.Close()
+	return pml.Execute(context.Background(), svc, author, message, string(src))
+}
+
+func applyRemote(server, message string, src []byte) (*model.Revision, error) {
+	body, err := remoteCall(server, http.MethodPost, "/admin/v1/policy?message="+url.QueryEscape(message), src, http.StatusCreated)
+	if err != nil {
+		return nil, err
+	}
+	var rev model.Revision
+	if err := json.Unmarshal(body, &rev); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &rev, nil
+}
+
+// tokenEnv names the environment variable holding the bearer token sent
+// to --server when --token is not given
+const tokenEnv = "POLICY_MACHINE_TOKEN"
+
+// token is the bearer token of --server requests
+var token string
+
+func serverToken() string {
+	if token != "" {
+		return token
+	}
+	return os.Getenv(tokenEnv)
+}
+
+// remoteCall sends a request to the admin API of a running server and
+// returns the response body when the status is one of the expected ones.
+// The server records the name of the token as the author of commits.
+func remoteCall(server, method, path string, payload []byte, expected ...int) ([]byte, error) {
+	req, err := http.NewRequest(method, strings.TrimRight(server, "/")+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "text/plain")
+	}
+	if token := serverToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			return body, nil
+		}
+	}
+	var e struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &e) == nil && e.Error != "" {
+		return nil, fmt.Errorf("%s", e.Error)
+	}
+	return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
+}
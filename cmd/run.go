@@ -1,13 +1,22 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
+	"github.com/kumarabd/policy-machine/internal/authz"
 	"github.com/kumarabd/policy-machine/internal/config"
+	"github.com/kumarabd/policy-machine/internal/health"
 	"github.com/kumarabd/policy-machine/internal/metrics"
+	"github.com/kumarabd/policy-machine/internal/tracing"
+	"github.com/kumarabd/policy-machine/pkg/audit"
+	"github.com/kumarabd/policy-machine/pkg/gitops"
 	"github.com/kumarabd/policy-machine/pkg/server"
 	"github.com/kumarabd/policy-machine/pkg/service"
 	"github.com/kumarabd/policy-machine/pkg/store"
@@ -24,37 +33,133 @@ var runCmd = &cobra.Command{
 }
 
 func run() {
-	// Initialize a new PostgreSQL data layer with the configuration from the config handler
-	dHandler, err := store.New()
+	// Initialize the policy store with the configuration from the config handler
+	dHandler, err := store.New(configHandler.Store)
 	if err != nil {
 		log.Error().Err(err).Msg("unable to connect to store")
 		os.Exit(1)
 	}
+	warnRepairs(dHandler)
 	log.Info().Msg("store initialized")
 
 	// Initialize Metrics instance
-	metricsHandler, err := metrics.New(config.ApplicationName)
+	metricsHandler, err := metrics.New(config.ApplicationName, configHandler.Metrics)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
+	// Tracing Initialization
+	shutdownTracing, err := tracing.SetupTracing(configHandler.Tracing, config.ApplicationName, config.ApplicationVersion)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to initialize tracing")
+		os.Exit(1)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error().Err(err).Msg("unable to flush traces")
+		}
+	}()
+
 	// Service Initialization
 	service, err := service.New(log, metricsHandler, dHandler, configHandler.Service)
 	if err != nil {
 		log.Error().Err(err).Msg("")
 		os.Exit(1)
 	}
+	// Dynamic separation of duty is enforced against the operations the
+	// store records as allowed
+	service.SetHistory(dHandler)
 	log.Info().Msg("service initialized")
 
+	// GitOps sync reconciles the store to a policy directory in the background
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var background sync.WaitGroup
+	var syncer *gitops.Syncer
+	if configHandler.GitOps.Enabled {
+		syncer = gitops.New(log, configHandler.GitOps, service)
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			syncer.Run(ctx)
+		}()
+		log.Info().Str("dir", configHandler.GitOps.Dir).Msg("gitops sync started")
+	}
+
+	// Authorization for the protected API, through OPA or embedded Rego
+	evaluator, err := authz.New(log, configHandler.Authz)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to initialize authz")
+		os.Exit(1)
+	}
+
+	// OPA bundles of the policies and the graph for OPA instances to poll
+	var bundler *authz.Bundler
+	if configHandler.Authz.Bundle.Enabled {
+		bundler = authz.NewBundler(configHandler.Authz)
+		metricsHandler.RegisterCache("opa_bundle", bundler.CacheStats)
+	}
+	if client, ok := evaluator.(*authz.Client); ok {
+		metricsHandler.RegisterBreaker("opa", client.BreakerState)
+		if configHandler.Authz.Cache.Enabled {
+			metricsHandler.RegisterCache("opa_decisions", client.CacheStats)
+		}
+	}
+	obligations := authz.NewObligations(log, configHandler.Authz.Obligations)
+
+	// Readiness checks of everything needed to make decisions
+	checks := health.New(0)
+	checks.Register("store", service.PingStore)
+	checks.Register("policy", service.PolicyLoaded)
+	if pinger, ok := evaluator.(authz.Pinger); ok {
+		checks.Register("opa", pinger.Ping)
+	}
+	if client, ok := evaluator.(*authz.Client); ok {
+		checks.Register("opa_breaker", client.BreakerReady)
+	}
+
+	// Audit log of OPA and enforced decisions and of every committed revision
+	auditLog, err := audit.New(configHandler.Audit)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to open audit log")
+		os.Exit(1)
+	}
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		if err := auditLog.Follow(ctx, service); err != nil {
+			log.Error().Err(err).Msg("audit of revisions stopped")
+		}
+	}()
+
 	// Server Initialization
-	srv, err := server.New(log, configHandler.Server, service)
+	srv, err := server.New(log, metricsHandler, configHandler.Server, service, syncer, evaluator, bundler, auditLog, checks, obligations.Options()...)
 	if err != nil {
 		log.Error().Err(err).Msg("")
 		os.Exit(1)
 	}
 	log.Info().Msg("server listenining")
 
+	// Settings that are safe to change are applied on SIGHUP or when the
+	// config file changes
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		err := configHandler.Watch(ctx, log, func(next *config.Config) {
+			next.Log.ApplyLevel()
+			if client, ok := evaluator.(*authz.Client); ok {
+				client.SetCacheTTL(next.Authz.Cache.TTL)
+			}
+			obligations.Configure(next.Authz.Obligations)
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("unable to watch configuration")
+		}
+	}()
+
 	log.Info().Msg("application starting")
 	ch := make(chan struct{})
 	srv.Run(ch)
@@ -72,5 +177,28 @@ func run() {
 		}
 	}
 	log.Info().Msg("received stop. gracefully shutting down...")
-	close(ch)
+
+	// Report unready and drain the requests in flight, which may still
+	// commit revisions and append to the audit log
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), srv.ShutdownGrace())
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("unable to drain server")
+	}
+	cancelShutdown()
+
+	// Stop the background sync and revision audit before closing what they write to
+	cancel()
+	background.Wait()
+	if err := auditLog.Close(); err != nil {
+		log.Error().Err(err).Msg("unable to close audit log")
+	}
+	if closer, ok := evaluator.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Error().Err(err).Msg("unable to close authz evaluator")
+		}
+	}
+	if err := dHandler.Close(); err != nil {
+		log.Error().Err(err).Msg("unable to close store")
+	}
+	log.Info().Msg("shutdown complete")
 }
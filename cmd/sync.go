@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/kumarabd/policy-machine/pkg/gitops"
+	"github.com/spf13/cobra"
+)
+
+var syncDryRun bool
+
+var policySyncCmd = &cobra.Command{
+	Use:   "sync [dir]",
+	Short: "Reconcile the policy store to a directory of policy files once",
+	Long: "Reconcile the policy store to a directory of .pml, .json and .yaml policy files.\n" +
+		"Without --server the local store is synced from dir, or gitops.dir when dir is omitted.\n" +
+		"With --server the running server syncs from its own configured directory.",
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := configHandler.GitOps.Dir
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		policySync(dir)
+	},
+}
+
+func init() {
+	policySyncCmd.Flags().BoolVar(&syncDryRun, "dry-run", false, "Print the plan without applying it")
+	policySyncCmd.Flags().StringVar(&policyAuthor, "author", os.Getenv("USER"), "Author recorded on the revision by the local store; a server records the token's name")
+	policyCmd.AddCommand(policySyncCmd)
+}
+
+func policySync(dir string) {
+	var (
+		plan *gitops.Plan
+		rev  uint64
+		err  error
+	)
+	if policyServer != "" {
+		plan, rev, err = syncRemote()
+	} else {
+		plan, rev, err = syncLocal(dir)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sync failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	printPlan(plan)
+	switch {
+	case syncDryRun:
+		fmt.Println("dry run, nothing applied")
+	case rev > 0:
+		fmt.Printf("applied as revision %d\n", rev)
+	default:
+		fmt.Println("store already matches source")
+	}
+}
+
+func syncLocal(dir string) (*gitops.Plan, uint64, error) {
+	if dir == "" {
+		return nil, 0, fmt.Errorf("no policy directory given and gitops.dir is not configured")
+	}
+	dHandler, svc, err := openLocal()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer dHandler.Close()
+
+	cfg := *configHandler.GitOps
+	cfg.Dir = dir
+	if policyAuthor != "" {
+		cfg.Author = policyAuthor
+	}
+	syncer := gitops.New(log, &cfg, svc)
+
+	if syncDryRun {
+		plan, err := syncer.Preview(context.Background(), impactFilter)
+		return plan, 0, err
+	}
+	plan, r, err := syncer.Sync(context.Background())
+	if err != nil || r == nil {
+		return plan, 0, err
+	}
+	return plan, r.Number, nil
+}
+
+func syncRemote() (*gitops.Plan, uint64, error) {
+	path := "/admin/v1/sync"
+	if syncDryRun {
+		query := impactQuery()
+		query.Set("dry_run", "true")
+		path += "?" + query.Encode()
+	}
+	body, err := remoteCall(policyServer, http.MethodPost, path, nil, http.StatusOK)
+	if err != nil {
+		return nil, 0, err
+	}
+	var resp struct {
+		Plan     *gitops.Plan `json:"plan"`
+		Revision *struct {
+			Number uint64 `json:"revision"`
+		} `json:"revision"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if resp.Revision == nil {
+		return resp.Plan, 0, nil
+	}
+	return resp.Plan, resp.Revision.Number, nil
+}
+
+func printPlan(plan *gitops.Plan) {
+	fmt.Printf("source:   %s (%s)\n", plan.Dir, plan.SourceHash)
+	if plan.Commit != "" {
+		fmt.Printf("commit:   %s\n", plan.Commit)
+	}
+	fmt.Printf("revision: %d\n", plan.Base)
+	if plan.Drift {
+		fmt.Println("drift:    store was changed outside of sync")
+	}
+	fmt.Printf("%d changes\n", len(plan.Changes))
+	for _, ch := range plan.Changes {
+		fmt.Printf("  %s\n", ch)
+	}
+	printImpact(plan.Impact)
+}
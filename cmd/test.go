@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+	"github.com/kumarabd/policy-machine/pkg/policytest"
+	"github.com/spf13/cobra"
+)
+
+var (
+	testJUnit    string
+	testCoverage bool
+	testVerbose  bool
+)
+
+// Add test command
+var testCmd = &cobra.Command{
+	Use:   "test [path...]",
+	Short: "Run the policy test suites under the given files and directories",
+	Long: "Run every *_test.yaml suite under the given files and directories, the current\n" +
+		"directory by default. Each suite names a policy file or directory and lists requests\n" +
+		"with the decision expected for them. Exits with status 1 when any test fails.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+		policyTest(args)
+	},
+}
+
+func init() {
+	testCmd.Flags().StringVar(&testJUnit, "junit", "", "Write a JUnit XML report to this file")
+	testCmd.Flags().BoolVar(&testCoverage, "coverage", false, "Report the associations and prohibitions exercised by the tests")
+	testCmd.Flags().BoolVarP(&testVerbose, "verbose", "v", false, "Print passing tests too")
+}
+
+func policyTest(paths []string) {
+	files, err := policytest.Discover(paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to find test suites: %v\n", err)
+		os.Exit(1)
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "no test suites found")
+		os.Exit(1)
+	}
+
+	report := policytest.Run(context.Background(), files, func(g *model.Graph) (policytest.Policy, func(), error) {
+		dHandler, svc, err := openGraph(g)
+		if err != nil {
+			return nil, nil, err
+		}
+		return svc, func() { dHandler.Close() }, nil
+	})
+
+	for _, s := range report.Suites {
+		printSuite(s)
+	}
+	if testCoverage {
+		for _, c := range report.Coverage {
+			printCoverage(c)
+		}
+	}
+	if testJUnit != "" {
+		if err := writeJUnit(report); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to write JUnit report: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
+
+func printSuite(s *policytest.SuiteResult) {
+	if s.Err != nil {
+		fmt.Printf("FAIL  %s  %v\n", s.Path, s.Err)
+		return
+	}
+	for _, c := range s.Cases {
+		switch {
+		case c.Err != nil:
+			fmt.Printf("--- ERROR: %s\n    %v\n", c.Name, c.Err)
+		case c.Failure != "":
+			fmt.Printf("--- FAIL: %s\n    %s\n", c.Name, c.Failure)
+		case testVerbose:
+			fmt.Printf("--- PASS: %s\n", c.Name)
+		}
+	}
+	if failed := s.Failures(); failed > 0 {
+		fmt.Printf("FAIL  %s  %d of %d failed  (%.3fs)\n", s.Path, failed, len(s.Cases), s.Duration.Seconds())
+	} else {
+		fmt.Printf("ok    %s  %d tests  (%.3fs)\n", s.Path, len(s.Cases), s.Duration.Seconds())
+	}
+}
+
+func printCoverage(c *policytest.Coverage) {
+	fmt.Printf("coverage of %s\n", strings.Join(c.Policies, ", "))
+	fmt.Printf("  associations  %d/%d  (%.1f%%)\n", policytest.Covered(c.Associations), len(c.Associations), policytest.Percent(c.Associations))
+	fmt.Printf("  prohibitions  %d/%d  (%.1f%%)\n", policytest.Covered(c.Prohibitions), len(c.Prohibitions), policytest.Percent(c.Prohibitions))
+	for _, item := range c.Associations {
+		if item.Hits == 0 {
+			fmt.Printf("  not exercised: association %s\n", item.Name)
+		}
+	}
+	for _, item := range c.Prohibitions {
+		if item.Hits == 0 {
+			fmt.Printf("  not exercised: prohibition %s\n", item.Name)
+		}
+	}
+}
+
+func writeJUnit(report *policytest.Report) error {
+	f, err := os.Create(testJUnit)
+	if err != nil {
+		return err
+	}
+	if err := policytest.WriteJUnit(f, report); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,72 @@
+package authz
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kumarabd/policy-machine/internal/metrics"
+)
+
+const (
+	breakerThreshold = 5
+	breakerCooldown  = 10 * time.Second
+)
+
+// ErrBreakerOpen is returned without contacting OPA while it is failing
+var ErrBreakerOpen = errors.New("OPA is unavailable, circuit breaker is open")
+
+// breaker opens after consecutive failures and lets a single trial request
+// through once the cooldown has passed
+type breaker struct {
+	mu       sync.Mutex
+	state    int
+	failures int
+	openedAt time.Time
+}
+
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case metrics.BreakerOpen:
+		if time.Since(b.openedAt) < breakerCooldown {
+			return ErrBreakerOpen
+		}
+		b.state = metrics.BreakerHalfOpen
+		return nil
+	case metrics.BreakerHalfOpen:
+		// A trial request is already in flight
+		return ErrBreakerOpen
+	}
+	return nil
+}
+
+func (b *breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.state, b.failures = metrics.BreakerClosed, 0
+		return
+	}
+	b.failures++
+	if b.state == metrics.BreakerHalfOpen || b.failures >= breakerThreshold {
+		b.state, b.openedAt = metrics.BreakerOpen, time.Now()
+	}
+}
+
+// abort gives up a trial request without judging OPA, so the next request
+// becomes the trial
+func (b *breaker) abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == metrics.BreakerHalfOpen {
+		b.state = metrics.BreakerOpen
+	}
+}
+
+func (b *breaker) current() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -0,0 +1,178 @@
+package authz
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/kumarabd/policy-machine/internal/metrics"
+	"github.com/kumarabd/policy-machine/pkg/model"
+	"github.com/open-policy-agent/opa/v1/bundle"
+)
+
+// GraphRoot is where the NGAC graph is mounted in the bundle, read by
+// policies as data.ngac
+const GraphRoot = "ngac"
+
+// BundleConfig configures the OPA bundle served to OPA instances polling
+// this server
+type BundleConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Policies is the directory of .rego files in the bundle, defaulting to
+	// the directory used in embedded mode
+	Policies string `json:"policies,omitempty" yaml:"policies,omitempty"`
+	// SigningKey is a PEM private key, a path to one, or an HMAC secret.
+	// Bundles are unsigned when it is empty.
+	SigningKey string `json:"signing_key,omitempty" yaml:"signing_key,omitempty"`
+	// SigningAlg is the JWT algorithm of the signature, RS256 by default
+	SigningAlg string `json:"signing_alg,omitempty" yaml:"signing_alg,omitempty"`
+	// KeyID names the verification key OPA should use
+	KeyID string `json:"key_id,omitempty" yaml:"key_id,omitempty"`
+}
+
+// Bundler builds OPA bundles of the Rego policies and the NGAC graph. The
+// last bundle is reused while neither the graph revision nor the policy
+// files change.
+type Bundler struct {
+	dir     string
+	signing *bundle.SigningConfig
+	keyID   string
+
+	mu     sync.Mutex
+	etag   string
+	built  []byte
+	hits   uint64
+	misses uint64
+}
+
+// NewBundler creates a bundler for the configuration
+func NewBundler(cfg *Config) *Bundler {
+	dir := cfg.Bundle.Policies
+	if dir == "" {
+		dir = cfg.Policies
+	}
+	if dir == "" {
+		dir = "opa/policies"
+	}
+	b := &Bundler{dir: dir, keyID: cfg.Bundle.KeyID}
+	if cfg.Bundle.SigningKey != "" {
+		b.signing = bundle.NewSigningConfig(cfg.Bundle.SigningKey, cfg.Bundle.SigningAlg, "")
+	}
+	return b
+}
+
+// ETag returns the entity tag of the bundle for the graph revision without
+// building it
+func (b *Bundler) ETag(revision uint64) (string, error) {
+	_, sum, err := b.policies()
+	if err != nil {
+		return "", err
+	}
+	return etag(revision, sum), nil
+}
+
+// Build returns the gzipped bundle tarball and its entity tag. The manifest
+// revision is the entity tag without quotes.
+func (b *Bundler) Build(g *model.Graph, revision uint64) ([]byte, string, error) {
+	modules, sum, err := b.policies()
+	if err != nil {
+		return nil, "", err
+	}
+	tag := etag(revision, sum)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if tag == b.etag {
+		b.hits++
+		return b.built, b.etag, nil
+	}
+	b.misses++
+
+	// The graph goes through JSON so the data hashes the way OPA reads it back
+	raw, err := json.Marshal(map[string]interface{}{
+		GraphRoot: graphData{Document: g.Document(), Revision: revision},
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, "", err
+	}
+
+	roots := []string{"authz", GraphRoot}
+	bdl := bundle.Bundle{
+		Manifest: bundle.Manifest{Revision: tag[1 : len(tag)-1], Roots: &roots},
+		Data:     data,
+		Modules:  modules,
+	}
+	bdl.Manifest.Init()
+	if b.signing != nil {
+		if err := bdl.GenerateSignature(b.signing, b.keyID, false); err != nil {
+			return nil, "", fmt.Errorf("failed to sign bundle: %w", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := bundle.NewWriter(&buf).Write(bdl); err != nil {
+		return nil, "", fmt.Errorf("failed to write bundle: %w", err)
+	}
+	b.etag, b.built = tag, buf.Bytes()
+	return b.built, b.etag, nil
+}
+
+// CacheStats reports reuse of the last built bundle for metrics
+func (b *Bundler) CacheStats() metrics.CacheStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := metrics.CacheStats{Hits: b.hits, Misses: b.misses}
+	if b.built != nil {
+		s.Entries = 1
+	}
+	return s
+}
+
+// graphData is the document mounted at data.ngac
+type graphData struct {
+	model.Document
+	Revision uint64 `json:"revision"`
+}
+
+// policies reads the bundled modules in name order with a digest of their
+// names and contents
+func (b *Bundler) policies() ([]bundle.ModuleFile, string, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, "", err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && isPolicy(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	modules := make([]bundle.ModuleFile, 0, len(names))
+	for _, name := range names {
+		src, err := os.ReadFile(filepath.Join(b.dir, name))
+		if err != nil {
+			return nil, "", err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", name, len(src))
+		h.Write(src)
+		modules = append(modules, bundle.ModuleFile{URL: name, Path: name, Raw: src})
+	}
+	return modules, hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+func etag(revision uint64, sum string) string {
+	return fmt.Sprintf(`"%d-%s"`, revision, sum)
+}
@@ -0,0 +1,113 @@
+package authz
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+	"github.com/open-policy-agent/opa/v1/bundle"
+)
+
+func TestBundler(t *testing.T) {
+	dir := t.TempDir()
+	writePolicy(t, dir, "authz.rego", adminPolicy)
+	writePolicy(t, dir, "authz_test.rego", "package authz\n")
+	g := model.NewGraph()
+	if err := g.Apply(model.Change{Op: model.OpCreateNode, Node: &model.Node{Name: "pc", Type: model.PolicyClass}}); err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewBundler(&Config{Policies: dir})
+	data, tag, err := b.Build(g, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := b.ETag(3); tag != want {
+		t.Fatalf("Build() tag %s, ETag() %s", tag, want)
+	}
+
+	bdl, err := bundle.NewReader(bytes.NewReader(data)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bdl.Manifest.Revision != tag[1:len(tag)-1] {
+		t.Errorf("manifest revision %q, want the tag %s", bdl.Manifest.Revision, tag)
+	}
+	if len(bdl.Modules) != 1 || bdl.Modules[0].Path != "/authz.rego" {
+		t.Errorf("modules %v, want authz.rego only", bdl.Modules)
+	}
+	ngac, _ := bdl.Data[GraphRoot].(map[string]interface{})
+	if rev := fmt.Sprint(ngac["revision"]); rev != "3" {
+		t.Errorf("data.ngac.revision = %v, want 3", ngac["revision"])
+	}
+
+	// The same revision and policies reuse the built bundle
+	if again, _, err := b.Build(g, 3); err != nil || !bytes.Equal(again, data) {
+		t.Fatalf("rebuild = %v", err)
+	}
+	if s := b.CacheStats(); s.Hits != 1 || s.Misses != 1 {
+		t.Errorf("cache stats %+v, want one hit and one miss", s)
+	}
+
+	tests := []struct {
+		name   string
+		change func()
+		rev    uint64
+		same   bool
+	}{
+		{name: "unchanged", rev: 3, same: true},
+		{name: "test policy changed", change: func() { writePolicy(t, dir, "authz_test.rego", "package authz\n\n# edited\n") }, rev: 3, same: true},
+		{name: "new revision", rev: 4},
+		{name: "policy changed", change: func() { writePolicy(t, dir, "authz.rego", everyonePolicy) }, rev: 3},
+		{name: "policy added", change: func() { writePolicy(t, dir, "extra.rego", "package extra\n") }, rev: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := b.ETag(3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.change != nil {
+				tt.change()
+			}
+			after, err := b.ETag(tt.rev)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (after == before) != tt.same {
+				t.Fatalf("ETag %s after %s, want same %v", after, before, tt.same)
+			}
+		})
+	}
+}
+
+func TestBundlerSigning(t *testing.T) {
+	dir := t.TempDir()
+	writePolicy(t, dir, "authz.rego", adminPolicy)
+	b := NewBundler(&Config{Policies: dir, Bundle: BundleConfig{SigningKey: "secret", SigningAlg: "HS256", KeyID: "pm"}})
+	data, _, err := b.Build(model.NewGraph(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{name: "verified", key: "secret"},
+		{name: "wrong key", key: "other", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := map[string]*bundle.KeyConfig{"pm": {Key: tt.key, Algorithm: "HS256"}}
+			_, err := bundle.NewReader(bytes.NewReader(data)).
+				WithBundleVerificationConfig(bundle.NewVerificationConfig(keys, "pm", "", nil)).
+				Read()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package authz
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	cache "github.com/kumarabd/policy-machine/internal/caching"
+	"github.com/kumarabd/policy-machine/internal/metrics"
+)
+
+// decisionCache holds OPA decisions by input for a TTL that can be changed
+// while running. Decisions may be stale by up to the TTL after the policies
+// or the graph change.
+type decisionCache struct {
+	store  *cache.Service
+	ttl    atomic.Int64
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func newDecisionCache(cfg *cache.Config) *decisionCache {
+	store := cache.NewService(cfg)
+	if store == nil {
+		return nil
+	}
+	c := &decisionCache{store: store}
+	c.ttl.Store(int64(cfg.TTL))
+	return c
+}
+
+func (c *decisionCache) get(key string) (*DecisionResult, bool) {
+	v, ok := c.store.Get(key)
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	result := *v.(*DecisionResult)
+	return &result, true
+}
+
+func (c *decisionCache) set(key string, result *DecisionResult) {
+	stored := *result
+	c.store.Set(key, &stored, time.Duration(c.ttl.Load())*time.Second)
+}
+
+// cacheKey digests the request, which is what OPA evaluates
+func cacheKey(req DecisionRequest) (string, bool) {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), true
+}
+
+// EnableCache caches decisions for the configured TTL. It does nothing when
+// the cache is disabled.
+func (c *Client) EnableCache(cfg *cache.Config) {
+	c.cache = newDecisionCache(cfg)
+}
+
+// SetCacheTTL changes the TTL of cached decisions, dropping those cached
+// under the previous one
+func (c *Client) SetCacheTTL(seconds int) {
+	if c.cache == nil || c.cache.ttl.Swap(int64(seconds)) == int64(seconds) {
+		return
+	}
+	c.cache.store.Flush()
+}
+
+// CacheStats reports the decision cache for metrics
+func (c *Client) CacheStats() metrics.CacheStats {
+	if c.cache == nil {
+		return metrics.CacheStats{}
+	}
+	return metrics.CacheStats{
+		Entries: c.cache.store.Len(),
+		Hits:    c.cache.hits.Load(),
+		Misses:  c.cache.misses.Load(),
+	}
+}
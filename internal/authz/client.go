@@ -7,12 +7,21 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/kumarabd/policy-machine/internal/metrics"
+	"github.com/kumarabd/policy-machine/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Client handles communication with OPA
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	breaker    breaker
+	cache      *decisionCache
 }
 
 // NewClient creates a new OPA client
@@ -66,15 +75,79 @@ type DecisionResponse struct {
 	Result DecisionResult `json:"result"`
 }
 
-// DecisionResult contains the authorization decision
+// DecisionResult contains the authorization decision. Policies report it
+// either as a "decision" of "allow" or as a boolean "allow".
 type DecisionResult struct {
 	Decision    string                   `json:"decision"`
+	Allow       bool                     `json:"allow"`
+	Reason      string                   `json:"reason"`
 	Obligations []map[string]interface{} `json:"obligations"`
 	Conditions  map[string]bool          `json:"conditions"`
 }
 
-// Evaluate makes an authorization decision via OPA
+// Evaluate makes an authorization decision via OPA. After repeated failures
+// requests fail fast with ErrBreakerOpen until OPA answers a trial request.
 func (c *Client) Evaluate(ctx context.Context, req DecisionRequest) (*DecisionResult, error) {
+	key, cacheable := "", false
+	if c.cache != nil {
+		key, cacheable = cacheKey(req)
+	}
+	if cacheable {
+		if result, ok := c.cache.get(key); ok {
+			return result, nil
+		}
+	}
+
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+	result, err := c.evaluate(ctx, req)
+	// A cancelled caller says nothing about OPA's health
+	if ctx.Err() != nil {
+		c.breaker.abort()
+	} else {
+		c.breaker.record(err)
+	}
+	if err == nil && cacheable {
+		c.cache.set(key, result)
+	}
+	return result, err
+}
+
+// Ping checks OPA is up and has its policies and bundles loaded
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/health?bundles", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("OPA is unreachable: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("OPA health returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// BreakerReady fails while the circuit breaker is open
+func (c *Client) BreakerReady(ctx context.Context) error {
+	if c.breaker.current() == metrics.BreakerOpen {
+		return ErrBreakerOpen
+	}
+	return nil
+}
+
+// BreakerState reports the circuit breaker state for metrics
+func (c *Client) BreakerState() int {
+	return c.breaker.current()
+}
+
+func (c *Client) evaluate(ctx context.Context, req DecisionRequest) (*DecisionResult, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "POST /v1/data/authz/result", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
 	reqBody, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
@@ -86,15 +159,21 @@ func (c *Client) Evaluate(ctx context.Context, req DecisionRequest) (*DecisionRe
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
+	// OPA joins the caller's trace through the propagated context
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
 
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
+		tracing.Error(span, err)
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("OPA returned status %d", resp.StatusCode)
+		err := fmt.Errorf("OPA returned status %d", resp.StatusCode)
+		tracing.Error(span, err)
+		return nil, err
 	}
 
 	var decisionResp DecisionResponse
@@ -0,0 +1,179 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/kumarabd/gokit/logger"
+	"github.com/open-policy-agent/opa/v1/rego"
+)
+
+// resultQuery is the decision evaluated in embedded mode, the same document
+// remote mode reads from /v1/data/authz/result
+const resultQuery = "data.authz.result"
+
+// reloadDelay coalesces the burst of events an editor save produces
+const reloadDelay = 100 * time.Millisecond
+
+// Embedded evaluates the Rego policies in process with a query prepared
+// once per load
+type Embedded struct {
+	dir string
+	log *logger.Handler
+
+	mu    sync.RWMutex
+	query rego.PreparedEvalQuery
+	// loadErr is the error of the last reload, nil once one succeeds
+	loadErr error
+
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+// NewEmbedded compiles the .rego files in dir, skipping _test.rego files.
+// With watch set the policies are recompiled whenever they change; a policy
+// that fails to compile leaves the previous one in place.
+func NewEmbedded(l *logger.Handler, dir string, watch bool) (*Embedded, error) {
+	e := &Embedded{dir: dir, log: l, done: make(chan struct{})}
+	if err := e.load(context.Background()); err != nil {
+		return nil, err
+	}
+	if !watch {
+		close(e.done)
+		return e, nil
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Add(dir); err != nil {
+		w.Close()
+		return nil, err
+	}
+	e.watcher = w
+	go e.watch()
+	return e, nil
+}
+
+// Evaluate implements Evaluator
+func (e *Embedded) Evaluate(ctx context.Context, req DecisionRequest) (*DecisionResult, error) {
+	e.mu.RLock()
+	query := e.query
+	e.mu.RUnlock()
+
+	rs, err := query.Eval(ctx, rego.EvalInput(req.Input))
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate policy: %w", err)
+	}
+	// An undefined result denies, as an empty OPA response does in remote mode
+	if len(rs) == 0 || len(rs[0].Expressions) == 0 {
+		return &DecisionResult{}, nil
+	}
+
+	// The result document is decoded the same way as an OPA response
+	b, err := json.Marshal(rs[0].Expressions[0].Value)
+	if err != nil {
+		return nil, err
+	}
+	var result DecisionResult
+	if err := json.Unmarshal(b, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode result: %w", err)
+	}
+	return &result, nil
+}
+
+// Ping implements Pinger. It reports the error of the last reload: requests
+// are still decided with the previous policies, but not the ones on disk.
+func (e *Embedded) Ping(ctx context.Context) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.loadErr
+}
+
+// Close stops watching the policy directory
+func (e *Embedded) Close() error {
+	if e.watcher == nil {
+		return nil
+	}
+	err := e.watcher.Close()
+	<-e.done
+	return err
+}
+
+// load compiles the policies, recording the error for Ping when they fail
+func (e *Embedded) load(ctx context.Context) error {
+	query, err := e.compile(ctx)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.loadErr = err
+	if err == nil {
+		e.query = query
+	}
+	return err
+}
+
+func (e *Embedded) compile(ctx context.Context) (rego.PreparedEvalQuery, error) {
+	entries, err := os.ReadDir(e.dir)
+	if err != nil {
+		return rego.PreparedEvalQuery{}, err
+	}
+	opts := []func(*rego.Rego){rego.Query(resultQuery)}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !isPolicy(name) {
+			continue
+		}
+		src, err := os.ReadFile(filepath.Join(e.dir, name))
+		if err != nil {
+			return rego.PreparedEvalQuery{}, err
+		}
+		opts = append(opts, rego.Module(name, string(src)))
+	}
+
+	query, err := rego.New(opts...).PrepareForEval(ctx)
+	if err != nil {
+		return rego.PreparedEvalQuery{}, fmt.Errorf("failed to compile policies in %s: %w", e.dir, err)
+	}
+	return query, nil
+}
+
+func (e *Embedded) watch() {
+	defer close(e.done)
+
+	var reload <-chan time.Time
+	for {
+		select {
+		case ev, ok := <-e.watcher.Events:
+			if !ok {
+				return
+			}
+			if isPolicy(filepath.Base(ev.Name)) {
+				reload = time.After(reloadDelay)
+			}
+		case err, ok := <-e.watcher.Errors:
+			if !ok {
+				return
+			}
+			e.log.Error().Err(err).Msg("policy watch failed")
+		case <-reload:
+			reload = nil
+			if err := e.load(context.Background()); err != nil {
+				e.log.Error().Err(err).Msg("policy reload failed, keeping previous policies")
+				continue
+			}
+			e.log.Info().Str("dir", e.dir).Msg("policies reloaded")
+		}
+	}
+}
+
+func isPolicy(name string) bool {
+	return strings.HasSuffix(name, ".rego") && !strings.HasSuffix(name, "_test.rego")
+}
@@ -0,0 +1,148 @@
+package authz
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kumarabd/gokit/logger"
+	"github.com/rs/zerolog"
+)
+
+const (
+	adminPolicy = `package authz
+
+default result := {"decision": "deny", "reason": "not an admin"}
+
+result := {"decision": "allow", "obligations": [{"type": "log"}]} if input.subject.attributes.role == "admin"
+`
+	everyonePolicy = `package authz
+
+result := {"decision": "allow", "reason": "everyone"}
+`
+)
+
+func writePolicy(t *testing.T, dir, name, src string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func roleInput(role string) DecisionRequest {
+	return DecisionRequest{Input: DecisionInput{
+		Subject: Subject{ID: "alice", Attributes: map[string]interface{}{"role": role}},
+		Action:  "read",
+	}}
+}
+
+func TestEmbedded(t *testing.T) {
+	tests := []struct {
+		name     string
+		policies map[string]string
+		role     string
+		want     DecisionResult
+		wantErr  bool
+	}{
+		{
+			name:     "allowed",
+			policies: map[string]string{"authz.rego": adminPolicy},
+			role:     "admin",
+			want:     DecisionResult{Decision: "allow", Obligations: []map[string]interface{}{{"type": "log"}}},
+		},
+		{
+			name:     "denied by default",
+			policies: map[string]string{"authz.rego": adminPolicy},
+			role:     "user",
+			want:     DecisionResult{Decision: "deny", Reason: "not an admin"},
+		},
+		{
+			name:     "undefined result",
+			policies: map[string]string{"other.rego": "package other\n\nallow := true\n"},
+			role:     "admin",
+		},
+		{
+			name:     "test files skipped",
+			policies: map[string]string{"authz.rego": adminPolicy, "authz_test.rego": "not rego"},
+			role:     "user",
+			want:     DecisionResult{Decision: "deny", Reason: "not an admin"},
+		},
+		{
+			name:     "invalid policy",
+			policies: map[string]string{"authz.rego": "package authz\n\nresult := {"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, src := range tt.policies {
+				writePolicy(t, dir, name, src)
+			}
+			e, err := NewEmbedded(&logger.Handler{Logger: zerolog.Nop()}, dir, false)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("NewEmbedded() succeeded")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer e.Close()
+
+			got, err := e.Evaluate(context.Background(), roleInput(tt.role))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Decision != tt.want.Decision || got.Reason != tt.want.Reason || len(got.Obligations) != len(tt.want.Obligations) {
+				t.Fatalf("Evaluate() = %+v, want %+v", *got, tt.want)
+			}
+			if err := e.Ping(context.Background()); err != nil {
+				t.Fatalf("Ping() = %v", err)
+			}
+		})
+	}
+}
+
+func TestEmbeddedReload(t *testing.T) {
+	dir := t.TempDir()
+	writePolicy(t, dir, "authz.rego", adminPolicy)
+	e, err := NewEmbedded(&logger.Handler{Logger: zerolog.Nop()}, dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	ctx := context.Background()
+
+	// eventually waits for the reload to give a user the decision
+	eventually := func(decision string, ping bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			got, err := e.Evaluate(ctx, roleInput("user"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pingErr := e.Ping(ctx)
+			if got.Decision == decision && (pingErr == nil) == ping {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("decision %q and Ping() = %v, want %q and ok %v", got.Decision, pingErr, decision, ping)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	eventually("deny", true)
+	writePolicy(t, dir, "authz.rego", everyonePolicy)
+	eventually("allow", true)
+	// A policy that fails to compile keeps the previous one and fails Ping
+	writePolicy(t, dir, "authz.rego", "package authz\n\nresult := {")
+	eventually("allow", false)
+	writePolicy(t, dir, "authz.rego", adminPolicy)
+	eventually("deny", true)
+}
@@ -0,0 +1,128 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/kumarabd/gokit/logger"
+	cache "github.com/kumarabd/policy-machine/internal/caching"
+)
+
+const (
+	// ModeRemote queries an OPA server over HTTP
+	ModeRemote = "remote"
+	// ModeEmbedded evaluates the Rego policies in process
+	ModeEmbedded = "embedded"
+)
+
+// Config selects how authorization decisions are evaluated
+type Config struct {
+	Mode string `json:"mode" yaml:"mode"`
+	// URL of the OPA server in remote mode
+	URL string `json:"url" yaml:"url"`
+	// Policies is the directory of .rego files loaded in embedded mode
+	Policies string `json:"policies" yaml:"policies"`
+	// Watch reloads the policies when they change, like opa run --watch
+	Watch bool `json:"watch" yaml:"watch"`
+	// Bundle serves the policies and the graph to OPA as a bundle
+	Bundle BundleConfig `json:"bundle" yaml:"bundle"`
+	// Cache keeps OPA decisions in remote mode. Its TTL can be reloaded.
+	Cache cache.Config `json:"cache" yaml:"cache"`
+	// Obligations configures how the protected API fulfils obligations and
+	// can be reloaded
+	Obligations ObligationsConfig `json:"obligations" yaml:"obligations"`
+}
+
+// Validate reports every invalid setting
+func (c *Config) Validate() error {
+	var errs []error
+	switch c.Mode {
+	case "", ModeRemote:
+		if c.URL != "" {
+			if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs = append(errs, fmt.Errorf("url: %q is not an http(s) URL", c.URL))
+			}
+		}
+	case ModeEmbedded:
+		if c.Policies != "" {
+			if err := isDir(c.Policies); err != nil {
+				errs = append(errs, fmt.Errorf("policies: %w", err))
+			}
+		}
+	default:
+		errs = append(errs, fmt.Errorf("mode: unknown mode %q, expected %s or %s", c.Mode, ModeRemote, ModeEmbedded))
+	}
+
+	if c.Bundle.Enabled && c.Bundle.Policies != "" {
+		if err := isDir(c.Bundle.Policies); err != nil {
+			errs = append(errs, fmt.Errorf("bundle.policies: %w", err))
+		}
+	}
+	switch c.Bundle.SigningAlg {
+	case "", "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "HS256", "HS384", "HS512":
+	default:
+		errs = append(errs, fmt.Errorf("bundle.signing_alg: unsupported algorithm %q", c.Bundle.SigningAlg))
+	}
+	if c.Bundle.SigningKey == "" && (c.Bundle.SigningAlg != "" || c.Bundle.KeyID != "") {
+		errs = append(errs, errors.New("bundle.signing_key: required to sign bundles"))
+	}
+
+	if c.Cache.Enabled && c.Cache.TTL <= 0 {
+		errs = append(errs, errors.New("cache.ttl: must be positive when the cache is enabled"))
+	}
+	if c.Cache.Cleanup < 0 {
+		errs = append(errs, errors.New("cache.cleanup: must not be negative"))
+	}
+	for _, t := range c.Obligations.Deny {
+		if t == "" {
+			errs = append(errs, errors.New("obligations.deny: empty obligation type"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func isDir(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+	return nil
+}
+
+// Evaluator makes an authorization decision for a request
+type Evaluator interface {
+	Evaluate(ctx context.Context, req DecisionRequest) (*DecisionResult, error)
+}
+
+// Pinger is implemented by evaluators that can report whether they are able
+// to make decisions
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// New creates the evaluator selected by the configuration
+func New(l *logger.Handler, cfg *Config) (Evaluator, error) {
+	switch cfg.Mode {
+	case "", ModeRemote:
+		url := cfg.URL
+		if url == "" {
+			url = "http://localhost:8181"
+		}
+		client := NewClient(url)
+		client.EnableCache(&cfg.Cache)
+		return client, nil
+	case ModeEmbedded:
+		dir := cfg.Policies
+		if dir == "" {
+			dir = "opa/policies"
+		}
+		return NewEmbedded(l, dir, cfg.Watch)
+	}
+	return nil, fmt.Errorf("unknown authz mode %q", cfg.Mode)
+}
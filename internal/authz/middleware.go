@@ -1,89 +1,128 @@
 package authz
 
 import (
-	"net/http"
+	"context"
+	"fmt"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kumarabd/policy-machine/internal/metrics"
+	"github.com/kumarabd/policy-machine/internal/tracing"
+	"github.com/kumarabd/policy-machine/pkg/pep"
 )
 
 // Middleware creates a Gin middleware for authorization
-func Middleware(client *Client) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Extract subject from request (in real app, this would come from JWT/auth)
-		subject := Subject{
-			ID: c.GetHeader("X-User-ID"),
-			Attributes: map[string]interface{}{
-				"role":            c.GetHeader("X-User-Role"),
-				"user_id":         c.GetHeader("X-User-ID"),
-				"clearance_level": 2, // Default clearance level
-			},
-		}
+func Middleware(e Evaluator, m *metrics.Handler, opts ...pep.Option) gin.HandlerFunc {
+	return pep.New(NewPDP(e, m), opts...).Gin()
+}
 
-		// Extract resource from request
-		resource := Resource{
-			ID: c.Param("resource_id"),
-			Attributes: map[string]interface{}{
-				"owner_id": c.GetHeader("X-Resource-Owner"),
-				"type":     c.GetHeader("X-Resource-Type"),
-			},
-		}
+// NewPDP adapts an Evaluator to the pep.PDP interface. Decisions are
+// recorded in the metrics when m is set.
+func NewPDP(e Evaluator, m *metrics.Handler) pep.PDP {
+	return &pdp{evaluator: e, metric: m}
+}
 
-		// Determine action from HTTP method
-		action := getActionFromMethod(c.Request.Method)
-
-		// Get policies from PIP (Policy Information Point)
-		permissions, prohibitions, conditions := getPolicies(subject, resource, action)
-
-		// Create decision request
-		req := DecisionRequest{
-			Input: DecisionInput{
-				Subject:      subject,
-				Resource:     resource,
-				Action:       action,
-				Permissions:  permissions,
-				Prohibitions: prohibitions,
-				Conditions:   conditions,
-			},
-		}
+type pdp struct {
+	evaluator Evaluator
+	metric    *metrics.Handler
+}
 
-		// Evaluate with OPA
-		decision, err := client.Evaluate(c.Request.Context(), req)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "authorization failed"})
-			c.Abort()
-			return
-		}
+// Decide implements pep.PDP by evaluating the request with OPA
+func (p *pdp) Decide(ctx context.Context, req pep.Request) (*pep.Decision, error) {
+	ctx, span := tracing.Start(ctx, "authz.decide",
+		tracing.AttrPDP.String("opa"),
+		tracing.AttrSubject.String(req.Subject),
+		tracing.AttrResource.String(req.Resource),
+		tracing.AttrAction.String(req.Action),
+	)
+	defer span.End()
+	input := newInput(req)
+
+	// Get policies from PIP (Policy Information Point)
+	_, pip := tracing.Start(ctx, "authz.pip")
+	input.Permissions, input.Prohibitions, input.Conditions = getPolicies(input.Subject, input.Resource, input.Action)
+	pip.End()
+
+	start := time.Now()
+	decision, err := p.evaluator.Evaluate(ctx, DecisionRequest{Input: input})
+	if err != nil {
+		tracing.Error(span, err)
+		return nil, err
+	}
+	elapsed := time.Since(start)
 
-		// Check decision
-		if decision.Decision != "allow" {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error":       "access denied",
-				"decision":    decision.Decision,
-				"obligations": decision.Obligations,
-			})
-			c.Abort()
-			return
-		}
+	out := &pep.Decision{
+		Allowed: decision.Decision == "allow" || decision.Allow,
+		Reason:  decision.Reason,
+	}
+	switch {
+	case out.Reason != "":
+	case decision.Decision != "":
+		out.Reason = fmt.Sprintf("opa decision %q", decision.Decision)
+	default:
+		out.Reason = "policy returned no decision"
+	}
+	for _, o := range decision.Obligations {
+		out.Obligations = append(out.Obligations, pep.Obligation(o))
+	}
+	tracing.Decision(span, decisionLabel(out.Allowed), out.Reason)
+	if p.metric != nil {
+		p.observe(input, out, elapsed)
+	}
+	return out, nil
+}
 
-		// Store obligations in context for later processing
-		c.Set("obligations", decision.Obligations)
-		c.Set("conditions", decision.Conditions)
+func (p *pdp) observe(input DecisionInput, d *pep.Decision, elapsed time.Duration) {
+	// the type comes from a request header, so only configured kinds are recorded
+	kind, _ := input.Resource.Attributes["type"].(string)
+	kind = p.metric.ResourceKind(kind, "unknown")
+	var obligations []string
+	for _, o := range d.Obligations {
+		obligations = append(obligations, o.Type())
+	}
+	p.metric.ObserveDecision(metrics.Decision{
+		PDP:          "opa",
+		Decision:     decisionLabel(d.Allowed),
+		Reason:       d.Reason,
+		Action:       input.Action,
+		ResourceKind: kind,
+		Obligations:  obligations,
+		Duration:     elapsed,
+	})
+}
 
-		c.Next()
+func decisionLabel(allowed bool) string {
+	if allowed {
+		return "allow"
 	}
+	return "deny"
 }
 
-// getActionFromMethod maps HTTP methods to actions
-func getActionFromMethod(method string) string {
-	switch method {
-	case "GET":
-		return "read"
-	case "POST", "PUT", "PATCH":
-		return "write"
-	case "DELETE":
-		return "delete"
-	default:
-		return "read"
+// newInput builds the subject and resource of a decision from the request
+func newInput(req pep.Request) DecisionInput {
+	// Extract subject from request (in real app, this would come from JWT/auth)
+	subject := Subject{
+		ID: req.Subject,
+		Attributes: map[string]interface{}{
+			"role":            req.Attributes.Get("X-User-Role"),
+			"user_id":         req.Subject,
+			"clearance_level": 2, // Default clearance level
+		},
+	}
+
+	// Extract resource from request
+	resource := Resource{
+		ID: req.Resource,
+		Attributes: map[string]interface{}{
+			"owner_id": req.Attributes.Get("X-Resource-Owner"),
+			"type":     req.Attributes.Get("X-Resource-Type"),
+		},
+	}
+
+	return DecisionInput{
+		Subject:  subject,
+		Resource: resource,
+		Action:   req.Action,
 	}
 }
 
@@ -0,0 +1,60 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync/atomic"
+
+	"github.com/kumarabd/gokit/logger"
+	"github.com/kumarabd/policy-machine/pkg/pep"
+)
+
+// ObligationsConfig configures the obligations fulfilled by the service
+type ObligationsConfig struct {
+	// Log writes "log" obligations to the service log
+	Log bool `json:"log,omitempty" yaml:"log,omitempty"`
+	// Deny lists obligation types that cannot be fulfilled, so requests
+	// allowed with them are denied
+	Deny []string `json:"deny,omitempty" yaml:"deny,omitempty"`
+}
+
+// Obligations fulfils the obligations of allowed decisions under a
+// configuration that can be replaced while running
+type Obligations struct {
+	log    *logger.Handler
+	config atomic.Pointer[ObligationsConfig]
+}
+
+// NewObligations creates the obligation handler
+func NewObligations(l *logger.Handler, cfg ObligationsConfig) *Obligations {
+	o := &Obligations{log: l}
+	o.Configure(cfg)
+	return o
+}
+
+// Configure replaces the configuration for the decisions that follow
+func (o *Obligations) Configure(cfg ObligationsConfig) {
+	cfg.Deny = slices.Clone(cfg.Deny)
+	o.config.Store(&cfg)
+}
+
+// Options registers the handler with an enforcer
+func (o *Obligations) Options() []pep.Option {
+	return []pep.Option{pep.WithDefaultObligationHandler(o.fulfil)}
+}
+
+func (o *Obligations) fulfil(_ context.Context, ob pep.Obligation) error {
+	cfg := o.config.Load()
+	if slices.Contains(cfg.Deny, ob.Type()) {
+		return fmt.Errorf("obligations of type %q are denied", ob.Type())
+	}
+	if ob.Type() == "log" && cfg.Log {
+		message, _ := ob["message"].(string)
+		if message == "" {
+			message = "log obligation"
+		}
+		o.log.Info().Interface("obligation", map[string]interface{}(ob)).Msg(message)
+	}
+	return nil
+}
@@ -50,6 +50,15 @@ func (s *Service) Delete(key string) {
 	s.cache.Delete(key)
 }
 
+// Len returns the number of cached items, including expired ones not yet
+// cleaned up
+func (s *Service) Len() int {
+	if s.cache == nil {
+		return 0
+	}
+	return s.cache.ItemCount()
+}
+
 func (s *Service) Flush() {
 	if s.cache == nil {
 		return
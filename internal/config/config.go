@@ -1,11 +1,24 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
 	config_pkg "github.com/kumarabd/gokit/config"
+	"github.com/kumarabd/policy-machine/internal/authz"
 	"github.com/kumarabd/policy-machine/internal/metrics"
+	"github.com/kumarabd/policy-machine/internal/tracing"
+	"github.com/kumarabd/policy-machine/pkg/audit"
+	"github.com/kumarabd/policy-machine/pkg/gitops"
 	"github.com/kumarabd/policy-machine/pkg/server"
 	"github.com/kumarabd/policy-machine/pkg/service"
+	"github.com/kumarabd/policy-machine/pkg/store"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -14,20 +27,161 @@ var (
 )
 
 type Config struct {
+	Log     *LogConfig       `json:"log,omitempty" yaml:"log,omitempty"`
 	Server  *server.Config   `json:"server,omitempty" yaml:"server,omitempty"`
 	Service *service.Config  `json:"service" yaml:"service"`
+	Store   *store.Config    `json:"store,omitempty" yaml:"store,omitempty"`
+	GitOps  *gitops.Config   `json:"gitops,omitempty" yaml:"gitops,omitempty"`
+	Authz   *authz.Config    `json:"authz,omitempty" yaml:"authz,omitempty"`
+	Audit   *audit.Config    `json:"audit,omitempty" yaml:"audit,omitempty"`
 	Metrics *metrics.Options `json:"metrics,omitempty" yaml:"metrics,omitempty"`
+	Tracing *tracing.Config  `json:"tracing,omitempty" yaml:"tracing,omitempty"`
+
+	cmd  *cobra.Command
+	file string
 }
 
-// New creates a new config instance
-func New(cmd *cobra.Command) (*Config, error) {
-	// Create default config object
-	configObject := &Config{
-		Server:  &server.Config{},
+// defaults returns the configuration before the file, the environment and
+// flags are applied
+func defaults() *Config {
+	return &Config{
+		Log: &LogConfig{},
+		Server: &server.Config{
+			HTTP: server.HTTPServerConfig{Port: "8000"},
+		},
 		Service: &service.Config{},
+		Store:   &store.Config{},
+		GitOps:  &gitops.Config{},
+		Authz:   &authz.Config{},
+		Audit:   &audit.Config{},
 		Metrics: &metrics.Options{},
+		Tracing: &tracing.Config{},
+	}
+}
+
+// New creates a new config instance from the config file, PM_* environment
+// variables and flags, in increasing precedence, and validates it
+func New(cmd *cobra.Command) (*Config, error) {
+	finalConfig, err := config_pkg.NewWithCommand(cmd, defaults())
+	if err != nil {
+		return nil, err
 	}
+	configObject := finalConfig.(*Config)
+	configObject.cmd = cmd
+	configObject.file, _ = cmd.PersistentFlags().GetString("config")
 
-	finalConfig, err := config_pkg.NewWithCommand(cmd, configObject)
-	return finalConfig.(*Config), err
+	// The file is decoded again to reject keys that are not in the schema
+	if configObject.file != "" {
+		if err := decodeFile(configObject.file, defaults()); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyEnv(cmd, configObject, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := configObject.Validate(); err != nil {
+		return nil, err
+	}
+	return configObject, nil
+}
+
+// File returns the path of the config file, empty when there is none
+func (c *Config) File() string {
+	return c.file
+}
+
+// Reload loads the configuration again from the same file, environment and
+// flags. The current configuration is left unchanged.
+func (c *Config) Reload() (*Config, error) {
+	next := defaults()
+	next.cmd, next.file = c.cmd, c.file
+	if next.file != "" {
+		if err := decodeFile(next.file, next); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyEnv(next.cmd, next, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if next.cmd != nil {
+		if err := applyFlags(next.cmd, next); err != nil {
+			return nil, err
+		}
+	}
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// decodeFile decodes the config file, failing on unknown keys
+func decodeFile(path string, c *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting by its key
+func (c *Config) Validate() error {
+	var errs []error
+	for _, section := range []struct {
+		key string
+		v   interface{ Validate() error }
+	}{
+		{"log", c.Log},
+		{"server", c.Server},
+		{"store", c.Store},
+		{"gitops", c.GitOps},
+		{"authz", c.Authz},
+		{"audit", c.Audit},
+		{"metrics", c.Metrics},
+		{"tracing", c.Tracing},
+	} {
+		if err := section.v.Validate(); err != nil {
+			errs = append(errs, prefix(section.key, err)...)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errs: errs}
+}
+
+// ValidationError lists every invalid setting
+type ValidationError struct {
+	Errs []error
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		lines[i] = err.Error()
+	}
+	return "invalid configuration: " + strings.Join(lines, "; ")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return e.Errs
+}
+
+// prefix qualifies the keys of a section's errors with the section
+func prefix(key string, err error) []error {
+	var errs []error
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	} else {
+		errs = []error{err}
+	}
+	prefixed := make([]error, len(errs))
+	for i, err := range errs {
+		prefixed[i] = fmt.Errorf("%s.%w", key, err)
+	}
+	return prefixed
 }
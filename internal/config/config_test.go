@@ -0,0 +1,135 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// load runs New as the command line args would, over the config file src
+func load(t *testing.T, src string, args ...string) (*Config, error) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Args
+	t.Cleanup(func() { os.Args = saved })
+	os.Args = append([]string{"pm", "--config", file}, args...)
+	return New(&cobra.Command{Use: "pm", Run: func(*cobra.Command, []string) {}})
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		args []string
+		env  map[string]string
+		// want is the HTTP port, or errs the keys rejected
+		want string
+		errs []string
+	}{
+		{name: "defaults", want: "8000"},
+		{name: "file", src: "server:\n  http:\n    port: \"9000\"\n", want: "9000"},
+		{
+			name: "environment over file",
+			src:  "server:\n  http:\n    port: \"9000\"\n",
+			env:  map[string]string{"PM_SERVER_HTTP_PORT": "9100"},
+			want: "9100",
+		},
+		{
+			name: "flag over environment",
+			src:  "server:\n  http:\n    port: \"9000\"\n",
+			args: []string{"--server.http.port", "9200"},
+			env:  map[string]string{"PM_SERVER_HTTP_PORT": "9100"},
+			want: "9200",
+		},
+		{name: "unknown key", src: "server:\n  htp:\n    port: \"9000\"\n", errs: []string{"field htp not found"}},
+		{name: "invalid environment", env: map[string]string{"PM_GITOPS_INTERVAL": "often"}, errs: []string{"PM_GITOPS_INTERVAL"}},
+		{
+			name: "every invalid setting",
+			src:  "log:\n  level: loud\ngitops:\n  enabled: true\n",
+			env:  map[string]string{"PM_GITOPS_INTERVAL": "-1"},
+			errs: []string{"log.level", "gitops.dir", "gitops.interval"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			c, err := load(t, tt.src, tt.args...)
+			if tt.errs != nil {
+				if err == nil {
+					t.Fatal("New() succeeded")
+				}
+				for _, key := range tt.errs {
+					if !strings.Contains(err.Error(), key) {
+						t.Errorf("error %q does not name %s", err, key)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Server.HTTP.Port != tt.want {
+				t.Errorf("port %s, want %s", c.Server.HTTP.Port, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	c := defaults()
+	c.Log.Level = "loud"
+	c.GitOps.Enabled = true
+	err := c.Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Errs) != 2 {
+		t.Fatalf("Validate() = %v, want both settings", err)
+	}
+	if !strings.HasPrefix(verr.Errs[0].Error(), "log.level:") || !strings.HasPrefix(verr.Errs[1].Error(), "gitops.dir:") {
+		t.Errorf("errors %v are not keyed by setting", verr.Errs)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"PM_GITOPS_ENABLED":  "true",
+		"PM_GITOPS_INTERVAL": "30",
+		"PM_GITOPS_DIR":      " ./policies ",
+	}
+	c := defaults()
+	if err := applyEnv(nil, c, func(k string) (string, bool) { v, ok := env[k]; return v, ok }); err != nil {
+		t.Fatal(err)
+	}
+	if !c.GitOps.Enabled || c.GitOps.Interval != 30 || c.GitOps.Dir != "./policies" {
+		t.Errorf("gitops %+v", *c.GitOps)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	c := defaults()
+	c.Server.HTTP.Port = "9000"
+	c.Authz.Bundle.SigningKey = "s3cret"
+	keys := map[string]Key{}
+	for _, k := range c.Keys() {
+		keys[k.Path] = k
+	}
+	if k := keys["server.http.port"]; k.Env != "PM_SERVER_HTTP_PORT" || k.Value != "9000" {
+		t.Errorf("server.http.port = %+v", k)
+	}
+	if k := keys["authz.bundle.signing_key"]; k.Value != "(set)" {
+		t.Errorf("authz.bundle.signing_key = %+v, want it masked", k)
+	}
+	for path, k := range keys {
+		if strings.Contains(k.Value, "s3cret") {
+			t.Errorf("%s shows the secret", path)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// EnvPrefix starts the environment variable of every configuration key
+const EnvPrefix = "PM_"
+
+// Key is a configuration key with the environment variable overriding it
+type Key struct {
+	Path  string `json:"key"`
+	Env   string `json:"env"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// EnvName returns the environment variable of a key, such as
+// PM_SERVER_HTTP_PORT for server.http.port
+func EnvName(path string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+// Keys lists every configuration key in order with its current value.
+// Secrets are not shown.
+func (c *Config) Keys() []Key {
+	return c.keys(true)
+}
+
+func (c *Config) keys(mask bool) []Key {
+	var keys []Key
+	walk(c, "", func(path string, v reflect.Value) {
+		value := format(v)
+		if mask && secret(path) && value != "" {
+			value = "(set)"
+		}
+		keys = append(keys, Key{Path: path, Env: EnvName(path), Type: typeName(v), Value: value})
+	})
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Path < keys[j].Path })
+	return keys
+}
+
+// applyEnv sets every key whose environment variable is set, unless its
+// flag was given. Flags win over the environment, which wins over the file.
+func applyEnv(cmd *cobra.Command, c *Config, lookup func(string) (string, bool)) error {
+	var errs []string
+	walk(c, "", func(path string, v reflect.Value) {
+		if cmd != nil {
+			if f := cmd.PersistentFlags().Lookup(path); f != nil && f.Changed {
+				return
+			}
+		}
+		raw, ok := lookup(EnvName(path))
+		if !ok {
+			return
+		}
+		if err := set(v, raw); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", EnvName(path), err))
+		}
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid environment overrides: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// applyFlags sets every key whose flag was given, as gokit does on load,
+// including --from-env pairs
+func applyFlags(cmd *cobra.Command, c *Config) error {
+	fromEnv := map[string]string{}
+	if f := cmd.PersistentFlags().Lookup("from-env"); f != nil && f.Changed {
+		pairs, _ := cmd.PersistentFlags().GetStringSlice("from-env")
+		for _, pair := range pairs {
+			parts := strings.SplitN(pair, "::", 2)
+			if len(parts) == 2 {
+				fromEnv[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+			}
+		}
+	}
+
+	var errs []string
+	walk(c, "", func(path string, v reflect.Value) {
+		if env, ok := fromEnv[path]; ok {
+			if raw, ok := os.LookupEnv(env); ok && raw != "" {
+				if err := set(v, raw); err != nil {
+					errs = append(errs, fmt.Sprintf("%s: %v", path, err))
+				}
+			}
+			return
+		}
+		f := cmd.PersistentFlags().Lookup(path)
+		if f == nil || !f.Changed {
+			return
+		}
+		raw := f.Value.String()
+		if v.Kind() == reflect.String && strings.HasPrefix(raw, "$") {
+			// Flags may name an environment variable, as gokit resolves on load
+			if env := os.ExpandEnv(raw); env != "" {
+				raw = env
+			}
+		}
+		if err := set(v, raw); err != nil {
+			errs = append(errs, fmt.Sprintf("--%s: %v", path, err))
+		}
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid flags: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// walk calls fn for every leaf key of the configuration, by its YAML path
+func walk(v interface{}, prefix string, fn func(path string, v reflect.Value)) {
+	walkValue(reflect.ValueOf(v), prefix, fn)
+}
+
+func walkValue(v reflect.Value, prefix string, fn func(path string, v reflect.Value)) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+
+		fv := v.Field(i)
+		switch {
+		case fv.Kind() == reflect.Struct,
+			fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct:
+			walkValue(fv, path, fn)
+		default:
+			fn(path, fv)
+		}
+	}
+}
+
+// set parses raw into a leaf. Lists are comma separated and maps are
+// comma separated key=value pairs.
+func set(v reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", raw)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", raw)
+		}
+		v.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported list type %s", v.Type())
+		}
+		out := reflect.MakeSlice(v.Type(), 0, 0)
+		for _, s := range strings.Split(raw, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = reflect.Append(out, reflect.ValueOf(s))
+			}
+		}
+		v.Set(out)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map type %s", v.Type())
+		}
+		out := reflect.MakeMap(v.Type())
+		for _, pair := range strings.Split(raw, ",") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			k, val, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("%q is not a key=value pair", pair)
+			}
+			out.SetMapIndex(reflect.ValueOf(strings.TrimSpace(k)), reflect.ValueOf(strings.TrimSpace(val)))
+		}
+		v.Set(out)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func format(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Slice:
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(parts, ",")
+	case reflect.Map:
+		keys := v.MapKeys()
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			parts = append(parts, fmt.Sprintf("%v=%v", k.Interface(), v.MapIndex(k).Interface()))
+		}
+		sort.Strings(parts)
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func typeName(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Slice:
+		return "list"
+	case reflect.Map:
+		return "map"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "int"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	}
+	return v.Kind().String()
+}
+
+// secret reports keys whose values are never printed
+func secret(path string) bool {
+	name := path[strings.LastIndex(path, ".")+1:]
+	return strings.HasSuffix(name, "_key") || strings.Contains(name, "secret") || strings.Contains(name, "password") || strings.Contains(name, "token")
+}
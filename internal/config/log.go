@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog"
+)
+
+// LogConfig configures the service log
+type LogConfig struct {
+	// Level is debug, info, warn or error, info by default. It is applied
+	// again on reload.
+	Level string `json:"level,omitempty" yaml:"level,omitempty"`
+}
+
+// Validate reports every invalid setting
+func (c *LogConfig) Validate() error {
+	if _, err := c.level(); err != nil {
+		return fmt.Errorf("level: %w", err)
+	}
+	return nil
+}
+
+// ApplyLevel sets the level of every logger
+func (c *LogConfig) ApplyLevel() {
+	if level, err := c.level(); err == nil {
+		zerolog.SetGlobalLevel(level)
+	}
+}
+
+func (c *LogConfig) level() (zerolog.Level, error) {
+	switch c.Level {
+	case "debug":
+		return zerolog.DebugLevel, nil
+	case "", "info":
+		return zerolog.InfoLevel, nil
+	case "warn":
+		return zerolog.WarnLevel, nil
+	case "error":
+		return zerolog.ErrorLevel, nil
+	}
+	return zerolog.NoLevel, fmt.Errorf("unknown level %q, expected debug, info, warn or error", c.Level)
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/kumarabd/gokit/logger"
+	"gopkg.in/yaml.v3"
+)
+
+// Reloadable are the keys applied without a restart. Changes to any other
+// key are reported and take effect on the next start.
+var Reloadable = []string{
+	"log.level",
+	"authz.cache.ttl",
+	"authz.obligations.log",
+	"authz.obligations.deny",
+}
+
+// reloadDebounce coalesces the events of a single write to the file
+const reloadDebounce = 200 * time.Millisecond
+
+// Watch reloads the configuration on SIGHUP and whenever the config file
+// changes until ctx is done. When a reloadable key changed, apply is called
+// with the new configuration. Invalid configurations are logged and
+// ignored.
+func (c *Config) Watch(ctx context.Context, l *logger.Handler, apply func(*Config)) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	// The directory is watched as editors and Kubernetes replace the file
+	var events chan fsnotify.Event
+	if c.file != "" {
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+		if err := w.Add(filepath.Dir(c.file)); err != nil {
+			return err
+		}
+		events = w.Events
+	}
+
+	applied := c.clone()
+	timer := time.NewTimer(0)
+	<-timer.C
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+			applied = applied.reload(l, apply)
+		case e := <-events:
+			if filepath.Base(e.Name) == filepath.Base(c.file) || filepath.Base(e.Name) == "..data" {
+				timer.Reset(reloadDebounce)
+			}
+		case <-timer.C:
+			applied = applied.reload(l, apply)
+		}
+	}
+}
+
+// reload loads the configuration and applies the reloadable keys that
+// changed, returning what is now in effect
+func (c *Config) reload(l *logger.Handler, apply func(*Config)) *Config {
+	next, err := c.Reload()
+	if err != nil {
+		l.Error().Err(err).Msg("configuration not reloaded")
+		return c
+	}
+
+	var reloaded, restart []string
+	for _, key := range changed(c, next) {
+		if slices.Contains(Reloadable, key) {
+			reloaded = append(reloaded, key)
+		} else {
+			restart = append(restart, key)
+		}
+	}
+	if len(restart) > 0 {
+		l.Warn().Strs("keys", restart).Msg("configuration changes require a restart")
+	}
+	if len(reloaded) == 0 {
+		return c
+	}
+	apply(next)
+	l.Info().Strs("keys", reloaded).Msg("configuration reloaded")
+
+	// Keys requiring a restart keep being reported until then
+	applied := c.clone()
+	applied.Log = next.Log
+	applied.Authz.Cache.TTL = next.Authz.Cache.TTL
+	applied.Authz.Obligations = next.Authz.Obligations
+	return applied
+}
+
+// changed lists the keys whose values differ
+func changed(a, b *Config) []string {
+	values := map[string]string{}
+	for _, k := range a.keys(false) {
+		values[k.Path] = k.Value
+	}
+	var keys []string
+	for _, k := range b.keys(false) {
+		if values[k.Path] != k.Value {
+			keys = append(keys, k.Path)
+		}
+	}
+	return keys
+}
+
+// clone copies the configuration so it can be changed independently
+func (c *Config) clone() *Config {
+	out := defaults()
+	if data, err := yaml.Marshal(c); err == nil {
+		_ = yaml.Unmarshal(data, out)
+	}
+	out.cmd, out.file = c.cmd, c.file
+	return out
+}
@@ -0,0 +1,125 @@
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+
+	defaultTimeout = 2 * time.Second
+)
+
+// Check reports whether a component is ready, returning nil when it is
+type Check func(ctx context.Context) error
+
+// Component is the result of one check
+type Component struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the readiness of every registered component
+type Report struct {
+	Status     string               `json:"status"`
+	Components map[string]Component `json:"components"`
+}
+
+// Registry holds the readiness checks of the components the service needs
+// to make decisions
+type Registry struct {
+	timeout time.Duration
+
+	mu       sync.RWMutex
+	checks   map[string]Check
+	draining atomic.Bool
+}
+
+// New creates a registry whose checks each get timeout to complete, two
+// seconds when zero
+func New(timeout time.Duration) *Registry {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Registry{timeout: timeout, checks: map[string]Check{}}
+}
+
+// Register adds or replaces the check of a component
+func (r *Registry) Register(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = check
+}
+
+// Drain marks the service as shutting down. Every later report fails so
+// load balancers stop sending new requests.
+func (r *Registry) Drain() {
+	r.draining.Store(true)
+}
+
+// Check runs every check concurrently. The service is ready only when all
+// of them pass.
+func (r *Registry) Check(ctx context.Context) *Report {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.checks))
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = r.checks[name]
+	}
+	r.mu.RUnlock()
+
+	results := make([]Component, len(names))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = r.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := &Report{Status: StatusOK, Components: make(map[string]Component, len(names)+1)}
+	if r.draining.Load() {
+		report.Status = StatusFail
+		report.Components["shutdown"] = Component{Status: StatusFail, Error: "server is shutting down", Duration: "0s"}
+	}
+	for i, name := range names {
+		report.Components[name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, check Check) Component {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	c := Component{Status: StatusOK, Duration: time.Since(start).Round(time.Microsecond).String()}
+	if err != nil {
+		c.Status, c.Error = StatusFail, err.Error()
+	}
+	return c
+}
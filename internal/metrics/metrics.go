@@ -1,26 +1,228 @@
 package metrics
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Breaker states reported by the circuit_breaker_state gauge
+const (
+	BreakerClosed   = 0
+	BreakerHalfOpen = 1
+	BreakerOpen     = 2
 )
 
 type Handler struct {
 	RequestsReceived *prometheus.CounterVec
+	RequestDuration  *prometheus.HistogramVec
+
+	// Decisions counts decisions by pdp, decision, reason, action and resource kind
+	Decisions *prometheus.CounterVec
+	// DecisionDuration is the time a PDP takes to evaluate a decision
+	DecisionDuration *prometheus.HistogramVec
+	// Obligations counts the obligations attached to allowed decisions by type
+	Obligations *prometheus.CounterVec
+
+	registry *prometheus.Registry
+	stats    *statsCollector
+	kinds    map[string]struct{}
 }
 
 type Options struct {
-	// Additional labels necessary
-}
-
-func New(name string) (*Handler, error) {
-	return &Handler{
-		RequestsReceived: promauto.NewCounterVec(prometheus.CounterOpts{
-			Name:        "http_requests_received",
-			ConstLabels: map[string]string{
-				// Add labels
-			},
-			Help: "The total number of http requests received",
-		}, []string{"status"}),
-	}, nil
+	// Namespace prefixes every metric name
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	// ConstLabels are added to every metric, e.g. the environment or region
+	ConstLabels map[string]string `json:"const_labels,omitempty" yaml:"const_labels,omitempty"`
+	// ResourceKinds are the type properties recorded as the resource_kind of
+	// decisions; any other kind is reported by its fallback
+	ResourceKinds []string `json:"resource_kinds,omitempty" yaml:"resource_kinds,omitempty"`
+}
+
+// metricName is a valid Prometheus metric name or label name
+var metricName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Validate reports every invalid setting
+func (o *Options) Validate() error {
+	var errs []error
+	if o.Namespace != "" && !metricName.MatchString(o.Namespace) {
+		errs = append(errs, fmt.Errorf("namespace: %q is not a valid metric name", o.Namespace))
+	}
+	names := make([]string, 0, len(o.ConstLabels))
+	for name := range o.ConstLabels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !metricName.MatchString(name) || strings.HasPrefix(name, "__") {
+			errs = append(errs, fmt.Errorf("const_labels: %q is not a valid label name", name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CacheStats is a snapshot of a cache read at scrape time
+type CacheStats struct {
+	Entries int
+	Hits    uint64
+	Misses  uint64
+}
+
+func New(name string, opts *Options) (*Handler, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	reg := prometheus.NewRegistry()
+	registerer := prometheus.WrapRegistererWith(opts.ConstLabels, reg)
+	ns := opts.Namespace
+
+	h := &Handler{
+		RequestsReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns,
+			Name:      "http_requests_received",
+			Help:      "The total number of http requests received",
+		}, []string{"method", "route", "status"}),
+		RequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: ns,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of http requests by route template",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		Decisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns,
+			Name:      "authz_decisions_total",
+			Help:      "The total number of authorization decisions",
+		}, []string{"pdp", "decision", "reason", "action", "resource_kind"}),
+		DecisionDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: ns,
+			Name:      "authz_decision_duration_seconds",
+			Help:      "Time taken to evaluate an authorization decision",
+			Buckets:   []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"pdp"}),
+		Obligations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns,
+			Name:      "authz_obligations_total",
+			Help:      "The total number of obligations attached to allowed decisions",
+		}, []string{"type"}),
+		registry: reg,
+		stats:    newStatsCollector(ns),
+		kinds:    make(map[string]struct{}, len(opts.ResourceKinds)),
+	}
+	for _, k := range opts.ResourceKinds {
+		h.kinds[k] = struct{}{}
+	}
+
+	for _, c := range []prometheus.Collector{
+		h.RequestsReceived, h.RequestDuration, h.Decisions, h.DecisionDuration, h.Obligations, h.stats,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{Namespace: ns}),
+	} {
+		if err := registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// HTTPHandler serves the metrics in the Prometheus exposition format
+func (h *Handler) HTTPHandler() http.Handler {
+	return promhttp.HandlerFor(h.registry, promhttp.HandlerOpts{})
+}
+
+// Decision describes an evaluated decision for ObserveDecision
+type Decision struct {
+	PDP          string
+	Decision     string
+	Reason       string
+	Action       string
+	ResourceKind string
+	// Obligations are the types of the obligations attached to the decision
+	Obligations []string
+	Duration    time.Duration
+}
+
+// ObserveDecision records a decision, its latency and its obligations
+func (h *Handler) ObserveDecision(d Decision) {
+	h.Decisions.WithLabelValues(d.PDP, d.Decision, d.Reason, d.Action, d.ResourceKind).Inc()
+	h.DecisionDuration.WithLabelValues(d.PDP).Observe(d.Duration.Seconds())
+	for _, t := range d.Obligations {
+		h.Obligations.WithLabelValues(t).Inc()
+	}
+}
+
+// ResourceKind returns kind if it is in the configured resource kinds and
+// fallback otherwise, which bounds the resource_kind label
+func (h *Handler) ResourceKind(kind, fallback string) string {
+	if _, ok := h.kinds[kind]; ok {
+		return kind
+	}
+	return fallback
+}
+
+// RegisterCache reports the entries, hits and misses of a cache on every scrape
+func (h *Handler) RegisterCache(name string, stats func() CacheStats) {
+	h.stats.mu.Lock()
+	defer h.stats.mu.Unlock()
+	h.stats.caches[name] = stats
+}
+
+// RegisterBreaker reports the state of a circuit breaker on every scrape
+func (h *Handler) RegisterBreaker(name string, state func() int) {
+	h.stats.mu.Lock()
+	defer h.stats.mu.Unlock()
+	h.stats.breakers[name] = state
+}
+
+// statsCollector reads registered caches and breakers at scrape time, so
+// their owners need not depend on this package
+type statsCollector struct {
+	mu       sync.Mutex
+	caches   map[string]func() CacheStats
+	breakers map[string]func() int
+
+	entries  *prometheus.Desc
+	requests *prometheus.Desc
+	breaker  *prometheus.Desc
+}
+
+func newStatsCollector(ns string) *statsCollector {
+	return &statsCollector{
+		caches:   map[string]func() CacheStats{},
+		breakers: map[string]func() int{},
+		entries: prometheus.NewDesc(prometheus.BuildFQName(ns, "", "cache_entries"),
+			"The number of entries in a cache", []string{"cache"}, nil),
+		requests: prometheus.NewDesc(prometheus.BuildFQName(ns, "", "cache_requests_total"),
+			"The total number of cache lookups by result", []string{"cache", "result"}, nil),
+		breaker: prometheus.NewDesc(prometheus.BuildFQName(ns, "", "circuit_breaker_state"),
+			"Circuit breaker state: 0 closed, 1 half-open, 2 open", []string{"breaker"}, nil),
+	}
+}
+
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.entries
+	ch <- c.requests
+	ch <- c.breaker
+}
+
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, stats := range c.caches {
+		s := stats()
+		ch <- prometheus.MustNewConstMetric(c.entries, prometheus.GaugeValue, float64(s.Entries), name)
+		ch <- prometheus.MustNewConstMetric(c.requests, prometheus.CounterValue, float64(s.Hits), name, "hit")
+		ch <- prometheus.MustNewConstMetric(c.requests, prometheus.CounterValue, float64(s.Misses), name, "miss")
+	}
+	for name, state := range c.breakers {
+		ch <- prometheus.MustNewConstMetric(c.breaker, prometheus.GaugeValue, float64(state()), name)
+	}
 }
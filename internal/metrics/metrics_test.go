@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// series returns the label values of every series of a metric family as
+// "name=value,..." strings
+func series(t *testing.T, h *Handler, family string) []string {
+	t.Helper()
+	families, err := h.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, f := range families {
+		if f.GetName() != family {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			var labels []string
+			for _, l := range m.GetLabel() {
+				labels = append(labels, l.GetName()+"="+l.GetValue())
+			}
+			out = append(out, strings.Join(labels, ","))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestResourceKind(t *testing.T) {
+	h, err := New("test", &Options{ResourceKinds: []string{"record", "invoice"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		kind, want string
+	}{
+		{kind: "record", want: "record"},
+		{kind: "invoice", want: "invoice"},
+		{kind: "", want: "O"},
+		{kind: "Record", want: "O"},
+		{kind: "attacker-chosen-123", want: "O"},
+	}
+	for _, tt := range tests {
+		if got := h.ResourceKind(tt.kind, "O"); got != tt.want {
+			t.Errorf("ResourceKind(%q) = %q, want %q", tt.kind, got, tt.want)
+		}
+	}
+}
+
+func TestObserveDecision(t *testing.T) {
+	h, err := New("test", &Options{Namespace: "pm", ConstLabels: map[string]string{"env": "test"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		h.ObserveDecision(Decision{PDP: "ngac", Decision: "allow", Reason: "granted", Action: "read", ResourceKind: "record", Obligations: []string{"log"}, Duration: time.Millisecond})
+	}
+	h.ObserveDecision(Decision{PDP: "ngac", Decision: "deny", Reason: "prohibited", Action: "other", ResourceKind: "unknown"})
+
+	want := []string{
+		"action=other,decision=deny,env=test,pdp=ngac,reason=prohibited,resource_kind=unknown",
+		"action=read,decision=allow,env=test,pdp=ngac,reason=granted,resource_kind=record",
+	}
+	if got := series(t, h, "pm_authz_decisions_total"); strings.Join(got, ";") != strings.Join(want, ";") {
+		t.Errorf("decision series %v, want %v", got, want)
+	}
+	if got := series(t, h, "pm_authz_obligations_total"); len(got) != 1 || got[0] != "env=test,type=log" {
+		t.Errorf("obligation series %v", got)
+	}
+	if got := series(t, h, "pm_authz_decision_duration_seconds"); len(got) != 1 {
+		t.Errorf("duration series %v, want one per pdp", got)
+	}
+}
+
+func TestStats(t *testing.T) {
+	h, err := New("test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.RegisterCache("bundle", func() CacheStats { return CacheStats{Entries: 1, Hits: 4, Misses: 2} })
+	h.RegisterBreaker("opa", func() int { return 2 })
+
+	if got := series(t, h, "cache_requests_total"); len(got) != 2 || got[0] != "cache=bundle,result=hit" {
+		t.Errorf("cache series %v", got)
+	}
+	if got := series(t, h, "circuit_breaker_state"); len(got) != 1 || got[0] != "breaker=opa" {
+		t.Errorf("breaker series %v", got)
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{name: "valid", opts: Options{Namespace: "pm", ConstLabels: map[string]string{"region": "eu"}}},
+		{name: "namespace", opts: Options{Namespace: "policy-machine"}, wantErr: true},
+		{name: "label", opts: Options{ConstLabels: map[string]string{"1region": "eu"}}, wantErr: true},
+		{name: "reserved label", opts: Options{ConstLabels: map[string]string{"__name": "eu"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -4,31 +4,38 @@ import (
 	"context"
 	"net/http"
 	"time"
+
+	"github.com/kumarabd/gokit/logger"
 )
 
-// LoggingMiddleware logs HTTP requests
-func LoggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		
-		// Create a response writer wrapper to capture status code
-		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
-		
-		next.ServeHTTP(wrapped, r)
-		
-		_ = time.Since(start) // duration for logging
-		
-		// Log the request
-		// Note: In a real implementation, you'd use a proper logger here
-		// logger.Info("HTTP Request",
-		// 	"method", r.Method,
-		// 	"path", r.URL.Path,
-		// 	"status", wrapped.statusCode,
-		// 	"duration", duration,
-		// 	"user_agent", r.UserAgent(),
-		// 	"remote_addr", r.RemoteAddr,
-		// )
-	})
+// LoggingMiddleware is GinLogging for a net/http handler
+func LoggingMiddleware(l *logger.Handler, cfg *LoggingConfig) func(http.Handler) http.Handler {
+	rl := newRequestLogger(l, cfg)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := requestID(r)
+			w.Header().Set(RequestIDHeader, id)
+			r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+
+			// Create a response writer wrapper to capture status code
+			wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+			next.ServeHTTP(wrapped, r)
+
+			line := requestLine{
+				id:       id,
+				route:    r.Pattern,
+				status:   wrapped.statusCode,
+				latency:  time.Since(start),
+				decision: decisionOf(r.Context()),
+			}
+			if line.route == "" {
+				line.route = "unmatched"
+			}
+			rl.write(r, line)
+		})
+	}
 }
 
 // CORSMiddleware handles CORS headers
@@ -37,12 +44,12 @@ func CORSMiddleware(next http.Handler) http.Handler {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		
+
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
 			return
 		}
-		
+
 		next.ServeHTTP(w, r)
 	})
 }
@@ -57,7 +64,7 @@ func RecoveryMiddleware(next http.Handler) http.Handler {
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			}
 		}()
-		
+
 		next.ServeHTTP(w, r)
 	})
 }
@@ -68,15 +75,15 @@ func TimeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx, cancel := context.WithTimeout(r.Context(), timeout)
 			defer cancel()
-			
+
 			r = r.WithContext(ctx)
-			
+
 			done := make(chan bool, 1)
 			go func() {
 				next.ServeHTTP(w, r)
 				done <- true
 			}()
-			
+
 			select {
 			case <-done:
 				return
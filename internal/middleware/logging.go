@@ -0,0 +1,219 @@
+package middleware
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kumarabd/gokit/logger"
+	"github.com/kumarabd/policy-machine/pkg/pep"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// redacted replaces the value of sensitive query parameters and headers
+const redacted = "[REDACTED]"
+
+// defaultRedact are always redacted, whatever the configuration
+var defaultRedact = []string{
+	"authorization", "proxy-authorization", "cookie", "set-cookie", "x-api-key",
+	"token", "access_token", "id_token", "refresh_token", "password", "secret", "key",
+}
+
+// LoggingConfig configures request logging
+type LoggingConfig struct {
+	// Disabled turns request logging off
+	Disabled bool `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+	// SampleRate is the fraction of successful requests logged, all when
+	// zero. Server errors and denials are always logged.
+	SampleRate float64 `json:"sample_rate,omitempty" yaml:"sample_rate,omitempty"`
+	// SkipPaths are never logged, such as health probes
+	SkipPaths []string `json:"skip_paths,omitempty" yaml:"skip_paths,omitempty"`
+	// Headers are request headers added to every line
+	Headers []string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	// Redact names further query parameters and headers whose values are
+	// never logged
+	Redact []string `json:"redact,omitempty" yaml:"redact,omitempty"`
+}
+
+// requestLogger writes one line per request
+type requestLogger struct {
+	log    *logger.Handler
+	config LoggingConfig
+	skip   map[string]bool
+	redact map[string]bool
+}
+
+func newRequestLogger(l *logger.Handler, cfg *LoggingConfig) *requestLogger {
+	rl := &requestLogger{log: l, skip: map[string]bool{}, redact: map[string]bool{}}
+	if cfg != nil {
+		rl.config = *cfg
+	}
+	for _, p := range rl.config.SkipPaths {
+		rl.skip[p] = true
+	}
+	for _, name := range append(defaultRedact, rl.config.Redact...) {
+		rl.redact[strings.ToLower(name)] = true
+	}
+	return rl
+}
+
+// requestLine is what is known of a request once it has been served
+type requestLine struct {
+	id       string
+	route    string
+	status   int
+	latency  time.Duration
+	decision *pep.Decision
+}
+
+func (rl *requestLogger) write(r *http.Request, line requestLine) {
+	if rl.config.Disabled || rl.skip[r.URL.Path] || !rl.sampled(line) {
+		return
+	}
+
+	var event *zerolog.Event
+	switch {
+	case line.status >= http.StatusInternalServerError:
+		event = rl.log.Error()
+	case line.status >= http.StatusBadRequest:
+		event = rl.log.Warn()
+	default:
+		event = rl.log.Info()
+	}
+	event = event.
+		Str("request_id", line.id).
+		Str("method", r.Method).
+		Str("route", line.route).
+		Str("path", r.URL.Path).
+		Int("status", line.status).
+		Dur("latency", line.latency).
+		Str("remote_addr", r.RemoteAddr)
+	if q := rl.query(r.URL.Query()); q != "" {
+		event = event.Str("query", q)
+	}
+	if subject := r.Header.Get("X-User-ID"); subject != "" {
+		event = event.Str("subject", subject)
+	}
+	if line.decision != nil {
+		decision := "deny"
+		if line.decision.Allowed {
+			decision = "allow"
+		}
+		event = event.Str("decision", decision).Str("reason", line.decision.Reason)
+	}
+	if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+		event = event.Str("trace_id", sc.TraceID().String())
+	}
+	for _, name := range rl.config.Headers {
+		if v := r.Header.Get(name); v != "" {
+			event = event.Str(strings.ToLower(name), rl.value(name, v))
+		}
+	}
+	event.Msg("request")
+}
+
+// sampled decides whether a request is logged. Errors and denials are
+// always logged.
+func (rl *requestLogger) sampled(line requestLine) bool {
+	rate := rl.config.SampleRate
+	switch {
+	case rate <= 0 || rate >= 1:
+		return true
+	case line.status >= http.StatusInternalServerError:
+		return true
+	case line.decision != nil && !line.decision.Allowed:
+		return true
+	}
+	return rand.Float64() < rate
+}
+
+// query encodes the query string with sensitive values redacted
+func (rl *requestLogger) query(q url.Values) string {
+	for name, values := range q {
+		for i := range values {
+			values[i] = rl.value(name, values[i])
+		}
+	}
+	s, _ := url.QueryUnescape(q.Encode())
+	return s
+}
+
+func (rl *requestLogger) value(name, v string) string {
+	if rl.redact[strings.ToLower(name)] {
+		return redacted
+	}
+	return v
+}
+
+// GinLogging logs every request through the logger and tags it with a
+// request ID, taken from X-Request-ID or generated, which is echoed in the
+// response and available to handlers through RequestID
+func GinLogging(l *logger.Handler, cfg *LoggingConfig) gin.HandlerFunc {
+	rl := newRequestLogger(l, cfg)
+	return func(c *gin.Context) {
+		start := time.Now()
+		id := requestID(c.Request)
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), requestIDKey{}, id))
+
+		c.Next()
+
+		line := requestLine{
+			id:      id,
+			route:   c.FullPath(),
+			status:  c.Writer.Status(),
+			latency: time.Since(start),
+		}
+		if line.route == "" {
+			line.route = "unmatched"
+		}
+		if d, ok := c.Get("decision"); ok {
+			line.decision, _ = d.(*pep.Decision)
+		}
+		rl.write(c.Request, line)
+	}
+}
+
+// decisionOf returns the allowed decision a net/http enforcer stored
+func decisionOf(ctx context.Context) *pep.Decision {
+	d, _ := pep.FromContext(ctx)
+	return d
+}
+
+type requestIDKey struct{}
+
+// RequestID returns the ID of the request being served
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestID accepts the caller's ID when it is short and printable so it
+// cannot forge log lines
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(RequestIDHeader); id != "" && len(id) <= 128 && printable(id) {
+		return id
+	}
+	b := make([]byte, 16)
+	_, _ = crand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func printable(s string) bool {
+	for _, c := range s {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kumarabd/gokit/logger"
+	"github.com/kumarabd/policy-machine/pkg/pep"
+	"github.com/rs/zerolog"
+)
+
+// lines decodes the log lines written to buf
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var out []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestGinLogging(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &LoggingConfig{
+		SampleRate: 0.000001,
+		SkipPaths:  []string{"/healthz"},
+		Headers:    []string{"Authorization", "X-Tenant", "X-Session"},
+		Redact:     []string{"X-Session", "ssn"},
+	}
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		status  int
+		allowed *bool
+		// want are fields of the logged line, none is logged when nil
+		want map[string]interface{}
+	}{
+		{
+			name:    "redacted",
+			path:    "/records?access_token=s3cret-token&ssn=s3cret-ssn&page=2",
+			headers: map[string]string{"Authorization": "Bearer s3cret-bearer", "X-Tenant": "acme", "X-Session": "s3cret-session"},
+			status:  http.StatusInternalServerError,
+			want: map[string]interface{}{
+				"query":         "access_token=" + redacted + "&page=2&ssn=" + redacted,
+				"authorization": redacted,
+				"x-session":     redacted,
+				"x-tenant":      "acme",
+				"route":         "/records",
+				"level":         "error",
+			},
+		},
+		{
+			name:    "denial always logged",
+			path:    "/records",
+			headers: map[string]string{"X-User-ID": "bob"},
+			status:  http.StatusForbidden,
+			allowed: new(bool),
+			want:    map[string]interface{}{"decision": "deny", "subject": "bob", "level": "warn"},
+		},
+		{name: "success sampled out", path: "/records", status: http.StatusOK},
+		{name: "skipped", path: "/healthz", status: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			engine := gin.New()
+			engine.Use(GinLogging(&logger.Handler{Logger: zerolog.New(&buf)}, cfg))
+			handler := func(c *gin.Context) {
+				if tt.allowed != nil {
+					c.Set("decision", &pep.Decision{Allowed: *tt.allowed})
+				}
+				c.Status(tt.status)
+			}
+			engine.GET("/records", handler)
+			engine.GET("/healthz", handler)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			engine.ServeHTTP(httptest.NewRecorder(), req)
+
+			got := lines(t, &buf)
+			if tt.want == nil {
+				if len(got) != 0 {
+					t.Fatalf("logged %v", got)
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("logged %d lines, want 1", len(got))
+			}
+			for k, v := range tt.want {
+				if got[0][k] != v {
+					t.Errorf("%s = %v, want %v", k, got[0][k], v)
+				}
+			}
+			if strings.Contains(buf.String(), "s3cret") {
+				t.Errorf("secret logged: %s", buf.String())
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		keep bool
+	}{
+		{name: "accepted", id: "req-1", keep: true},
+		{name: "generated"},
+		{name: "forged line", id: "x\n{\"level\":\"error\"}"},
+		{name: "too long", id: strings.Repeat("a", 129)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			var seen string
+			h := LoggingMiddleware(&logger.Handler{Logger: zerolog.New(&buf)}, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestID(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.id != "" {
+				req.Header.Set(RequestIDHeader, tt.id)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(RequestIDHeader)
+			if id == "" || id != seen || (id == tt.id) != tt.keep {
+				t.Fatalf("request ID %q, handler saw %q, sent %q", id, seen, tt.id)
+			}
+			if got := lines(t, &buf); len(got) != 1 || got[0]["request_id"] != id {
+				t.Fatalf("logged %v", got)
+			}
+		})
+	}
+}
@@ -1,47 +1,50 @@
-package middleware
+package monitoring
 
 import (
 	"net/http"
 	"strconv"
 	"time"
 
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/gin-gonic/gin"
+	"github.com/kumarabd/policy-machine/internal/metrics"
 )
 
-var (
-	httpRequestsTotal = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "http_requests_total",
-			Help: "Total number of HTTP requests",
-		},
-		[]string{"method", "endpoint", "status"},
-	)
-
-	httpRequestDuration = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "http_request_duration_seconds",
-			Help:    "Duration of HTTP requests",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"method", "endpoint"},
-	)
-)
+// unmatchedRoute labels requests that matched no route, so arbitrary paths
+// cannot grow the label set
+const unmatchedRoute = "unmatched"
+
+// GinMiddleware records the requests of a gin engine by route template
+func GinMiddleware(m *metrics.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		m.RequestsReceived.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		m.RequestDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
 
-func PrometheusMiddleware(next http.Handler) http.Handler {
+// PrometheusMiddleware records the requests of a net/http handler by the
+// ServeMux pattern they matched
+func PrometheusMiddleware(m *metrics.Handler, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		
+
 		// Create a response writer wrapper to capture status code
 		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
-		
+
 		next.ServeHTTP(wrapped, r)
-		
-		duration := time.Since(start).Seconds()
-		
-		// Record metrics
-		httpRequestsTotal.WithLabelValues(r.Method, r.URL.Path, strconv.Itoa(wrapped.statusCode)).Inc()
-		httpRequestDuration.WithLabelValues(r.Method, r.URL.Path).Observe(duration)
+
+		route := r.Pattern
+		if route == "" {
+			route = unmatchedRoute
+		}
+		m.RequestsReceived.WithLabelValues(r.Method, route, strconv.Itoa(wrapped.statusCode)).Inc()
+		m.RequestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
 	})
 }
 
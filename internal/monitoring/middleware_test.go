@@ -0,0 +1,81 @@
+package monitoring
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kumarabd/policy-machine/internal/metrics"
+)
+
+// scrape returns the http_requests_received series of m
+func scrape(t *testing.T, m *metrics.Handler) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.HTTPHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	var lines []string
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if strings.HasPrefix(line, "http_requests_received{") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestRouteLabels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	paths := []string{"/records/rec_1", "/records/rec_2", "/x1", "/x2"}
+
+	tests := []struct {
+		name  string
+		serve func(m *metrics.Handler) http.Handler
+		route string
+	}{
+		{
+			name: "gin",
+			serve: func(m *metrics.Handler) http.Handler {
+				engine := gin.New()
+				engine.Use(GinMiddleware(m))
+				engine.GET("/records/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+				return engine
+			},
+			route: "/records/:id",
+		},
+		{
+			name: "net/http",
+			serve: func(m *metrics.Handler) http.Handler {
+				mux := http.NewServeMux()
+				mux.HandleFunc("GET /records/{id}", func(http.ResponseWriter, *http.Request) {})
+				return PrometheusMiddleware(m, mux)
+			},
+			route: "GET /records/{id}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := metrics.New("test", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := tt.serve(m)
+			for _, path := range paths {
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+			}
+
+			got := scrape(t, m)
+			if !strings.Contains(got, `route="`+tt.route+`",status="200"} 2`) {
+				t.Errorf("no series for %s in\n%s", tt.route, got)
+			}
+			if !strings.Contains(got, `route="unmatched",status="404"} 2`) {
+				t.Errorf("unmatched paths not grouped in\n%s", got)
+			}
+			for _, path := range paths {
+				if strings.Contains(got, path) {
+					t.Errorf("path %s became a label value", path)
+				}
+			}
+		})
+	}
+}
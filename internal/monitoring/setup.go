@@ -4,7 +4,7 @@ import (
 	"net/http"
 	"strconv"
 
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/kumarabd/policy-machine/internal/metrics"
 )
 
 type Config struct {
@@ -13,17 +13,19 @@ type Config struct {
 	Path    string `yaml:"path"`
 }
 
-func SetupMonitoring(cfg *Config) {
+// SetupMonitoring serves the metrics on a dedicated port
+func SetupMonitoring(cfg *Config, m *metrics.Handler) {
 	if !cfg.Enabled {
 		return
 	}
 
 	// Create metrics endpoint
-	http.Handle(cfg.Path, promhttp.Handler())
-	
+	mux := http.NewServeMux()
+	mux.Handle(cfg.Path, m.HTTPHandler())
+
 	go func() {
 		addr := ":" + strconv.Itoa(cfg.Port)
-		if err := http.ListenAndServe(addr, nil); err != nil {
+		if err := http.ListenAndServe(addr, mux); err != nil {
 			// Note: In a real implementation, you'd use a proper logger here
 			// logger.Error("Failed to start metrics server: %v", err)
 		}
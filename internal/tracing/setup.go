@@ -2,49 +2,151 @@ package tracing
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
 
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Exporters supported by SetupTracing
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
 )
 
 type Config struct {
-	Enabled     bool   `yaml:"enabled"`
-	Endpoint    string `yaml:"endpoint"`
-	ServiceName string `yaml:"service_name"`
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Exporter is otlp, stdout or file, otlp by default
+	Exporter string `json:"exporter,omitempty" yaml:"exporter,omitempty"`
+	// Endpoint is the OTLP/HTTP collector, host:port or a URL. The
+	// OTEL_EXPORTER_OTLP_* environment variables apply when empty.
+	Endpoint string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	// Insecure sends OTLP over plain HTTP
+	Insecure bool `json:"insecure,omitempty" yaml:"insecure,omitempty"`
+	// File is where the file exporter writes spans as JSON
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// ServiceName defaults to the application name
+	ServiceName string `json:"service_name,omitempty" yaml:"service_name,omitempty"`
+	// SampleRatio is the fraction of new traces sampled, all by default.
+	// Sampled parents are always followed.
+	SampleRatio float64 `json:"sample_ratio,omitempty" yaml:"sample_ratio,omitempty"`
+}
+
+// Validate reports every invalid setting
+func (c *Config) Validate() error {
+	var errs []error
+	switch c.Exporter {
+	case "", ExporterOTLP, ExporterStdout:
+	case ExporterFile:
+		if c.File == "" {
+			errs = append(errs, errors.New("file: required by the file exporter"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("exporter: unknown exporter %q, expected %s, %s or %s", c.Exporter, ExporterOTLP, ExporterStdout, ExporterFile))
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("sample_ratio: %v is not between 0 and 1", c.SampleRatio))
+	}
+	return errors.Join(errs...)
 }
 
-func SetupTracing(cfg *Config) error {
-	if !cfg.Enabled {
-		return nil
+// SetupTracing installs the global tracer provider and the W3C trace context
+// propagator. The returned function flushes and stops the exporter.
+func SetupTracing(cfg *Config, name, version string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if cfg == nil || !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
 	}
 
-	// Create Jaeger exporter
-	exp, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(cfg.Endpoint)))
+	exp, closer, err := newExporter(cfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Create resource
-	res, err := resource.New(context.Background(),
-		resource.WithAttributes(
-			semconv.ServiceName(cfg.ServiceName),
-		),
-	)
+	if cfg.ServiceName != "" {
+		name = cfg.ServiceName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(name),
+		semconv.ServiceVersion(version),
+	))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Create trace provider
+	sampler := sdktrace.AlwaysSample()
+	if cfg.SampleRatio > 0 && cfg.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(cfg.SampleRatio)
+	}
 	tp := sdktrace.NewTracerProvider(
 		sdktrace.WithBatcher(exp),
 		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
 	)
-
-	// Set global trace provider
 	otel.SetTracerProvider(tp)
 
-	return nil
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closer != nil {
+			closer.Close()
+		}
+		return err
+	}, nil
+}
+
+func newExporter(cfg *Config) (sdktrace.SpanExporter, io.Closer, error) {
+	switch cfg.Exporter {
+	case "", ExporterOTLP:
+		opts := []otlptracehttp.Option{}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(endpointURL(cfg)))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exp, err := otlptracehttp.New(context.Background(), opts...)
+		return exp, nil, err
+	case ExporterStdout:
+		exp, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		return exp, nil, err
+	case ExporterFile:
+		if cfg.File == "" {
+			return nil, nil, fmt.Errorf("tracing.file is required by the file exporter")
+		}
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open trace file: %w", err)
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return exp, f, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+}
+
+// endpointURL accepts host:port as well as a URL, sending to the default
+// traces path
+func endpointURL(cfg *Config) string {
+	if strings.HasPrefix(cfg.Endpoint, "http://") || strings.HasPrefix(cfg.Endpoint, "https://") {
+		return cfg.Endpoint
+	}
+	scheme := "https://"
+	if cfg.Insecure {
+		scheme = "http://"
+	}
+	return scheme + cfg.Endpoint + "/v1/traces"
 }
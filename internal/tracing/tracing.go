@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation names the tracer of this module's spans
+const instrumentation = "github.com/kumarabd/policy-machine"
+
+// Span attributes recorded on decisions
+const (
+	AttrPDP      = attribute.Key("authz.pdp")
+	AttrSubject  = attribute.Key("authz.subject")
+	AttrResource = attribute.Key("authz.resource")
+	AttrAction   = attribute.Key("authz.action")
+	AttrDecision = attribute.Key("authz.decision")
+	AttrReason   = attribute.Key("authz.reason")
+	AttrRevision = attribute.Key("authz.revision")
+)
+
+// Tracer returns the tracer of the global provider, a no-op until
+// SetupTracing enables tracing
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// Start starts an internal span
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Decision records the outcome of a decision on the span
+func Decision(span trace.Span, decision, reason string) {
+	span.SetAttributes(AttrDecision.String(decision), AttrReason.String(reason))
+}
+
+// Error marks the span as failed
+func Error(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -68,6 +68,67 @@ permit if {
   all_conds_hold(e.conds)
 }
 
+# --- Graph permissions: the NGAC graph served in the bundle as data.ngac ---
+# Every node is a key: graph.reachable does not visit nodes missing from
+# the graph, such as policy classes without parents
+ngac_parents := {n.name: parents |
+  some n in data.ngac.nodes
+  parents := {a.parent | some a in data.ngac.assignments; a.child == n.name}
+}
+
+ngac_subject := graph.reachable(ngac_parents, {input.subject.id})
+ngac_object := graph.reachable(ngac_parents, {input.resource.id})
+
+ngac_op(ops) if { input.action in ops }
+ngac_op(ops) if { "*" in ops }
+
+ngac_types := {n.name: n.type | some n in data.ngac.nodes}
+
+# The subject must be a user or user attribute, the object neither a policy
+# class nor a user
+ngac_known if {
+  ngac_types[input.subject.id] in {"U", "UA"}
+  ngac_types[input.resource.id]
+  not ngac_types[input.resource.id] in {"PC", "U"}
+}
+
+# Policy classes containing the object
+ngac_policy_classes := {pc | some pc in ngac_object; ngac_types[pc] == "PC"}
+
+# Policy classes under the target of an association granting the action
+ngac_satisfied := {pc |
+  some assoc in data.ngac.associations
+  ngac_op(assoc.operations)
+  assoc.ua in ngac_subject
+  assoc.target in ngac_object
+  some pc in graph.reachable(ngac_parents, {assoc.target})
+  pc in ngac_policy_classes
+}
+
+# As in service.evaluate, every policy class containing the object must
+# be satisfied
+permit if {
+  ngac_known
+  count(ngac_policy_classes) > 0
+  every pc in ngac_policy_classes { pc in ngac_satisfied }
+}
+
+deny if {
+  some p in data.ngac.prohibitions
+  ngac_op(p.operations)
+  p.subject in ngac_subject
+  not p.intersection
+  some c in p.containers
+  c in ngac_object
+}
+deny if {
+  some p in data.ngac.prohibitions
+  ngac_op(p.operations)
+  p.subject in ngac_subject
+  p.intersection
+  every c in p.containers { c in ngac_object }
+}
+
 # --- Final decision ---
 allow if {
   not deny
@@ -156,3 +156,71 @@ test_attributes_row_filter_in_result if {
   res.allow
   startswith(res.attributes.row_filter, "dept = 'oncology'")
 }
+
+# --- NGAC graph: every policy class containing the object must be satisfied ---
+ngac_graph := {
+  "nodes": [
+    {"name": "clinical", "type": "PC"},
+    {"name": "billing", "type": "PC"},
+    {"name": "doctor", "type": "UA"},
+    {"name": "clerk", "type": "UA"},
+    {"name": "alice", "type": "U"},
+    {"name": "records", "type": "OA"},
+    {"name": "invoices", "type": "OA"},
+    {"name": "rec_1", "type": "O"}
+  ],
+  "assignments": [
+    {"child": "alice", "parent": "doctor"},
+    {"child": "doctor", "parent": "clinical"},
+    {"child": "clerk", "parent": "billing"},
+    {"child": "records", "parent": "clinical"},
+    {"child": "invoices", "parent": "billing"},
+    {"child": "rec_1", "parent": "records"},
+    {"child": "rec_1", "parent": "invoices"}
+  ],
+  "associations": [
+    {"ua": "doctor", "target": "records", "operations": ["read", "write"]},
+    {"ua": "clerk", "target": "invoices", "operations": ["read"]}
+  ],
+  "prohibitions": []
+}
+
+ngac_req(action) := {
+  "subject": {"id": "alice", "attrs": {"dept": "cardiology"}},
+  "resource": {"id": "rec_1", "attrs": {}},
+  "action": action,
+  "edges": {"perm": [], "prohib": []}
+}
+
+test_ngac_deny_when_a_policy_class_is_unsatisfied if {
+  res := authz.result with input as ngac_req("read") with data.ngac as ngac_graph
+  res.allow == false
+}
+
+test_ngac_allow_when_every_policy_class_is_satisfied if {
+  g := json.patch(ngac_graph, [
+    {"op": "add", "path": "/assignments/-", "value": {"child": "alice", "parent": "clerk"}}
+  ])
+  res := authz.result with input as ngac_req("read") with data.ngac as g
+  res.allow == true
+}
+
+test_ngac_deny_operation_missing_in_one_policy_class if {
+  g := json.patch(ngac_graph, [
+    {"op": "add", "path": "/assignments/-", "value": {"child": "alice", "parent": "clerk"}}
+  ])
+  res := authz.result with input as ngac_req("write") with data.ngac as g
+  res.allow == false
+}
+
+test_ngac_allow_single_policy_class if {
+  g := json.patch(ngac_graph, [{"op": "remove", "path": "/assignments/6"}])
+  res := authz.result with input as ngac_req("read") with data.ngac as g
+  res.allow == true
+}
+
+test_ngac_deny_policy_class_as_object if {
+  req := json.patch(ngac_req("read"), [{"op": "replace", "path": "/resource/id", "value": "clinical"}])
+  res := authz.result with input as req with data.ngac as ngac_graph
+  res.allow == false
+}
@@ -0,0 +1,441 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DecisionAllow and DecisionDeny are the recorded outcomes
+	DecisionAllow = "allow"
+	DecisionDeny  = "deny"
+
+	defaultLimit           = 100
+	defaultMaxSize         = 64
+	defaultCheckpointEvery = 1000
+
+	// pruneInterval is how often Append checks for segments past retention
+	pruneInterval = time.Minute
+)
+
+type Config struct {
+	// Dir holds the log segments. The log is memory only when empty.
+	Dir string `json:"dir,omitempty" yaml:"dir,omitempty"`
+	// Retention is the number of hours entries are kept. Zero keeps them
+	// until MaxEntries or MaxFiles is reached.
+	Retention int `json:"retention,omitempty" yaml:"retention,omitempty"`
+	// MaxEntries caps the number of entries kept in memory for search.
+	// Zero is unlimited.
+	MaxEntries int `json:"max_entries,omitempty" yaml:"max_entries,omitempty"`
+	// MaxSize is the size in megabytes at which a segment is rotated, 64 by default
+	MaxSize int `json:"max_size,omitempty" yaml:"max_size,omitempty"`
+	// MaxFiles caps the number of segments kept on disk. Zero is unlimited.
+	MaxFiles int `json:"max_files,omitempty" yaml:"max_files,omitempty"`
+	// CheckpointKey signs checkpoints with HMAC-SHA256. No checkpoints are
+	// written when it is empty.
+	CheckpointKey string `json:"checkpoint_key,omitempty" yaml:"checkpoint_key,omitempty"`
+	// CheckpointEvery is the number of entries between checkpoints, 1000 by default
+	CheckpointEvery int `json:"checkpoint_every,omitempty" yaml:"checkpoint_every,omitempty"`
+}
+
+// Validate reports every invalid setting
+func (c *Config) Validate() error {
+	var errs []error
+	for _, n := range []struct {
+		key   string
+		value int
+	}{
+		{"retention", c.Retention},
+		{"max_entries", c.MaxEntries},
+		{"max_size", c.MaxSize},
+		{"max_files", c.MaxFiles},
+		{"checkpoint_every", c.CheckpointEvery},
+	} {
+		if n.value < 0 {
+			errs = append(errs, fmt.Errorf("%s: must not be negative", n.key))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Entry is a single audit record. Every entry carries the hash of the one
+// before it, so removing or editing an entry breaks the chain.
+type Entry struct {
+	Seq       uint64            `json:"seq"`
+	ID        string            `json:"id,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+	Source    string            `json:"source"`
+	Subject   string            `json:"subject,omitempty"`
+	Resource  string            `json:"resource,omitempty"`
+	Action    string            `json:"action,omitempty"`
+	Decision  string            `json:"decision,omitempty"`
+	Reason    string            `json:"reason,omitempty"`
+	Revision  uint64            `json:"revision,omitempty"`
+	Path      string            `json:"path,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Input     json.RawMessage   `json:"input,omitempty"`
+	Result    json.RawMessage   `json:"result,omitempty"`
+	// Signature is set on checkpoints
+	Signature string `json:"signature,omitempty"`
+	Prev      string `json:"prev"`
+	Hash      string `json:"hash"`
+}
+
+// Query filters entries. Empty fields match everything.
+type Query struct {
+	Source   string
+	Subject  string
+	Resource string
+	Action   string
+	Decision string
+	From     time.Time
+	To       time.Time
+	// Limit caps the number of entries returned, 100 by default
+	Limit int
+}
+
+// Log is an append-only, hash-chained audit log. Recent entries are kept
+// in memory for search; with a directory configured every entry is also
+// written to rotated segment files, one JSON entry per line.
+type Log struct {
+	config Config
+
+	mu         sync.RWMutex
+	entries    []Entry
+	seq        uint64
+	last       string
+	sinceCheck int
+	revision   uint64
+	segment    *segment
+	// pending holds the encoded entries of the batch being appended
+	pending []byte
+	// pruned is when the segments were last pruned
+	pruned time.Time
+}
+
+// head is the state of the chain a failed batch is rolled back to
+type head struct {
+	seq        uint64
+	last       string
+	sinceCheck int
+	revision   uint64
+	entries    int
+	segment    *segment
+	size       int64
+}
+
+// New opens the log and resumes the chain from the last segment
+func New(config *Config) (*Log, error) {
+	l := &Log{}
+	if config != nil {
+		l.config = *config
+	}
+	if l.config.MaxSize <= 0 {
+		l.config.MaxSize = defaultMaxSize
+	}
+	if l.config.CheckpointEvery <= 0 {
+		l.config.CheckpointEvery = defaultCheckpointEvery
+	}
+	if l.config.Dir == "" {
+		return l, nil
+	}
+
+	if err := os.MkdirAll(l.config.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+	cutoff := l.cutoff(time.Now())
+	err := Read(l.config.Dir, func(_ string, e Entry, _ []byte) error {
+		l.advance(e)
+		if !e.Timestamp.Before(cutoff) {
+			l.entries = append(l.entries, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	l.trim()
+
+	if l.segment, err = openSegment(l.config.Dir, l.seq+1); err != nil {
+		return nil, err
+	}
+	if err := l.prune(time.Now()); err != nil {
+		l.segment.close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Append chains the entries onto the log in order, assigning their
+// sequence numbers and hashes. The batch is written with a single write: if
+// it fails none of the batch is kept and the chain is left as it was.
+// Segments past retention are pruned first, at most once per minute.
+func (l *Log) Append(entries []Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now := time.Now(); l.segment != nil && now.Sub(l.pruned) >= pruneInterval {
+		if err := l.prune(now); err != nil {
+			return err
+		}
+	}
+
+	h := l.mark()
+	if err := l.appendBatch(entries); err != nil {
+		return errors.Join(err, l.rollback(h))
+	}
+	l.release(h)
+	l.trim()
+	return nil
+}
+
+// appendBatch chains the entries, starting the next segment first when the
+// current one is full, and writes them. Callers hold the write lock.
+func (l *Log) appendBatch(entries []Entry) error {
+	if l.segment != nil && l.segment.size >= int64(l.config.MaxSize)<<20 {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	for _, e := range entries {
+		if err := l.append(e); err != nil {
+			return err
+		}
+		if l.config.CheckpointKey != "" && l.sinceCheck >= l.config.CheckpointEvery {
+			if err := l.checkpoint(""); err != nil {
+				return err
+			}
+		}
+	}
+	return l.flush()
+}
+
+// Search returns the matching entries held in memory, newest first
+func (l *Log) Search(q Query) []Entry {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	out := []Entry{}
+	for i := len(l.entries) - 1; i >= 0 && len(out) < limit; i-- {
+		e := l.entries[i]
+		if q.matches(e) {
+			out = append(out, e)
+		}
+	}
+	// Entries are appended in arrival order; OPA may upload batches late
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Timestamp.After(out[j].Timestamp) })
+	return out
+}
+
+// Close writes a final checkpoint and closes the current segment
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.segment == nil {
+		return nil
+	}
+	if l.config.CheckpointKey != "" && l.sinceCheck > 0 {
+		if err := l.checkpoint(""); err != nil {
+			l.segment.close()
+			return err
+		}
+		if err := l.flush(); err != nil {
+			l.segment.close()
+			return err
+		}
+	}
+	err := l.segment.close()
+	l.segment = nil
+	return err
+}
+
+func (q Query) matches(e Entry) bool {
+	switch {
+	case q.Source != "" && e.Source != q.Source:
+		return false
+	case q.Subject != "" && e.Subject != q.Subject:
+		return false
+	case q.Resource != "" && e.Resource != q.Resource:
+		return false
+	case q.Action != "" && e.Action != q.Action:
+		return false
+	case q.Decision != "" && e.Decision != q.Decision:
+		return false
+	case !q.From.IsZero() && e.Timestamp.Before(q.From):
+		return false
+	case !q.To.IsZero() && !e.Timestamp.Before(q.To):
+		return false
+	}
+	return true
+}
+
+// append chains a single entry, encoding it for the next flush. Callers
+// hold the write lock.
+func (l *Log) append(e Entry) error {
+	e.Seq = l.seq + 1
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now().UTC()
+	}
+	e.Prev = l.last
+	hash, err := e.hash()
+	if err != nil {
+		return err
+	}
+	e.Hash = hash
+
+	if l.segment != nil {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to encode entry: %w", err)
+		}
+		l.pending = append(append(l.pending, line...), '\n')
+	}
+	l.advance(e)
+	l.entries = append(l.entries, e)
+	return nil
+}
+
+// advance moves the head of the chain to e
+func (l *Log) advance(e Entry) {
+	l.seq, l.last = e.Seq, e.Hash
+	l.sinceCheck++
+	switch {
+	case e.Source == SourceCheckpoint:
+		l.sinceCheck = 0
+	case e.Source == SourceAdmin && e.Action == actionCommit:
+		l.revision = e.Revision
+	}
+}
+
+// flush writes the pending entries to the segment and syncs it. Callers
+// hold the write lock.
+func (l *Log) flush() error {
+	pending := l.pending
+	l.pending = nil
+	if l.segment == nil || len(pending) == 0 {
+		return nil
+	}
+	if err := l.segment.write(pending); err != nil {
+		return err
+	}
+	return l.segment.sync()
+}
+
+// mark records the head of the chain before a batch
+func (l *Log) mark() head {
+	h := head{seq: l.seq, last: l.last, sinceCheck: l.sinceCheck, revision: l.revision, entries: len(l.entries), segment: l.segment}
+	if l.segment != nil {
+		h.size = l.segment.size
+	}
+	return h
+}
+
+// rollback restores the chain to h, removing the segment a failed batch
+// started and cutting what it wrote from the one before. Callers hold the
+// write lock.
+func (l *Log) rollback(h head) error {
+	l.seq, l.last, l.sinceCheck, l.revision = h.seq, h.last, h.sinceCheck, h.revision
+	l.entries = l.entries[:h.entries]
+	l.pending = nil
+	if l.segment == nil {
+		return nil
+	}
+	var err error
+	if l.segment != h.segment {
+		err = errors.Join(l.segment.close(), os.Remove(l.segment.file.Name()))
+		l.segment = h.segment
+	}
+	return errors.Join(err, l.segment.truncate(h.size))
+}
+
+// release closes the segment a written batch rotated away from. Closed
+// segments are pruned on the next append. Callers hold the write lock.
+func (l *Log) release(h head) {
+	if h.segment != nil && h.segment != l.segment {
+		h.segment.close()
+		l.pruned = time.Time{}
+	}
+}
+
+// rotate starts the next segment with a checkpoint, keeping the current one
+// open until the batch is written. Callers hold the write lock.
+func (l *Log) rotate() error {
+	if err := l.segment.sync(); err != nil {
+		return err
+	}
+	next, err := newSegment(l.config.Dir, l.seq+1)
+	if err != nil {
+		return err
+	}
+	l.segment = next
+	if l.config.CheckpointKey != "" {
+		return l.checkpoint(reasonRotation)
+	}
+	return nil
+}
+
+// prune removes the closed segments past retention or MaxFiles
+func (l *Log) prune(now time.Time) error {
+	files, err := segments(l.config.Dir)
+	if err != nil {
+		return err
+	}
+	// The open segment is always the last and is never removed
+	closed := files[:len(files)-1]
+	drop := 0
+	if l.config.MaxFiles > 0 && len(files) > l.config.MaxFiles {
+		drop = len(files) - l.config.MaxFiles
+	}
+	cutoff := l.cutoff(now)
+	for drop < len(closed) && l.config.Retention > 0 {
+		info, err := os.Stat(closed[drop])
+		if err != nil {
+			return err
+		}
+		// A segment is no longer written once closed, so its modification
+		// time is that of its newest entry
+		if !info.ModTime().Before(cutoff) {
+			break
+		}
+		drop++
+	}
+	for _, f := range closed[:min(drop, len(closed))] {
+		if err := os.Remove(f); err != nil {
+			return fmt.Errorf("failed to remove audit segment: %w", err)
+		}
+	}
+	l.pruned = now
+	return nil
+}
+
+// trim drops the in-memory entries past retention or MaxEntries. Callers
+// hold the write lock.
+func (l *Log) trim() {
+	drop := 0
+	cutoff := l.cutoff(time.Now())
+	for drop < len(l.entries) && l.entries[drop].Timestamp.Before(cutoff) {
+		drop++
+	}
+	if max := l.config.MaxEntries; max > 0 && len(l.entries)-drop > max {
+		drop = len(l.entries) - max
+	}
+	if drop > 0 {
+		l.entries = append([]Entry(nil), l.entries[drop:]...)
+	}
+}
+
+func (l *Log) cutoff(now time.Time) time.Time {
+	if l.config.Retention <= 0 {
+		return time.Time{}
+	}
+	return now.Add(-time.Duration(l.config.Retention) * time.Hour)
+}
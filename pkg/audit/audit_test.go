@@ -0,0 +1,136 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPruneOnAppend(t *testing.T) {
+	dir := t.TempDir()
+	writeLog(t, dir, 4, []byte(`"`+strings.Repeat("x", 600<<10)+`"`))
+	l, err := New(&Config{Dir: dir, Retention: 1, CheckpointKey: testKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	files, err := segments(dir)
+	if err != nil || len(files) != 2 {
+		t.Fatalf("segments = %v, %v, want 2", files, err)
+	}
+	// The closed segment ages past retention while the log is open
+	old := time.Now().Add(-2 * time.Hour)
+	for _, f := range files[:1] {
+		if err := os.Chtimes(f, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		want    int
+	}{
+		{name: "within the prune interval", want: 2},
+		{name: "after the prune interval", elapsed: pruneInterval, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l.pruned = l.pruned.Add(-tt.elapsed)
+			if err := l.Append([]Entry{{Source: SourcePEP, Subject: "alice"}}); err != nil {
+				t.Fatal(err)
+			}
+			if files, _ := segments(dir); len(files) != tt.want {
+				t.Fatalf("segments = %v, want %d", files, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendRollback(t *testing.T) {
+	padding := []byte(`"` + strings.Repeat("x", 600<<10) + `"`)
+	ok := Entry{Source: SourcePEP, Subject: "alice", Decision: DecisionAllow}
+	// an entry with invalid raw JSON cannot be encoded
+	bad := Entry{Source: SourcePEP, Subject: "mallory", Input: json.RawMessage("{")}
+
+	tests := []struct {
+		name string
+		// before appends entries ahead of the failing batch
+		before []Entry
+		// fail makes the batch fail, returning the entries to append
+		fail func(t *testing.T, l *Log) []Entry
+		// reopen replaces the segment file closed by fail
+		reopen bool
+	}{
+		{
+			name:   "entry cannot be encoded",
+			before: []Entry{ok},
+			fail:   func(*testing.T, *Log) []Entry { return []Entry{ok, ok, bad} },
+		},
+		{
+			name:   "rotated segment removed",
+			before: []Entry{{Source: SourcePEP, Input: padding}, {Source: SourcePEP, Input: padding}},
+			fail:   func(*testing.T, *Log) []Entry { return []Entry{ok, bad} },
+		},
+		{
+			name:   "write fails",
+			before: []Entry{ok},
+			fail: func(t *testing.T, l *Log) []Entry {
+				l.segment.file.Close()
+				return []Entry{ok, ok}
+			},
+			reopen: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			l, err := New(&Config{Dir: dir, CheckpointKey: testKey, CheckpointEvery: 2, MaxSize: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := l.Append(tt.before); err != nil {
+				t.Fatal(err)
+			}
+			seq, last := l.seq, l.last
+			files, _ := segments(dir)
+			held := len(l.Search(Query{}))
+
+			if err := l.Append(tt.fail(t, l)); err == nil {
+				t.Fatal("Append() succeeded")
+			}
+			if l.seq != seq || l.last != last {
+				t.Errorf("chain at %d %s, want %d %s", l.seq, l.last, seq, last)
+			}
+			if n := len(l.Search(Query{})); n != held {
+				t.Errorf("%d entries held, want %d", n, held)
+			}
+			if after, _ := segments(dir); len(after) != len(files) {
+				t.Errorf("segments %v, want %v", after, files)
+			}
+			if tt.reopen {
+				if l.segment, err = appendSegment(files[len(files)-1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// The chain continues from the entries before the failed batch
+			if err := l.Append([]Entry{ok}); err != nil {
+				t.Fatal(err)
+			}
+			if err := l.Close(); err != nil {
+				t.Fatal(err)
+			}
+			report, err := Verify(dir, testKey, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.First != 1 || report.Entries != int(report.Last) {
+				t.Fatalf("Verify() = %+v", *report)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	// SourceCheckpoint marks the signed checkpoints written into the chain
+	SourceCheckpoint = "checkpoint"
+
+	// reasonRotation marks the checkpoint that starts every segment after
+	// the first, so a log pruned by retention starts with a signed entry
+	reasonRotation = "rotation"
+)
+
+// hash is the SHA-256 of the entry encoded without its own hash. The
+// encoding covers Prev, which links the entry to the one before it.
+func (e Entry) hash() (string, error) {
+	e.Hash = ""
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode entry: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// checkpoint appends an entry signing the position and head of the chain.
+// Callers hold the write lock.
+func (l *Log) checkpoint(reason string) error {
+	e := Entry{Seq: l.seq + 1, Source: SourceCheckpoint, Reason: reason, Timestamp: time.Now().UTC(), Prev: l.last}
+	sig, err := sign(l.config.CheckpointKey, e)
+	if err != nil {
+		return err
+	}
+	e.Signature = sig
+	return l.append(e)
+}
+
+// sign is the HMAC-SHA256 of a checkpoint encoded without its hash and
+// signature. The encoding covers its sequence number, its reason and the
+// hash of the entry before it.
+func sign(key string, e Entry) (string, error) {
+	e.Hash, e.Signature = "", ""
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(b)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
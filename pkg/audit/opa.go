@@ -0,0 +1,123 @@
+package audit
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// SourceOPA marks entries uploaded by OPA's decision log plugin
+const SourceOPA = "opa"
+
+// MaxOPABatchSize bounds a decision log batch after decompression
+const MaxOPABatchSize = 16 << 20
+
+// ErrBatchTooLarge is returned for batches over MaxOPABatchSize
+var ErrBatchTooLarge = errors.New("decision log batch is too large")
+
+// opaEvent is an event in OPA's decision log upload format
+type opaEvent struct {
+	DecisionID string            `json:"decision_id"`
+	Path       string            `json:"path"`
+	Labels     map[string]string `json:"labels"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Input      json.RawMessage   `json:"input"`
+	Result     json.RawMessage   `json:"result"`
+}
+
+// DecodeOPA reads a batch uploaded by OPA: a JSON array of decision events,
+// gzip compressed when gzipped is set. Batches decompressing to more than
+// MaxOPABatchSize bytes are rejected with ErrBatchTooLarge.
+func DecodeOPA(r io.Reader, gzipped bool) ([]Entry, error) {
+	if gzipped {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r, MaxOPABatchSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read decision log batch: %w", err)
+	}
+	if len(body) > MaxOPABatchSize {
+		return nil, ErrBatchTooLarge
+	}
+	var events []opaEvent
+	if err := json.Unmarshal(body, &events); err != nil {
+		return nil, fmt.Errorf("invalid decision log batch: %w", err)
+	}
+	entries := make([]Entry, 0, len(events))
+	for _, ev := range events {
+		e := Entry{
+			ID:        ev.DecisionID,
+			Timestamp: ev.Timestamp,
+			Source:    SourceOPA,
+			Path:      ev.Path,
+			Labels:    ev.Labels,
+			Input:     ev.Input,
+			Result:    ev.Result,
+			Decision:  opaDecision(ev.Result),
+		}
+		e.Subject, e.Resource, e.Action = opaRequest(ev.Input)
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// opaRequest reads the subject, resource and action from the input shapes
+// used by this server: the authz middleware, ext_authz and PDP requests
+func opaRequest(raw json.RawMessage) (subject, resource, action string) {
+	var input map[string]interface{}
+	if json.Unmarshal(raw, &input) != nil {
+		return "", "", ""
+	}
+	subject = field(input, "subject", "user")
+	resource = field(input, "resource", "object")
+	action = field(input, "action", "operation")
+	return
+}
+
+// field returns the first key that is a string, or an object with an id
+func field(input map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		switch v := input[k].(type) {
+		case string:
+			return v
+		case map[string]interface{}:
+			if id, ok := v["id"].(string); ok {
+				return id
+			}
+		}
+	}
+	return ""
+}
+
+// opaDecision interprets the result as a boolean, or an object with an
+// allow flag or decision string. Anything else, including an undefined
+// result, is a deny.
+func opaDecision(raw json.RawMessage) string {
+	var result interface{}
+	if json.Unmarshal(raw, &result) != nil {
+		return DecisionDeny
+	}
+	switch v := result.(type) {
+	case bool:
+		if v {
+			return DecisionAllow
+		}
+	case map[string]interface{}:
+		if allow, ok := v["allow"].(bool); ok && allow {
+			return DecisionAllow
+		}
+		if d, ok := v["decision"].(string); ok && d == DecisionAllow {
+			return DecisionAllow
+		}
+	}
+	return DecisionDeny
+}
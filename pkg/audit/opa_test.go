@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeOPA(t *testing.T) {
+	type request struct{ subject, resource, action, decision string }
+	batch := []byte(`[
+		{"decision_id": "1", "input": {"subject": {"id": "alice"}, "resource": {"id": "rec"}, "action": "read"}, "result": {"allow": true}},
+		{"decision_id": "2", "input": {"user": "bob", "object": "rec", "operation": "write"}, "result": {"decision": "deny"}},
+		{"decision_id": "3", "input": {"subject": "carol"}, "result": true},
+		{"decision_id": "4"}
+	]`)
+	want := []request{
+		{"alice", "rec", "read", DecisionAllow},
+		{"bob", "rec", "write", DecisionDeny},
+		{"carol", "", "", DecisionAllow},
+		{"", "", "", DecisionDeny},
+	}
+	tests := []struct {
+		name     string
+		body     []byte
+		gzipped  bool
+		want     []request
+		err      error
+		contains string
+	}{
+		{name: "plain", body: batch, want: want},
+		{name: "gzipped", body: gzipped(t, batch), gzipped: true, want: want},
+		{name: "empty batch", body: []byte(`[]`), want: []request{}},
+		{name: "not gzipped", body: batch, gzipped: true, contains: "invalid gzip body"},
+		{name: "not an array", body: []byte(`{}`), contains: "invalid decision log batch"},
+		{name: "too large", body: bytes.Repeat([]byte(" "), MaxOPABatchSize+1), err: ErrBatchTooLarge},
+		{name: "decompresses too large", body: gzipped(t, bytes.Repeat([]byte(" "), MaxOPABatchSize+1)), gzipped: true, err: ErrBatchTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := DecodeOPA(bytes.NewReader(tt.body), tt.gzipped)
+			switch {
+			case tt.err != nil:
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("DecodeOPA() error = %v, want %v", err, tt.err)
+				}
+				return
+			case tt.contains != "":
+				if err == nil || !strings.Contains(err.Error(), tt.contains) {
+					t.Fatalf("DecodeOPA() error = %v, want %q", err, tt.contains)
+				}
+				return
+			case err != nil:
+				t.Fatal(err)
+			}
+			if len(entries) != len(tt.want) {
+				t.Fatalf("decoded %d entries, want %d", len(entries), len(tt.want))
+			}
+			for i, e := range entries {
+				got := request{e.Subject, e.Resource, e.Action, e.Decision}
+				if got != tt.want[i] || e.Source != SourceOPA {
+					t.Errorf("entry %d = %+v from %s, want %+v", i, got, e.Source, tt.want[i])
+				}
+			}
+		})
+	}
+}
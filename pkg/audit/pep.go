@@ -0,0 +1,27 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/kumarabd/policy-machine/pkg/pep"
+)
+
+// SourcePEP marks decisions enforced by a pep.Enforcer or the ext_authz service
+const SourcePEP = "pep"
+
+// Record implements pep.Recorder
+func (l *Log) Record(ctx context.Context, req pep.Request, d *pep.Decision) error {
+	decision := DecisionDeny
+	if d.Allowed {
+		decision = DecisionAllow
+	}
+	return l.Append([]Entry{{
+		Source:   SourcePEP,
+		Subject:  req.Subject,
+		Resource: req.Resource,
+		Action:   req.Action,
+		Decision: decision,
+		Reason:   d.Reason,
+		Revision: d.Revision,
+	}})
+}
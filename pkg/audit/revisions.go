@@ -0,0 +1,101 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+)
+
+// SourceAdmin marks committed graph revisions
+const SourceAdmin = "admin"
+
+const (
+	actionCommit = "commit"
+	actionGap    = "gap"
+)
+
+// Revisions is the part of the policy service whose commits are audited
+type Revisions interface {
+	Graph(ctx context.Context, revision uint64) (*model.Graph, uint64, error)
+	Watch(ctx context.Context, from uint64) (<-chan model.Event, error)
+}
+
+// Follow records every revision committed to the store until ctx is done.
+// It resumes after the last revision in the log, so commits made while the
+// server was down are recorded on the next start. Revisions compacted away
+// before they were recorded are logged as a gap.
+func (l *Log) Follow(ctx context.Context, r Revisions) error {
+	from := l.lastRevision()
+	if _, latest, err := r.Graph(ctx, 0); err != nil {
+		return err
+	} else if from > latest {
+		// A memory store restarts from scratch, a persistent one lost revisions
+		if err := l.gap(fmt.Sprintf("store is at revision %d, behind the recorded revision %d", latest, from)); err != nil {
+			return err
+		}
+		from = 0
+	}
+
+	for {
+		events, err := r.Watch(ctx, from)
+		var compacted *model.CompactedError
+		if errors.As(err, &compacted) {
+			if err := l.gap(fmt.Sprintf("revisions %d to %d were compacted before they were recorded", from+1, compacted.Compacted)); err != nil {
+				return err
+			}
+			from = compacted.Compacted
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for ev := range events {
+			if ev.Type == model.EventResync {
+				if err := l.gap(fmt.Sprintf("revisions after %d were compacted before they were recorded", from)); err != nil {
+					return err
+				}
+				from = ev.Compacted
+				break
+			}
+			if err := l.commit(*ev.Revision); err != nil {
+				return err
+			}
+			from = ev.Revision.Number
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+func (l *Log) commit(rev model.Revision) error {
+	changes, err := json.Marshal(rev.Changes)
+	if err != nil {
+		return err
+	}
+	return l.Append([]Entry{{
+		Timestamp: rev.Timestamp,
+		Source:    SourceAdmin,
+		Subject:   rev.Author,
+		Action:    actionCommit,
+		Reason:    rev.Message,
+		Revision:  rev.Number,
+		Labels:    rev.Tags,
+		Input:     changes,
+	}})
+}
+
+func (l *Log) gap(reason string) error {
+	return l.Append([]Entry{{Source: SourceAdmin, Action: actionGap, Reason: reason}})
+}
+
+// lastRevision is the newest revision recorded
+func (l *Log) lastRevision() uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.revision
+}
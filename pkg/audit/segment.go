@@ -0,0 +1,133 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// segmentPattern names a segment by the sequence number of its first entry,
+// zero padded so the names sort in log order
+const (
+	segmentPattern = "audit-%020d.log"
+	segmentGlob    = "audit-*.log"
+)
+
+// segment is the log file currently appended to
+type segment struct {
+	file *os.File
+	size int64
+}
+
+// openSegment reopens the newest segment in dir, or starts one at seq when
+// there is none
+func openSegment(dir string, seq uint64) (*segment, error) {
+	files, err := segments(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > 0 {
+		return appendSegment(files[len(files)-1])
+	}
+	return newSegment(dir, seq)
+}
+
+// newSegment starts a segment whose first entry is seq
+func newSegment(dir string, seq uint64) (*segment, error) {
+	return appendSegment(filepath.Join(dir, fmt.Sprintf(segmentPattern, seq)))
+}
+
+func appendSegment(path string) (*segment, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit segment: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &segment{file: f, size: info.Size()}, nil
+}
+
+// write appends lines, each ending in a newline, with a single write
+func (s *segment) write(lines []byte) error {
+	n, err := s.file.Write(lines)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// truncate cuts the segment back to size, dropping a failed write
+func (s *segment) truncate(size int64) error {
+	if err := s.file.Truncate(size); err != nil {
+		return fmt.Errorf("failed to roll back audit log: %w", err)
+	}
+	s.size = size
+	return nil
+}
+
+func (s *segment) sync() error {
+	return s.file.Sync()
+}
+
+func (s *segment) close() error {
+	return s.file.Close()
+}
+
+// segments returns the segment files in dir in log order
+func segments(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, segmentGlob))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Read feeds every entry in the segments of dir to fn in log order, with
+// the segment it was read from and the line as written
+func Read(dir string, fn func(file string, e Entry, raw []byte) error) error {
+	files, err := segments(dir)
+	if err != nil {
+		return err
+	}
+	for _, path := range files {
+		if err := readSegment(path, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readSegment(path string, fn func(file string, e Entry, raw []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	name := filepath.Base(path)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return fmt.Errorf("%s line %d: %w", name, line, err)
+		}
+		if err := fn(name, e, scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,152 @@
+package audit
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Report summarises a verified log
+type Report struct {
+	Segments int    `json:"segments"`
+	Entries  int    `json:"entries"`
+	First    uint64 `json:"first"`
+	Last     uint64 `json:"last"`
+	// Checkpoints counts the checkpoints whose signature was checked
+	Checkpoints int `json:"checkpoints"`
+	// Unchecked counts the checkpoints skipped for want of a key
+	Unchecked int `json:"unchecked"`
+}
+
+// VerifyError locates the first break in the chain
+type VerifyError struct {
+	File   string `json:"file"`
+	Seq    uint64 `json:"seq"`
+	Reason string `json:"reason"`
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("%s: entry %d: %s", e.File, e.Seq, e.Reason)
+}
+
+// Verify walks the segments in dir and checks that sequence numbers have no
+// gaps, every entry links to the hash of the one before it and hashes to
+// its recorded value, and, when key is set, every checkpoint signature.
+// With a key the log must also have a checkpoint at least every `every`
+// entries and end in one, so it cannot be rewritten or cut short without
+// the key. The chain may start after seq 1 when old segments were removed
+// by retention; that start, reported as First, must then be the signed
+// checkpoint that began its segment.
+func Verify(dir, key string, every int) (*Report, error) {
+	if every <= 0 {
+		every = defaultCheckpointEvery
+	}
+	report := &Report{}
+	var prev Entry
+	file := ""
+	since := 0
+	err := Read(dir, func(name string, e Entry, _ []byte) error {
+		if name != file {
+			file = name
+			report.Segments++
+		}
+		fail := func(format string, args ...interface{}) error {
+			return &VerifyError{File: name, Seq: e.Seq, Reason: fmt.Sprintf(format, args...)}
+		}
+
+		switch {
+		case report.Entries == 0 && e.Seq == 1 && e.Prev != "":
+			return fail("first entry links to %q", e.Prev)
+		case report.Entries == 0 && e.Seq > 1 && key != "" && (e.Source != SourceCheckpoint || e.Reason != reasonRotation):
+			return fail("log starts after entry 1 without the checkpoint that begins a segment")
+		case report.Entries > 0 && e.Seq != prev.Seq+1:
+			return fail("expected entry %d, entries are missing or reordered", prev.Seq+1)
+		case report.Entries > 0 && e.Prev != prev.Hash:
+			return fail("previous hash %q does not match entry %d", e.Prev, prev.Seq)
+		}
+		hash, err := e.hash()
+		if err != nil {
+			return err
+		}
+		if hash != e.Hash {
+			return fail("content does not match its hash")
+		}
+
+		switch {
+		case e.Source != SourceCheckpoint:
+			since++
+			if key != "" && since > every {
+				return fail("no checkpoint in the %d entries before it", every)
+			}
+		case key == "":
+			since = 0
+			report.Unchecked++
+		default:
+			since = 0
+			sig, err := sign(key, e)
+			if err != nil {
+				return err
+			}
+			if !hmac.Equal([]byte(sig), []byte(e.Signature)) {
+				return fail("checkpoint signature is invalid")
+			}
+			report.Checkpoints++
+		}
+
+		if report.Entries == 0 {
+			report.First = e.Seq
+		}
+		report.Last = e.Seq
+		report.Entries++
+		prev = e
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	if key != "" && report.Entries > 0 && prev.Source != SourceCheckpoint {
+		return report, &VerifyError{File: file, Seq: prev.Seq, Reason: "log does not end in a checkpoint, entries after the last one are not signed"}
+	}
+	return report, nil
+}
+
+// Export writes the entries within [from, to) as JSON Lines, from the
+// segments on disk or, for a memory only log, the entries held in memory
+func (l *Log) Export(w io.Writer, from, to time.Time) error {
+	// Holding the lock keeps appends from leaving a partial last line
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.config.Dir != "" {
+		return Export(l.config.Dir, w, from, to)
+	}
+	q := Query{From: from, To: to}
+	enc := json.NewEncoder(w)
+	for _, e := range l.entries {
+		if !q.matches(e) {
+			continue
+		}
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Export writes the entries of the segments in dir within [from, to) as
+// JSON Lines, exactly as stored so the hashes still verify. Zero times are
+// unbounded.
+func Export(dir string, w io.Writer, from, to time.Time) error {
+	q := Query{From: from, To: to}
+	return Read(dir, func(_ string, e Entry, raw []byte) error {
+		if !q.matches(e) {
+			return nil
+		}
+		if _, err := w.Write(raw); err != nil {
+			return err
+		}
+		_, err := w.Write([]byte{'\n'})
+		return err
+	})
+}
@@ -0,0 +1,304 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testKey = "secret"
+
+// writeLog appends n decisions to a log in dir, with a checkpoint after
+// every three, and closes it
+func writeLog(t *testing.T, dir string, n int, input []byte) {
+	t.Helper()
+	l, err := New(&Config{Dir: dir, CheckpointKey: testKey, CheckpointEvery: 3, MaxSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		e := Entry{Source: SourcePEP, Subject: "alice", Resource: "rec", Action: "read", Decision: DecisionAllow, Input: input}
+		if err := l.Append([]Entry{e}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// editLines rewrites the lines of the only segment in dir
+func editLines(t *testing.T, dir string, fn func(lines [][]byte) [][]byte) {
+	t.Helper()
+	files, err := segments(dir)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("want one segment, got %v (%v)", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := fn(bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n")))
+	if err := os.WriteFile(files[0], append(bytes.Join(lines, []byte("\n")), '\n'), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// editEntry decodes line i, lets fn change it and encodes it back
+func editEntry(t *testing.T, lines [][]byte, i int, fn func(*Entry)) {
+	t.Helper()
+	var e Entry
+	if err := json.Unmarshal(lines[i], &e); err != nil {
+		t.Fatal(err)
+	}
+	fn(&e)
+	line, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines[i] = line
+}
+
+// rechain renumbers and rehashes the entries of lines as a forger without
+// the key would
+func rechain(t *testing.T, lines [][]byte) [][]byte {
+	t.Helper()
+	prev := ""
+	for i := range lines {
+		editEntry(t, lines, i, func(e *Entry) {
+			e.Seq, e.Prev = uint64(i+1), prev
+			e.Hash, _ = e.hash()
+			prev = e.Hash
+		})
+	}
+	return lines
+}
+
+// drop removes the lines for which remove is true
+func drop(t *testing.T, lines [][]byte, remove func(e Entry) bool) [][]byte {
+	t.Helper()
+	var out [][]byte
+	for _, line := range lines {
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			t.Fatal(err)
+		}
+		if !remove(e) {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries int
+		// input pads every entry, so a large one rotates segments
+		input  []byte
+		key    string
+		tamper func(t *testing.T, dir string)
+		want   Report
+		// err is the reason of the expected VerifyError
+		err    string
+		errSeq uint64
+	}{
+		{
+			name:    "intact",
+			entries: 5,
+			key:     testKey,
+			want:    Report{Segments: 1, Entries: 7, First: 1, Last: 7, Checkpoints: 2},
+		},
+		{
+			name:    "checkpoints unchecked without a key",
+			entries: 5,
+			want:    Report{Segments: 1, Entries: 7, First: 1, Last: 7, Unchecked: 2},
+		},
+		{
+			name:    "rotated segments",
+			entries: 4,
+			input:   []byte(`"` + strings.Repeat("x", 600<<10) + `"`),
+			key:     testKey,
+			// a full segment is rotated by the next append, and every
+			// segment after the first starts with a checkpoint
+			want: Report{Segments: 2, Entries: 6, First: 1, Last: 6, Checkpoints: 2},
+		},
+		{
+			name:    "oldest segment removed by retention",
+			entries: 4,
+			input:   []byte(`"` + strings.Repeat("x", 600<<10) + `"`),
+			key:     testKey,
+			tamper: func(t *testing.T, dir string) {
+				files, _ := segments(dir)
+				if err := os.Remove(files[0]); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: Report{Segments: 1, Entries: 4, First: 3, Last: 6, Checkpoints: 2},
+		},
+		{
+			name:    "checkpoints removed and the chain rehashed",
+			entries: 5,
+			key:     testKey,
+			tamper: func(t *testing.T, dir string) {
+				editLines(t, dir, func(lines [][]byte) [][]byte {
+					return rechain(t, drop(t, lines, func(e Entry) bool { return e.Source == SourceCheckpoint }))
+				})
+			},
+			err:    "no checkpoint in the 3 entries before it",
+			errSeq: 4,
+		},
+		{
+			name:    "entries removed from the end",
+			entries: 5,
+			key:     testKey,
+			tamper: func(t *testing.T, dir string) {
+				editLines(t, dir, func(lines [][]byte) [][]byte { return lines[:len(lines)-1] })
+			},
+			err:    "log does not end in a checkpoint",
+			errSeq: 6,
+		},
+		{
+			name:    "entries removed from the start",
+			entries: 5,
+			key:     testKey,
+			tamper: func(t *testing.T, dir string) {
+				editLines(t, dir, func(lines [][]byte) [][]byte { return lines[2:] })
+			},
+			err:    "log starts after entry 1",
+			errSeq: 3,
+		},
+		{
+			name:    "entries removed up to a checkpoint",
+			entries: 5,
+			key:     testKey,
+			tamper: func(t *testing.T, dir string) {
+				editLines(t, dir, func(lines [][]byte) [][]byte { return lines[3:] })
+			},
+			err:    "log starts after entry 1",
+			errSeq: 4,
+		},
+		{
+			name:    "edited entry",
+			entries: 5,
+			key:     testKey,
+			tamper: func(t *testing.T, dir string) {
+				editLines(t, dir, func(lines [][]byte) [][]byte {
+					editEntry(t, lines, 1, func(e *Entry) { e.Decision = DecisionDeny })
+					return lines
+				})
+			},
+			err:    "content does not match its hash",
+			errSeq: 2,
+		},
+		{
+			name:    "edited entry with its hash recomputed",
+			entries: 5,
+			key:     testKey,
+			tamper: func(t *testing.T, dir string) {
+				editLines(t, dir, func(lines [][]byte) [][]byte {
+					editEntry(t, lines, 1, func(e *Entry) {
+						e.Decision = DecisionDeny
+						e.Hash, _ = e.hash()
+					})
+					return lines
+				})
+			},
+			err:    "previous hash",
+			errSeq: 3,
+		},
+		{
+			name:    "removed entry",
+			entries: 5,
+			key:     testKey,
+			tamper: func(t *testing.T, dir string) {
+				editLines(t, dir, func(lines [][]byte) [][]byte { return append(lines[:2], lines[3:]...) })
+			},
+			err:    "expected entry 3",
+			errSeq: 4,
+		},
+		{
+			name:    "reordered entries",
+			entries: 5,
+			key:     testKey,
+			tamper: func(t *testing.T, dir string) {
+				editLines(t, dir, func(lines [][]byte) [][]byte {
+					lines[0], lines[1] = lines[1], lines[0]
+					return lines
+				})
+			},
+			err:    "log starts after entry 1",
+			errSeq: 2,
+		},
+		{
+			name:    "wrong key",
+			entries: 5,
+			key:     "other",
+			err:     "checkpoint signature is invalid",
+			errSeq:  4,
+		},
+		{
+			name:    "forged checkpoint",
+			entries: 5,
+			key:     testKey,
+			tamper: func(t *testing.T, dir string) {
+				editLines(t, dir, func(lines [][]byte) [][]byte {
+					editEntry(t, lines, 3, func(e *Entry) {
+						e.Signature, _ = sign("guess", *e)
+						e.Hash, _ = e.hash()
+					})
+					return lines
+				})
+			},
+			err:    "checkpoint signature is invalid",
+			errSeq: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeLog(t, dir, tt.entries, tt.input)
+			if tt.tamper != nil {
+				tt.tamper(t, dir)
+			}
+
+			report, err := Verify(dir, tt.key, 3)
+			if tt.err != "" {
+				var verr *VerifyError
+				if !errors.As(err, &verr) || !strings.Contains(verr.Reason, tt.err) || verr.Seq != tt.errSeq {
+					t.Fatalf("Verify() error = %v, want %q at entry %d", err, tt.err, tt.errSeq)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *report != tt.want {
+				t.Fatalf("Verify() = %+v, want %+v", *report, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyResumedLog(t *testing.T) {
+	dir := t.TempDir()
+	writeLog(t, dir, 2, nil)
+	// Reopening continues the chain from the last segment
+	writeLog(t, dir, 2, nil)
+
+	report, err := Verify(dir, testKey, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Report{Segments: 1, Entries: 6, First: 1, Last: 6, Checkpoints: 2}
+	if *report != want {
+		t.Fatalf("Verify() = %+v, want %+v", *report, want)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, segmentGlob)); len(files) != 1 {
+		t.Fatalf("reopening started a new segment: %v", files)
+	}
+}
@@ -0,0 +1,131 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+	"github.com/kumarabd/policy-machine/pkg/pml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is a serialization of the policy graph
+type Format string
+
+const (
+	PML  Format = "pml"
+	JSON Format = "json"
+	YAML Format = "yaml"
+)
+
+// ParseFormat validates a format name
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case PML, JSON, YAML:
+		return f, nil
+	case "yml":
+		return YAML, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected pml, json or yaml", s)
+}
+
+// FormatFromPath infers the format from a file extension
+func FormatFromPath(path string) (Format, error) {
+	return ParseFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// ContentType returns the media type for the format
+func (f Format) ContentType() string {
+	switch f {
+	case JSON:
+		return "application/json"
+	case YAML:
+		return "application/yaml"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// Marshal renders the graph in the given format. The output is canonical:
+// identical graphs always produce identical bytes.
+func Marshal(g *model.Graph, f Format) ([]byte, error) {
+	switch f {
+	case PML:
+		out, err := pml.Format(g)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(out), nil
+	case JSON:
+		out, err := json.MarshalIndent(g.Document(), "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(out, '\n'), nil
+	case YAML:
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(g.Document()); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unknown format %q", f)
+}
+
+// Unmarshal builds a graph from data in the given format
+func Unmarshal(data []byte, f Format) (*model.Graph, error) {
+	g := model.NewGraph()
+	if err := Load(g, data, f); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Load applies the contents of data on top of g, so several files can be
+// combined into one graph
+func Load(g *model.Graph, data []byte, f Format) error {
+	var changes []model.Change
+	switch f {
+	case PML:
+		stmts, err := pml.Parse(string(data))
+		if err != nil {
+			return err
+		}
+		if changes, err = pml.Compile(g, stmts); err != nil {
+			return err
+		}
+	case JSON:
+		var doc model.Document
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&doc); err != nil {
+			return fmt.Errorf("invalid graph document: %w", err)
+		}
+		changes = doc.Changes()
+	case YAML:
+		var doc model.Document
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&doc); err != nil && err != io.EOF {
+			return fmt.Errorf("invalid graph document: %w", err)
+		}
+		changes = doc.Changes()
+	default:
+		return fmt.Errorf("unknown format %q", f)
+	}
+
+	for _, ch := range changes {
+		if err := g.Apply(ch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package gitops
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kumarabd/policy-machine/pkg/export"
+	"github.com/kumarabd/policy-machine/pkg/model"
+)
+
+// Source is the desired graph loaded from a policy directory
+type Source struct {
+	Dir   string
+	Files []string
+	// Hash covers the path and contents of every policy file
+	Hash string
+	// Commit is the checked out git commit when Dir is inside a git work tree
+	Commit string
+	Graph  *model.Graph
+}
+
+// Tags returns the provenance recorded on revisions synced from the source
+func (s *Source) Tags() map[string]string {
+	tags := map[string]string{
+		"source":      s.Dir,
+		"source_hash": s.Hash,
+	}
+	if s.Commit != "" {
+		tags["git_commit"] = s.Commit
+	}
+	return tags
+}
+
+// Files lists the .pml, .json and .yaml policy files under dir in lexical
+// path order. Hidden directories and policy test suites, files ending in
+// _test.yaml or _test.yml, are skipped.
+func Files(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(path, "_test.yaml") || strings.HasSuffix(path, "_test.yml") {
+			return nil
+		}
+		if _, err := export.FormatFromPath(path); err == nil {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy directory: %w", err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Load reads every policy file under dir in lexical path order and applies
+// them, one after another, to an empty graph
+func Load(dir string) (*Source, error) {
+	files, err := Files(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	g := model.NewGraph()
+	h := sha256.New()
+	rel := make([]string, 0, len(files))
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		name, _ := filepath.Rel(dir, path)
+		rel = append(rel, name)
+		fmt.Fprintf(h, "%s\x00%d\x00", filepath.ToSlash(name), len(data))
+		h.Write(data)
+
+		format, _ := export.FormatFromPath(path)
+		if err := export.Load(g, data, format); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return &Source{
+		Dir:    dir,
+		Files:  rel,
+		Hash:   "sha256:" + hex.EncodeToString(h.Sum(nil)),
+		Commit: gitHead(dir),
+		Graph:  g,
+	}, nil
+}
+
+// gitHead resolves the commit checked out in the work tree containing dir,
+// or returns an empty string when dir is not in a git checkout
+func gitHead(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		gitDir := filepath.Join(abs, ".git")
+		if head, err := os.ReadFile(filepath.Join(gitDir, "HEAD")); err == nil {
+			return resolveRef(gitDir, strings.TrimSpace(string(head)))
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return ""
+		}
+		abs = parent
+	}
+}
+
+func resolveRef(gitDir, head string) string {
+	ref, ok := strings.CutPrefix(head, "ref: ")
+	if !ok {
+		return head
+	}
+	if data, err := os.ReadFile(filepath.Join(gitDir, filepath.FromSlash(ref))); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+	packed, err := os.ReadFile(filepath.Join(gitDir, "packed-refs"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(packed), "\n") {
+		if sha, name, ok := strings.Cut(line, " "); ok && name == ref {
+			return sha
+		}
+	}
+	return ""
+}
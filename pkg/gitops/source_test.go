@@ -0,0 +1,88 @@
+package gitops
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, src string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "b.pml", "create UA \"staff\" in [\"pc\"]\n")
+	writeFile(t, dir, "a.pml", "create PC \"pc\"\n")
+	writeFile(t, dir, "sub/c.pml", "create U \"alice\" in [\"staff\"]\n")
+	writeFile(t, dir, "a_test.yaml", "tests: []\n")
+	writeFile(t, dir, ".hidden/d.pml", "not pml")
+	writeFile(t, dir, "notes.txt", "not policy")
+
+	src, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.pml", "b.pml", filepath.Join("sub", "c.pml")}; !reflect.DeepEqual(src.Files, want) {
+		t.Errorf("Files = %v, want %v", src.Files, want)
+	}
+	if _, ok := src.Graph.Node("alice"); !ok {
+		t.Error("alice was not loaded")
+	}
+	if src.Commit != "" {
+		t.Errorf("Commit = %q outside a git checkout", src.Commit)
+	}
+
+	// The hash changes with the contents of policy files only
+	writeFile(t, dir, "notes.txt", "edited")
+	writeFile(t, dir, "a_test.yaml", "tests: [{}]\n")
+	if again, err := Load(dir); err != nil || again.Hash != src.Hash {
+		t.Fatalf("hash %v after editing other files, want %s: %v", again, src.Hash, err)
+	}
+	writeFile(t, dir, "sub/c.pml", "create U \"bob\" in [\"staff\"]\n")
+	if again, err := Load(dir); err != nil || again.Hash == src.Hash {
+		t.Fatalf("hash unchanged after editing a policy: %v", err)
+	}
+
+	writeFile(t, dir, "b.pml", "create UA \"staff\" in [\"missing\"]\n")
+	if _, err := Load(dir); err == nil {
+		t.Error("Load() succeeded with an invalid policy")
+	}
+}
+
+func TestGitHead(t *testing.T) {
+	const sha = "0123456789abcdef0123456789abcdef01234567"
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{name: "detached", files: map[string]string{"HEAD": sha + "\n"}, want: sha},
+		{name: "loose ref", files: map[string]string{"HEAD": "ref: refs/heads/main\n", "refs/heads/main": sha + "\n"}, want: sha},
+		{name: "packed ref", files: map[string]string{"HEAD": "ref: refs/heads/main\n", "packed-refs": "# pack-refs\n" + sha + " refs/heads/main\n"}, want: sha},
+		{name: "unborn branch", files: map[string]string{"HEAD": "ref: refs/heads/main\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, src := range tt.files {
+				writeFile(t, filepath.Join(root, ".git"), name, src)
+			}
+			dir := filepath.Join(root, "policies")
+			if err := os.Mkdir(dir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if got := gitHead(dir); got != tt.want {
+				t.Fatalf("gitHead() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
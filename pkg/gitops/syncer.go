@@ -0,0 +1,232 @@
+package gitops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kumarabd/gokit/logger"
+	"github.com/kumarabd/policy-machine/pkg/model"
+	"github.com/kumarabd/policy-machine/pkg/service"
+)
+
+const defaultInterval = 10
+
+type Config struct {
+	Enabled bool   `json:"enabled" yaml:"enabled"`
+	Dir     string `json:"dir" yaml:"dir"`
+	// Interval is the number of seconds between reconciliations
+	Interval int    `json:"interval,omitempty" yaml:"interval,omitempty"`
+	Author   string `json:"author,omitempty" yaml:"author,omitempty"`
+}
+
+// Validate reports every invalid setting
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Enabled && c.Dir == "" {
+		errs = append(errs, errors.New("dir: required when sync is enabled"))
+	}
+	if c.Interval < 0 {
+		errs = append(errs, errors.New("interval: must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Store is the part of the policy service the syncer reconciles
+type Store interface {
+	Graph(ctx context.Context, revision uint64) (*model.Graph, uint64, error)
+	Replace(ctx context.Context, author, message string, tags map[string]string, g *model.Graph) (*model.Revision, error)
+	History(ctx context.Context, from, to uint64) ([]model.Revision, error)
+}
+
+// Plan is the set of changes needed to make the store match the source
+type Plan struct {
+	Dir        string         `json:"dir"`
+	SourceHash string         `json:"source_hash"`
+	Commit     string         `json:"git_commit,omitempty"`
+	Base       uint64         `json:"base_revision"`
+	Changes    []model.Change `json:"changes"`
+	// Drift is set when the store differs from the source and its latest
+	// revision was not synced, or was synced from this same source
+	Drift bool `json:"drift"`
+	// Impact is the access the changes grant and revoke, reported by Preview
+	Impact *service.Impact `json:"impact,omitempty"`
+}
+
+// Status reports the outcome of the last reconciliation
+type Status struct {
+	Dir        string    `json:"dir"`
+	LastSync   time.Time `json:"last_sync"`
+	SourceHash string    `json:"source_hash,omitempty"`
+	Commit     string    `json:"git_commit,omitempty"`
+	Revision   uint64    `json:"revision"`
+	InSync     bool      `json:"in_sync"`
+	DriftCount int       `json:"drift_count"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type Syncer struct {
+	log    *logger.Handler
+	config *Config
+	store  Store
+
+	mu     sync.Mutex
+	status Status
+}
+
+func New(l *logger.Handler, config *Config, store Store) *Syncer {
+	return &Syncer{
+		log:    l,
+		config: config,
+		store:  store,
+		status: Status{Dir: config.Dir},
+	}
+}
+
+// Plan loads the source and computes the changes without applying them
+func (s *Syncer) Plan(ctx context.Context) (*Plan, *Source, error) {
+	plan, src, _, err := s.plan(ctx)
+	return plan, src, err
+}
+
+// Preview plans a sync and reports the access it would grant and revoke,
+// as a dry run does
+func (s *Syncer) Preview(ctx context.Context, f service.ImpactFilter) (*Plan, error) {
+	plan, src, current, err := s.plan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	plan.Impact = service.AccessImpact(current, src.Graph, f)
+	return plan, nil
+}
+
+func (s *Syncer) plan(ctx context.Context) (*Plan, *Source, *model.Graph, error) {
+	src, err := Load(s.config.Dir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	current, rev, err := s.store.Graph(ctx, 0)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	drift, err := s.drifted(ctx, rev, src)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	changes := model.Diff(current, src.Graph)
+	return &Plan{
+		Dir:        src.Dir,
+		SourceHash: src.Hash,
+		Commit:     src.Commit,
+		Base:       rev,
+		Changes:    changes,
+		Drift:      drift && len(changes) > 0,
+	}, src, current, nil
+}
+
+// drifted tells drift from source changes by the source_hash tag of the
+// store's latest revision, which is kept in the store and so survives
+// restarts and syncs by other processes. A revision without the tag was
+// committed outside of the sync; one tagged with the current hash should
+// already match the source.
+func (s *Syncer) drifted(ctx context.Context, rev uint64, src *Source) (bool, error) {
+	if rev == 0 {
+		return false, nil
+	}
+	revs, err := s.store.History(ctx, rev-1, rev)
+	if err != nil {
+		return false, err
+	}
+	if len(revs) == 0 {
+		return false, nil
+	}
+	synced := revs[0].Tags["source_hash"]
+	return synced == "" || synced == src.Hash, nil
+}
+
+// Sync reconciles the store to the source in a single revision tagged with
+// the source hash. The returned revision is nil when nothing changed.
+func (s *Syncer) Sync(ctx context.Context) (*Plan, *model.Revision, error) {
+	plan, src, err := s.Plan(ctx)
+	if err != nil {
+		s.record(func(st *Status) { st.Error = err.Error() })
+		return nil, nil, err
+	}
+	if plan.Drift {
+		s.log.Warn().Str("dir", plan.Dir).Int("changes", len(plan.Changes)).Uint64("revision", plan.Base).Msg("policy drift detected, reconciling to source")
+	}
+
+	var rev *model.Revision
+	if len(plan.Changes) > 0 {
+		author := s.config.Author
+		if author == "" {
+			author = "gitops"
+		}
+		message := fmt.Sprintf("sync %s (%s)", src.Dir, src.Hash)
+		if rev, err = s.store.Replace(ctx, author, message, src.Tags(), src.Graph); err != nil {
+			s.record(func(st *Status) { st.Error = err.Error() })
+			return plan, nil, err
+		}
+	}
+
+	applied := plan.Base
+	if rev != nil {
+		applied = rev.Number
+	}
+	s.record(func(st *Status) {
+		st.LastSync = time.Now().UTC()
+		st.SourceHash = src.Hash
+		st.Commit = src.Commit
+		st.Revision = applied
+		st.InSync = true
+		st.Error = ""
+		if plan.Drift {
+			st.DriftCount++
+		}
+	})
+	return plan, rev, nil
+}
+
+// Run reconciles on every interval until the context is done
+func (s *Syncer) Run(ctx context.Context) {
+	interval := s.config.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		if _, rev, err := s.Sync(ctx); err != nil {
+			s.log.Error().Err(err).Str("dir", s.config.Dir).Msg("policy sync failed")
+		} else if rev != nil {
+			s.log.Info().Str("dir", s.config.Dir).Uint64("revision", rev.Number).Int("changes", len(rev.Changes)).Msg("policy synced from source")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the outcome of the last reconciliation
+func (s *Syncer) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+func (s *Syncer) record(fn func(*Status)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.status)
+	if s.status.Error != "" {
+		s.status.InSync = false
+	}
+}
@@ -0,0 +1,124 @@
+package gitops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kumarabd/gokit/logger"
+	"github.com/kumarabd/policy-machine/internal/metrics"
+	"github.com/kumarabd/policy-machine/pkg/pml"
+	"github.com/kumarabd/policy-machine/pkg/service"
+	"github.com/kumarabd/policy-machine/pkg/store"
+	"github.com/rs/zerolog"
+)
+
+func newTestSyncer(t *testing.T, dir string) (*Syncer, *service.Handler) {
+	t.Helper()
+	l := &logger.Handler{Logger: zerolog.Nop()}
+	m, err := metrics.New("test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := store.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := service.New(l, m, st, &service.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(l, &Config{Enabled: true, Dir: dir}, svc), svc
+}
+
+func TestSync(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "policy.pml", "create PC \"pc\"\ncreate UA \"staff\" in [\"pc\"]\n")
+	s, svc := newTestSyncer(t, dir)
+	ctx := context.Background()
+
+	type step struct {
+		name string
+		// edit changes the source, outside commits to the store
+		edit, outside string
+		changed       bool
+		drift         bool
+		driftCount    int
+	}
+	steps := []step{
+		{name: "initial sync", changed: true},
+		{name: "in sync"},
+		{name: "source changed", edit: "create PC \"pc\"\ncreate UA \"staff\" in [\"pc\"]\ncreate U \"alice\" in [\"staff\"]\n", changed: true},
+		{name: "outside change reverted", outside: "create U \"mallory\" in [\"staff\"]", changed: true, drift: true, driftCount: 1},
+		{name: "in sync after drift", driftCount: 1},
+	}
+	for _, st := range steps {
+		if st.edit != "" {
+			writeFile(t, dir, "policy.pml", st.edit)
+		}
+		if st.outside != "" {
+			if _, err := pml.Execute(ctx, svc, "someone", "manual", st.outside); err != nil {
+				t.Fatal(err)
+			}
+		}
+		plan, rev, err := s.Sync(ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", st.name, err)
+		}
+		if (rev != nil) != st.changed || plan.Drift != st.drift {
+			t.Fatalf("%s: revision %v and drift %v, want changed %v and drift %v", st.name, rev, plan.Drift, st.changed, st.drift)
+		}
+		src, err := Load(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rev != nil && rev.Tags["source_hash"] != src.Hash {
+			t.Errorf("%s: revision tags %v, want source_hash %s", st.name, rev.Tags, src.Hash)
+		}
+		status := s.Status()
+		if !status.InSync || status.SourceHash != src.Hash || status.DriftCount != st.driftCount {
+			t.Errorf("%s: status %+v", st.name, status)
+		}
+	}
+
+	g, head, err := svc.Graph(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.Node("mallory"); ok {
+		t.Error("outside change was not reverted")
+	}
+
+	// A broken source leaves the store alone and is reported in the status
+	writeFile(t, dir, "policy.pml", "create U \"bob\" in [\"missing\"]\n")
+	if _, _, err := s.Sync(ctx); err == nil {
+		t.Fatal("Sync() succeeded with an invalid source")
+	}
+	if status := s.Status(); status.InSync || status.Error == "" {
+		t.Errorf("status %+v, want the error", status)
+	}
+	if _, rev, err := svc.Graph(ctx, 0); err != nil || rev != head {
+		t.Errorf("store at revision %d after a failed sync: %v", rev, err)
+	}
+}
+
+func TestPreview(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "policy.pml", `create PC "pc"
+create UA "staff" in ["pc"]
+create U "alice" in ["staff"]
+create OA "records" in ["pc"]
+create O "rec_1" in ["records"]
+associate "staff" and "records" with ["read"]
+`)
+	s, svc := newTestSyncer(t, dir)
+	plan, err := s.Preview(context.Background(), service.ImpactFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Changes) == 0 || plan.Impact == nil || len(plan.Impact.Gained) == 0 {
+		t.Fatalf("plan %+v, want changes granting access", plan)
+	}
+	if _, rev, _ := svc.Graph(context.Background(), 0); rev != 0 {
+		t.Errorf("Preview() committed revision %d", rev)
+	}
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Constraints returns all separation of duty constraints ordered by name
+func (g *Graph) Constraints() []Constraint {
+	out := make([]Constraint, 0, len(g.constraints))
+	for _, c := range g.constraints {
+		out = append(out, *copyConstraint(c))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// CheckConstraints verifies no user is a member of more than one attribute
+// of a static constraint. It returns a *ConstraintError for the first
+// violation, checking constraints and users in name order.
+func (g *Graph) CheckConstraints() error {
+	for _, c := range g.Constraints() {
+		if c.Type != StaticSoD {
+			continue
+		}
+		members := map[string][]string{}
+		for _, attr := range c.Attributes {
+			for name := range g.Descendants(attr) {
+				if g.nodes[name].Type == User {
+					members[name] = append(members[name], attr)
+				}
+			}
+		}
+		users := make([]string, 0, len(members))
+		for u, attrs := range members {
+			if len(attrs) > 1 {
+				users = append(users, u)
+			}
+		}
+		if len(users) == 0 {
+			continue
+		}
+		sort.Strings(users)
+		return &ConstraintError{Constraint: c.Name, User: users[0], Attributes: members[users[0]]}
+	}
+	return nil
+}
+
+func (g *Graph) createConstraint(c Constraint) error {
+	if c.Name == "" {
+		return fmt.Errorf("constraint name is required")
+	}
+	if _, ok := g.constraints[c.Name]; ok {
+		return fmt.Errorf("constraint %q already exists", c.Name)
+	}
+	switch c.Type {
+	case StaticSoD:
+		if len(c.Operations) > 0 || len(c.Containers) > 0 {
+			return fmt.Errorf("constraint %q: static constraints take only attributes", c.Name)
+		}
+		c.Attributes = normalize(c.Attributes)
+		if len(c.Attributes) < 2 {
+			return fmt.Errorf("constraint %q must separate at least two user attributes", c.Name)
+		}
+		for _, a := range c.Attributes {
+			n, ok := g.nodes[a]
+			if !ok {
+				return fmt.Errorf("constraint %q: attribute %q does not exist", c.Name, a)
+			}
+			if n.Type != UserAttribute {
+				return fmt.Errorf("constraint %q: %q must be a user attribute", c.Name, a)
+			}
+		}
+	case DynamicSoD:
+		if len(c.Attributes) > 0 {
+			return fmt.Errorf("constraint %q: dynamic constraints take operations, not attributes", c.Name)
+		}
+		c.Operations = normalize(c.Operations)
+		if len(c.Operations) < 2 {
+			return fmt.Errorf("constraint %q must separate at least two operations", c.Name)
+		}
+		c.Containers = normalize(c.Containers)
+		for _, name := range c.Containers {
+			n, ok := g.nodes[name]
+			if !ok {
+				return fmt.Errorf("constraint %q: container %q does not exist", c.Name, name)
+			}
+			if n.Type != ObjectAttribute && n.Type != PolicyClass {
+				return fmt.Errorf("constraint %q: container %q must be an object attribute or policy class", c.Name, name)
+			}
+		}
+	default:
+		return fmt.Errorf("constraint %q: unknown type %q", c.Name, c.Type)
+	}
+	g.constraints[c.Name] = copyConstraint(&c)
+	return nil
+}
+
+func (g *Graph) deleteConstraint(name string) error {
+	if _, ok := g.constraints[name]; !ok {
+		return fmt.Errorf("constraint %q does not exist", name)
+	}
+	delete(g.constraints, name)
+	return nil
+}
+
+// nodes lists the nodes the constraint refers to
+func (c *Constraint) nodes() []string {
+	return append(append([]string(nil), c.Attributes...), c.Containers...)
+}
+
+func copyConstraint(c *Constraint) *Constraint {
+	out := *c
+	out.Attributes = append([]string(nil), c.Attributes...)
+	out.Operations = append([]string(nil), c.Operations...)
+	out.Containers = append([]string(nil), c.Containers...)
+	return &out
+}
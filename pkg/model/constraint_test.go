@@ -0,0 +1,96 @@
+package model
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckConstraints(t *testing.T) {
+	tests := []struct {
+		name   string
+		change []Change
+		want   *ConstraintError
+	}{
+		{name: "members of one attribute"},
+		{
+			name:   "direct member of both",
+			change: []Change{{Op: OpAssign, Assignment: &Assignment{Child: "alice", Parent: "intern"}}},
+			want:   &ConstraintError{Constraint: "sod", User: "alice", Attributes: []string{"doctor", "intern"}},
+		},
+		{
+			name: "member through a nested attribute",
+			change: append(createNode("resident", UserAttribute, "doctor", "intern"),
+				createNode("bob", User, "resident")...),
+			want: &ConstraintError{Constraint: "sod", User: "bob", Attributes: []string{"doctor", "intern"}},
+		},
+		{
+			name:   "first user by name",
+			change: append(createNode("zed", User, "doctor", "intern"), Change{Op: OpAssign, Assignment: &Assignment{Child: "alice", Parent: "intern"}}),
+			want:   &ConstraintError{Constraint: "sod", User: "alice", Attributes: []string{"doctor", "intern"}},
+		},
+		{
+			name:   "attributes outside the constraint",
+			change: createNode("carol", User, "doctor", "staff"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := hospital(t)
+			// Changes apply one at a time; the constraints hold for the result
+			apply(t, g, tt.change)
+			err := g.CheckConstraints()
+			if tt.want == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var got *ConstraintError
+			if !errors.As(err, &got) {
+				t.Fatalf("CheckConstraints() = %v, want %v", err, tt.want)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("CheckConstraints() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateConstraint(t *testing.T) {
+	tests := []struct {
+		name       string
+		constraint Constraint
+		err        string
+	}{
+		{name: "static", constraint: Constraint{Name: "c", Type: StaticSoD, Attributes: []string{"intern", "doctor"}}},
+		{name: "dynamic anywhere", constraint: Constraint{Name: "c", Type: DynamicSoD, Operations: []string{"submit", "approve"}}},
+		{name: "dynamic on a policy class", constraint: Constraint{Name: "c", Type: DynamicSoD, Operations: []string{"submit", "approve"}, Containers: []string{"hospital"}}},
+		{name: "no name", constraint: Constraint{Type: StaticSoD, Attributes: []string{"intern", "doctor"}}, err: "constraint name is required"},
+		{name: "duplicate", constraint: Constraint{Name: "sod", Type: StaticSoD, Attributes: []string{"intern", "doctor"}}, err: `constraint "sod" already exists`},
+		{name: "unknown type", constraint: Constraint{Name: "c", Type: "other"}, err: `unknown type "other"`},
+		{name: "one attribute twice", constraint: Constraint{Name: "c", Type: StaticSoD, Attributes: []string{"intern", "intern"}}, err: "at least two user attributes"},
+		{name: "static with operations", constraint: Constraint{Name: "c", Type: StaticSoD, Attributes: []string{"intern", "doctor"}, Operations: []string{"a"}}, err: "static constraints take only attributes"},
+		{name: "missing attribute", constraint: Constraint{Name: "c", Type: StaticSoD, Attributes: []string{"intern", "nurse"}}, err: `attribute "nurse" does not exist`},
+		{name: "object attribute", constraint: Constraint{Name: "c", Type: StaticSoD, Attributes: []string{"intern", "records"}}, err: `"records" must be a user attribute`},
+		{name: "one operation", constraint: Constraint{Name: "c", Type: DynamicSoD, Operations: []string{"submit", ""}}, err: "at least two operations"},
+		{name: "dynamic with attributes", constraint: Constraint{Name: "c", Type: DynamicSoD, Operations: []string{"a", "b"}, Attributes: []string{"intern"}}, err: "take operations, not attributes"},
+		{name: "object container", constraint: Constraint{Name: "c", Type: DynamicSoD, Operations: []string{"a", "b"}, Containers: []string{"rec"}}, err: `container "rec" must be an object attribute or policy class`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := hospital(t)
+			err := g.Apply(Change{Op: OpCreateConstraint, Constraint: &tt.constraint})
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Apply() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
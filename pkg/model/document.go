@@ -0,0 +1,122 @@
+package model
+
+import "fmt"
+
+// Document is the serialized form of a graph. Every list is sorted, so equal
+// graphs produce identical documents.
+type Document struct {
+	Nodes        []Node        `json:"nodes" yaml:"nodes"`
+	Assignments  []Assignment  `json:"assignments" yaml:"assignments"`
+	Associations []Association `json:"associations" yaml:"associations"`
+	Prohibitions []Prohibition `json:"prohibitions" yaml:"prohibitions"`
+	Obligations  []Obligation  `json:"obligations" yaml:"obligations"`
+	Constraints  []Constraint  `json:"constraints,omitempty" yaml:"constraints,omitempty"`
+}
+
+// Document returns the canonical serialized form of the graph
+func (g *Graph) Document() Document {
+	return Document{
+		Nodes:        g.Nodes(),
+		Assignments:  g.Assignments(),
+		Associations: g.Associations(),
+		Prohibitions: g.Prohibitions(),
+		Obligations:  g.Obligations(),
+		Constraints:  g.Constraints(),
+	}
+}
+
+// FromDocument builds a graph from its serialized form
+func FromDocument(doc Document) (*Graph, error) {
+	g := NewGraph()
+	for _, ch := range doc.Changes() {
+		if err := g.Apply(ch); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// Changes returns the changes that build the document's graph from empty
+func (doc Document) Changes() []Change {
+	var out []Change
+	for i := range doc.Nodes {
+		out = append(out, Change{Op: OpCreateNode, Node: &doc.Nodes[i]})
+	}
+	for i := range doc.Assignments {
+		out = append(out, Change{Op: OpAssign, Assignment: &doc.Assignments[i]})
+	}
+	for i := range doc.Associations {
+		out = append(out, Change{Op: OpAssociate, Association: &doc.Associations[i]})
+	}
+	for i := range doc.Prohibitions {
+		out = append(out, Change{Op: OpCreateProhibition, Prohibition: &doc.Prohibitions[i]})
+	}
+	for i := range doc.Obligations {
+		out = append(out, Change{Op: OpCreateObligation, Obligation: &doc.Obligations[i]})
+	}
+	for i := range doc.Constraints {
+		out = append(out, Change{Op: OpCreateConstraint, Constraint: &doc.Constraints[i]})
+	}
+	return out
+}
+
+// Subgraph returns the part of the graph rooted at a policy class: the policy
+// class, every node contained in it, and the relations among those nodes
+func (g *Graph) Subgraph(pc string) (*Graph, error) {
+	n, ok := g.nodes[pc]
+	if !ok {
+		return nil, fmt.Errorf("node %q does not exist", pc)
+	}
+	if n.Type != PolicyClass {
+		return nil, fmt.Errorf("node %q is a %s, not a policy class", pc, n.Type)
+	}
+
+	keep := g.Descendants(pc)
+	keep[pc] = struct{}{}
+	in := func(names ...string) bool {
+		for _, name := range names {
+			if _, ok := keep[name]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+
+	sub := NewGraph()
+	for name := range keep {
+		sub.nodes[name] = copyNode(g.nodes[name])
+	}
+	for child, ps := range g.parents {
+		for p := range ps {
+			if in(child, p) {
+				sub.link(child, p)
+			}
+		}
+	}
+	for ua, targets := range g.associations {
+		for t, ops := range targets {
+			if in(ua, t) {
+				if sub.associations[ua] == nil {
+					sub.associations[ua] = make(map[string][]string)
+				}
+				sub.associations[ua][t] = append([]string(nil), ops...)
+			}
+		}
+	}
+	for name, p := range g.prohibitions {
+		if in(p.Subject) && in(p.Containers...) {
+			sub.prohibitions[name] = copyProhibition(p)
+		}
+	}
+	for name, o := range g.obligations {
+		if in(o.Subject, o.Target) {
+			sub.obligations[name] = copyObligation(o)
+		}
+	}
+	for name, c := range g.constraints {
+		if in(c.nodes()...) {
+			sub.constraints[name] = copyConstraint(c)
+		}
+	}
+	return sub, nil
+}
@@ -0,0 +1,735 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// allowedAssignments lists the parent types each node type may be assigned to
+var allowedAssignments = map[NodeType][]NodeType{
+	User:            {UserAttribute},
+	UserAttribute:   {UserAttribute, PolicyClass},
+	Object:          {ObjectAttribute},
+	ObjectAttribute: {ObjectAttribute, PolicyClass},
+}
+
+// typeOrder is the order in which node types are created when replaying a graph
+var typeOrder = map[NodeType]int{
+	PolicyClass:     0,
+	UserAttribute:   1,
+	ObjectAttribute: 2,
+	User:            3,
+	Object:          4,
+}
+
+// Graph is an NGAC policy graph. It is not safe for concurrent use.
+type Graph struct {
+	nodes        map[string]*Node
+	parents      map[string]map[string]struct{}
+	children     map[string]map[string]struct{}
+	associations map[string]map[string][]string
+	prohibitions map[string]*Prohibition
+	obligations  map[string]*Obligation
+	constraints  map[string]*Constraint
+}
+
+// NewGraph creates an empty graph
+func NewGraph() *Graph {
+	return &Graph{
+		nodes:        make(map[string]*Node),
+		parents:      make(map[string]map[string]struct{}),
+		children:     make(map[string]map[string]struct{}),
+		associations: make(map[string]map[string][]string),
+		prohibitions: make(map[string]*Prohibition),
+		obligations:  make(map[string]*Obligation),
+		constraints:  make(map[string]*Constraint),
+	}
+}
+
+// Clone returns a deep copy of the graph
+func (g *Graph) Clone() *Graph {
+	c := NewGraph()
+	for name, n := range g.nodes {
+		c.nodes[name] = copyNode(n)
+	}
+	for child, ps := range g.parents {
+		for p := range ps {
+			c.link(child, p)
+		}
+	}
+	for ua, targets := range g.associations {
+		c.associations[ua] = make(map[string][]string, len(targets))
+		for t, ops := range targets {
+			c.associations[ua][t] = append([]string(nil), ops...)
+		}
+	}
+	for name, p := range g.prohibitions {
+		c.prohibitions[name] = copyProhibition(p)
+	}
+	for name, o := range g.obligations {
+		c.obligations[name] = copyObligation(o)
+	}
+	for name, con := range g.constraints {
+		c.constraints[name] = copyConstraint(con)
+	}
+	return c
+}
+
+// Apply validates and applies a single change
+func (g *Graph) Apply(ch Change) error {
+	switch ch.Op {
+	case OpCreateNode:
+		if ch.Node == nil {
+			return fmt.Errorf("%s: missing node", ch.Op)
+		}
+		return g.createNode(*ch.Node)
+	case OpUpdateNode:
+		if ch.Node == nil {
+			return fmt.Errorf("%s: missing node", ch.Op)
+		}
+		return g.updateNode(*ch.Node)
+	case OpDeleteNode:
+		if ch.Node == nil {
+			return fmt.Errorf("%s: missing node", ch.Op)
+		}
+		return g.deleteNode(ch.Node.Name)
+	case OpAssign:
+		if ch.Assignment == nil {
+			return fmt.Errorf("%s: missing assignment", ch.Op)
+		}
+		return g.assign(ch.Assignment.Child, ch.Assignment.Parent)
+	case OpDeassign:
+		if ch.Assignment == nil {
+			return fmt.Errorf("%s: missing assignment", ch.Op)
+		}
+		return g.deassign(ch.Assignment.Child, ch.Assignment.Parent)
+	case OpAssociate:
+		if ch.Association == nil {
+			return fmt.Errorf("%s: missing association", ch.Op)
+		}
+		return g.associate(*ch.Association)
+	case OpDissociate:
+		if ch.Association == nil {
+			return fmt.Errorf("%s: missing association", ch.Op)
+		}
+		return g.dissociate(ch.Association.UA, ch.Association.Target)
+	case OpCreateProhibition:
+		if ch.Prohibition == nil {
+			return fmt.Errorf("%s: missing prohibition", ch.Op)
+		}
+		return g.createProhibition(*ch.Prohibition)
+	case OpDeleteProhibition:
+		if ch.Prohibition == nil {
+			return fmt.Errorf("%s: missing prohibition", ch.Op)
+		}
+		return g.deleteProhibition(ch.Prohibition.Name)
+	case OpCreateObligation:
+		if ch.Obligation == nil {
+			return fmt.Errorf("%s: missing obligation", ch.Op)
+		}
+		return g.createObligation(*ch.Obligation)
+	case OpDeleteObligation:
+		if ch.Obligation == nil {
+			return fmt.Errorf("%s: missing obligation", ch.Op)
+		}
+		return g.deleteObligation(ch.Obligation.Name)
+	case OpCreateConstraint:
+		if ch.Constraint == nil {
+			return fmt.Errorf("%s: missing constraint", ch.Op)
+		}
+		return g.createConstraint(*ch.Constraint)
+	case OpDeleteConstraint:
+		if ch.Constraint == nil {
+			return fmt.Errorf("%s: missing constraint", ch.Op)
+		}
+		return g.deleteConstraint(ch.Constraint.Name)
+	default:
+		return fmt.Errorf("unknown change op %q", ch.Op)
+	}
+}
+
+// Node returns the named node
+func (g *Graph) Node(name string) (*Node, bool) {
+	n, ok := g.nodes[name]
+	if !ok {
+		return nil, false
+	}
+	return copyNode(n), true
+}
+
+// Nodes returns all nodes ordered by type and name
+func (g *Graph) Nodes() []Node {
+	out := make([]Node, 0, len(g.nodes))
+	for _, n := range g.nodes {
+		out = append(out, *copyNode(n))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Type != out[j].Type {
+			return typeOrder[out[i].Type] < typeOrder[out[j].Type]
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// Assignments returns all assignments ordered by child and parent
+func (g *Graph) Assignments() []Assignment {
+	out := []Assignment{}
+	for child, ps := range g.parents {
+		for p := range ps {
+			out = append(out, Assignment{Child: child, Parent: p})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Child != out[j].Child {
+			return out[i].Child < out[j].Child
+		}
+		return out[i].Parent < out[j].Parent
+	})
+	return out
+}
+
+// Associations returns all associations ordered by user attribute and target
+func (g *Graph) Associations() []Association {
+	out := []Association{}
+	for ua, targets := range g.associations {
+		for t, ops := range targets {
+			out = append(out, Association{UA: ua, Target: t, Operations: append([]string(nil), ops...)})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].UA != out[j].UA {
+			return out[i].UA < out[j].UA
+		}
+		return out[i].Target < out[j].Target
+	})
+	return out
+}
+
+// Prohibitions returns all prohibitions ordered by name
+func (g *Graph) Prohibitions() []Prohibition {
+	out := make([]Prohibition, 0, len(g.prohibitions))
+	for _, p := range g.prohibitions {
+		out = append(out, *copyProhibition(p))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Obligations returns all obligations ordered by name
+func (g *Graph) Obligations() []Obligation {
+	out := make([]Obligation, 0, len(g.obligations))
+	for _, o := range g.obligations {
+		out = append(out, *copyObligation(o))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Parents returns the direct parents of a node
+func (g *Graph) Parents(name string) []string {
+	return sortedKeys(g.parents[name])
+}
+
+// Children returns the direct children of a node
+func (g *Graph) Children(name string) []string {
+	return sortedKeys(g.children[name])
+}
+
+// Ancestors returns every container the node is transitively assigned to
+func (g *Graph) Ancestors(name string) map[string]struct{} {
+	seen := make(map[string]struct{})
+	stack := []string{name}
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for p := range g.parents[cur] {
+			if _, ok := seen[p]; ok {
+				continue
+			}
+			seen[p] = struct{}{}
+			stack = append(stack, p)
+		}
+	}
+	return seen
+}
+
+// Descendants returns every node transitively assigned to the container
+func (g *Graph) Descendants(name string) map[string]struct{} {
+	seen := make(map[string]struct{})
+	stack := []string{name}
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for c := range g.children[cur] {
+			if _, ok := seen[c]; ok {
+				continue
+			}
+			seen[c] = struct{}{}
+			stack = append(stack, c)
+		}
+	}
+	return seen
+}
+
+// Path returns the shortest chain of assignments from a node up to one of its
+// ancestors, including both ends, or nil when the ancestor is not reachable
+func (g *Graph) Path(from, to string) []string {
+	if from == to {
+		return []string{from}
+	}
+	prev := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, p := range g.Parents(cur) {
+			if _, ok := prev[p]; ok {
+				continue
+			}
+			prev[p] = cur
+			if p == to {
+				path := []string{to}
+				for n := cur; n != ""; n = prev[n] {
+					path = append([]string{n}, path...)
+				}
+				return path
+			}
+			queue = append(queue, p)
+		}
+	}
+	return nil
+}
+
+// HasOperation reports whether any association grants the operation
+func (g *Graph) HasOperation(op string) bool {
+	for _, targets := range g.associations {
+		for _, ops := range targets {
+			for _, o := range ops {
+				if o == op {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// AssociationsFrom returns the targets and operations granted to a user attribute
+func (g *Graph) AssociationsFrom(ua string) map[string][]string {
+	out := make(map[string][]string, len(g.associations[ua]))
+	for t, ops := range g.associations[ua] {
+		out[t] = append([]string(nil), ops...)
+	}
+	return out
+}
+
+func (g *Graph) createNode(n Node) error {
+	if n.Name == "" {
+		return fmt.Errorf("node name is required")
+	}
+	if _, ok := typeOrder[n.Type]; !ok {
+		return fmt.Errorf("node %q: unknown type %q", n.Name, n.Type)
+	}
+	if _, ok := g.nodes[n.Name]; ok {
+		return fmt.Errorf("node %q already exists", n.Name)
+	}
+	g.nodes[n.Name] = copyNode(&n)
+	return nil
+}
+
+func (g *Graph) updateNode(n Node) error {
+	cur, ok := g.nodes[n.Name]
+	if !ok {
+		return fmt.Errorf("node %q does not exist", n.Name)
+	}
+	if n.Type != "" && n.Type != cur.Type {
+		return fmt.Errorf("node %q: type cannot change from %s to %s", n.Name, cur.Type, n.Type)
+	}
+	cur.Properties = copyNode(&n).Properties
+	return nil
+}
+
+func (g *Graph) deleteNode(name string) error {
+	if _, ok := g.nodes[name]; !ok {
+		return fmt.Errorf("node %q does not exist", name)
+	}
+	if len(g.children[name]) > 0 {
+		return fmt.Errorf("node %q still has %d assigned children", name, len(g.children[name]))
+	}
+	for _, p := range g.prohibitions {
+		if p.Subject == name || contains(p.Containers, name) {
+			return fmt.Errorf("node %q is referenced by prohibition %q", name, p.Name)
+		}
+	}
+	for _, o := range g.obligations {
+		if o.Subject == name || o.Target == name {
+			return fmt.Errorf("node %q is referenced by obligation %q", name, o.Name)
+		}
+	}
+	for _, c := range g.constraints {
+		if contains(c.Attributes, name) || contains(c.Containers, name) {
+			return fmt.Errorf("node %q is referenced by constraint %q", name, c.Name)
+		}
+	}
+	for p := range g.parents[name] {
+		g.unlink(name, p)
+	}
+	delete(g.associations, name)
+	for _, targets := range g.associations {
+		delete(targets, name)
+	}
+	delete(g.nodes, name)
+	return nil
+}
+
+func (g *Graph) assign(child, parent string) error {
+	c, ok := g.nodes[child]
+	if !ok {
+		return fmt.Errorf("node %q does not exist", child)
+	}
+	p, ok := g.nodes[parent]
+	if !ok {
+		return fmt.Errorf("node %q does not exist", parent)
+	}
+	allowed := false
+	for _, t := range allowedAssignments[c.Type] {
+		if t == p.Type {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("cannot assign %s %q to %s %q", c.Type, child, p.Type, parent)
+	}
+	if _, ok := g.parents[child][parent]; ok {
+		return fmt.Errorf("%q is already assigned to %q", child, parent)
+	}
+	if child == parent {
+		return fmt.Errorf("cannot assign %q to itself", child)
+	}
+	if _, ok := g.Ancestors(parent)[child]; ok {
+		return fmt.Errorf("assigning %q to %q would create a cycle", child, parent)
+	}
+	g.link(child, parent)
+	return nil
+}
+
+func (g *Graph) deassign(child, parent string) error {
+	if _, ok := g.parents[child][parent]; !ok {
+		return fmt.Errorf("%q is not assigned to %q", child, parent)
+	}
+	g.unlink(child, parent)
+	return nil
+}
+
+func (g *Graph) associate(a Association) error {
+	ua, ok := g.nodes[a.UA]
+	if !ok {
+		return fmt.Errorf("node %q does not exist", a.UA)
+	}
+	if ua.Type != UserAttribute {
+		return fmt.Errorf("association source %q must be a user attribute, got %s", a.UA, ua.Type)
+	}
+	t, ok := g.nodes[a.Target]
+	if !ok {
+		return fmt.Errorf("node %q does not exist", a.Target)
+	}
+	if t.Type == PolicyClass || t.Type == User {
+		return fmt.Errorf("association target %q cannot be a %s", a.Target, t.Type)
+	}
+	ops := normalize(a.Operations)
+	if len(ops) == 0 {
+		return fmt.Errorf("association %q -> %q has no operations", a.UA, a.Target)
+	}
+	if g.associations[a.UA] == nil {
+		g.associations[a.UA] = make(map[string][]string)
+	}
+	g.associations[a.UA][a.Target] = ops
+	return nil
+}
+
+func (g *Graph) dissociate(ua, target string) error {
+	if _, ok := g.associations[ua][target]; !ok {
+		return fmt.Errorf("%q is not associated with %q", ua, target)
+	}
+	delete(g.associations[ua], target)
+	if len(g.associations[ua]) == 0 {
+		delete(g.associations, ua)
+	}
+	return nil
+}
+
+func (g *Graph) createProhibition(p Prohibition) error {
+	if p.Name == "" {
+		return fmt.Errorf("prohibition name is required")
+	}
+	if _, ok := g.prohibitions[p.Name]; ok {
+		return fmt.Errorf("prohibition %q already exists", p.Name)
+	}
+	s, ok := g.nodes[p.Subject]
+	if !ok {
+		return fmt.Errorf("prohibition %q: subject %q does not exist", p.Name, p.Subject)
+	}
+	if s.Type != User && s.Type != UserAttribute {
+		return fmt.Errorf("prohibition %q: subject %q must be a user or user attribute", p.Name, p.Subject)
+	}
+	if len(p.Containers) == 0 {
+		return fmt.Errorf("prohibition %q has no containers", p.Name)
+	}
+	for _, c := range p.Containers {
+		if _, ok := g.nodes[c]; !ok {
+			return fmt.Errorf("prohibition %q: container %q does not exist", p.Name, c)
+		}
+	}
+	p.Containers = normalize(p.Containers)
+	p.Operations = normalize(p.Operations)
+	if len(p.Operations) == 0 {
+		return fmt.Errorf("prohibition %q has no operations", p.Name)
+	}
+	g.prohibitions[p.Name] = copyProhibition(&p)
+	return nil
+}
+
+func (g *Graph) deleteProhibition(name string) error {
+	if _, ok := g.prohibitions[name]; !ok {
+		return fmt.Errorf("prohibition %q does not exist", name)
+	}
+	delete(g.prohibitions, name)
+	return nil
+}
+
+// checkResponse limits obligation responses to a string type and fields
+// that are strings, numbers, booleans or lists of strings, which every
+// policy format can express
+func checkResponse(response map[string]interface{}) error {
+	if _, ok := response["type"].(string); !ok {
+		return fmt.Errorf("response type must be a string")
+	}
+	for k, v := range response {
+		switch val := v.(type) {
+		case string, float64, bool:
+		case []interface{}:
+			for _, item := range val {
+				if _, ok := item.(string); !ok {
+					return fmt.Errorf("response field %q must contain only strings", k)
+				}
+			}
+		default:
+			return fmt.Errorf("response field %q must be a string, number, boolean or list of strings", k)
+		}
+	}
+	return nil
+}
+
+func (g *Graph) createObligation(o Obligation) error {
+	if o.Name == "" {
+		return fmt.Errorf("obligation name is required")
+	}
+	if _, ok := g.obligations[o.Name]; ok {
+		return fmt.Errorf("obligation %q already exists", o.Name)
+	}
+	if _, ok := g.nodes[o.Subject]; !ok {
+		return fmt.Errorf("obligation %q: subject %q does not exist", o.Name, o.Subject)
+	}
+	if _, ok := g.nodes[o.Target]; !ok {
+		return fmt.Errorf("obligation %q: target %q does not exist", o.Name, o.Target)
+	}
+	o.Operations = normalize(o.Operations)
+	if len(o.Operations) == 0 {
+		return fmt.Errorf("obligation %q has no operations", o.Name)
+	}
+	if _, ok := o.Response["type"]; !ok {
+		return fmt.Errorf("obligation %q: response has no type", o.Name)
+	}
+	// Round-trip the response through JSON so equal responses compare equal
+	// regardless of whether they were decoded or built in code
+	raw, err := json.Marshal(o.Response)
+	if err != nil {
+		return fmt.Errorf("obligation %q: invalid response: %w", o.Name, err)
+	}
+	o.Response = nil
+	if err := json.Unmarshal(raw, &o.Response); err != nil {
+		return fmt.Errorf("obligation %q: invalid response: %w", o.Name, err)
+	}
+	if err := checkResponse(o.Response); err != nil {
+		return fmt.Errorf("obligation %q: %w", o.Name, err)
+	}
+	g.obligations[o.Name] = copyObligation(&o)
+	return nil
+}
+
+func (g *Graph) deleteObligation(name string) error {
+	if _, ok := g.obligations[name]; !ok {
+		return fmt.Errorf("obligation %q does not exist", name)
+	}
+	delete(g.obligations, name)
+	return nil
+}
+
+func (g *Graph) link(child, parent string) {
+	if g.parents[child] == nil {
+		g.parents[child] = make(map[string]struct{})
+	}
+	if g.children[parent] == nil {
+		g.children[parent] = make(map[string]struct{})
+	}
+	g.parents[child][parent] = struct{}{}
+	g.children[parent][child] = struct{}{}
+}
+
+func (g *Graph) unlink(child, parent string) {
+	delete(g.parents[child], parent)
+	if len(g.parents[child]) == 0 {
+		delete(g.parents, child)
+	}
+	delete(g.children[parent], child)
+	if len(g.children[parent]) == 0 {
+		delete(g.children, parent)
+	}
+}
+
+// Diff returns the changes that transform graph a into graph b.
+// Applying them in order to a clone of a yields a graph equal to b.
+func Diff(a, b *Graph) []Change {
+	var changes []Change
+
+	// A node whose type differs is treated as removed and re-created
+	sameNode := func(name string) bool {
+		na, okA := a.nodes[name]
+		nb, okB := b.nodes[name]
+		return okA && okB && na.Type == nb.Type
+	}
+
+	for _, c := range a.Constraints() {
+		if cb, ok := b.constraints[c.Name]; !ok || !reflect.DeepEqual(&c, cb) || !sameNodes(sameNode, c.nodes()...) {
+			changes = append(changes, Change{Op: OpDeleteConstraint, Constraint: &Constraint{Name: c.Name}})
+		}
+	}
+	for _, o := range a.Obligations() {
+		if ob, ok := b.obligations[o.Name]; !ok || !reflect.DeepEqual(&o, ob) || !sameNode(o.Subject) || !sameNode(o.Target) {
+			changes = append(changes, Change{Op: OpDeleteObligation, Obligation: &Obligation{Name: o.Name}})
+		}
+	}
+	for _, p := range a.Prohibitions() {
+		if pb, ok := b.prohibitions[p.Name]; !ok || !reflect.DeepEqual(&p, pb) || !sameNode(p.Subject) || !sameNodes(sameNode, p.Containers...) {
+			changes = append(changes, Change{Op: OpDeleteProhibition, Prohibition: &Prohibition{Name: p.Name}})
+		}
+	}
+	for _, as := range a.Associations() {
+		if _, ok := b.associations[as.UA][as.Target]; !ok || !sameNode(as.UA) || !sameNode(as.Target) {
+			changes = append(changes, Change{Op: OpDissociate, Association: &Association{UA: as.UA, Target: as.Target}})
+		}
+	}
+	for _, as := range a.Assignments() {
+		if _, ok := b.parents[as.Child][as.Parent]; !ok || !sameNode(as.Child) || !sameNode(as.Parent) {
+			changes = append(changes, Change{Op: OpDeassign, Assignment: &as})
+		}
+	}
+	for _, n := range a.Nodes() {
+		if !sameNode(n.Name) {
+			changes = append(changes, Change{Op: OpDeleteNode, Node: &Node{Name: n.Name}})
+		}
+	}
+	for _, n := range b.Nodes() {
+		if !sameNode(n.Name) {
+			changes = append(changes, Change{Op: OpCreateNode, Node: &n})
+		} else if !reflect.DeepEqual(a.nodes[n.Name].Properties, n.Properties) {
+			changes = append(changes, Change{Op: OpUpdateNode, Node: &n})
+		}
+	}
+	for _, as := range b.Assignments() {
+		if _, ok := a.parents[as.Child][as.Parent]; !ok || !sameNode(as.Child) || !sameNode(as.Parent) {
+			changes = append(changes, Change{Op: OpAssign, Assignment: &as})
+		}
+	}
+	for _, as := range b.Associations() {
+		ops, ok := a.associations[as.UA][as.Target]
+		if !ok || !sameNode(as.UA) || !sameNode(as.Target) || !reflect.DeepEqual(ops, as.Operations) {
+			changes = append(changes, Change{Op: OpAssociate, Association: &as})
+		}
+	}
+	for _, p := range b.Prohibitions() {
+		if pa, ok := a.prohibitions[p.Name]; !ok || !reflect.DeepEqual(pa, &p) || !sameNode(p.Subject) || !sameNodes(sameNode, p.Containers...) {
+			changes = append(changes, Change{Op: OpCreateProhibition, Prohibition: &p})
+		}
+	}
+	for _, o := range b.Obligations() {
+		if oa, ok := a.obligations[o.Name]; !ok || !reflect.DeepEqual(oa, &o) || !sameNode(o.Subject) || !sameNode(o.Target) {
+			changes = append(changes, Change{Op: OpCreateObligation, Obligation: &o})
+		}
+	}
+	for _, c := range b.Constraints() {
+		if ca, ok := a.constraints[c.Name]; !ok || !reflect.DeepEqual(ca, &c) || !sameNodes(sameNode, c.nodes()...) {
+			changes = append(changes, Change{Op: OpCreateConstraint, Constraint: &c})
+		}
+	}
+	return changes
+}
+
+func sameNodes(same func(string) bool, names ...string) bool {
+	for _, name := range names {
+		if !same(name) {
+			return false
+		}
+	}
+	return true
+}
+
+func copyNode(n *Node) *Node {
+	c := &Node{Name: n.Name, Type: n.Type}
+	if len(n.Properties) > 0 {
+		c.Properties = make(map[string]string, len(n.Properties))
+		for k, v := range n.Properties {
+			c.Properties[k] = v
+		}
+	}
+	return c
+}
+
+func copyProhibition(p *Prohibition) *Prohibition {
+	c := *p
+	c.Operations = append([]string(nil), p.Operations...)
+	c.Containers = append([]string(nil), p.Containers...)
+	return &c
+}
+
+func copyObligation(o *Obligation) *Obligation {
+	c := *o
+	c.Operations = append([]string(nil), o.Operations...)
+	c.Response = make(map[string]interface{}, len(o.Response))
+	for k, v := range o.Response {
+		c.Response[k] = v
+	}
+	return &c
+}
+
+// normalize sorts and de-duplicates a list of operations
+func normalize(ops []string) []string {
+	set := make(map[string]struct{}, len(ops))
+	for _, op := range ops {
+		if op != "" {
+			set[op] = struct{}{}
+		}
+	}
+	return sortedKeys(set)
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
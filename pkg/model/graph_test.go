@@ -0,0 +1,206 @@
+package model
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func createNode(name string, typ NodeType, parents ...string) []Change {
+	out := []Change{{Op: OpCreateNode, Node: &Node{Name: name, Type: typ}}}
+	for _, p := range parents {
+		out = append(out, Change{Op: OpAssign, Assignment: &Assignment{Child: name, Parent: p}})
+	}
+	return out
+}
+
+func apply(t *testing.T, g *Graph, changes ...[]Change) {
+	t.Helper()
+	for _, list := range changes {
+		for _, ch := range list {
+			if err := g.Apply(ch); err != nil {
+				t.Fatalf("%s: %v", ch, err)
+			}
+		}
+	}
+}
+
+// hospital is a small graph with one of every kind of policy element
+func hospital(t *testing.T) *Graph {
+	t.Helper()
+	g := NewGraph()
+	apply(t, g,
+		createNode("hospital", PolicyClass),
+		createNode("staff", UserAttribute, "hospital"),
+		createNode("doctor", UserAttribute, "staff"),
+		createNode("intern", UserAttribute, "staff"),
+		createNode("alice", User, "doctor"),
+		createNode("records", ObjectAttribute, "hospital"),
+		createNode("rec", Object, "records"),
+		createNode("claims", ObjectAttribute, "hospital"),
+		[]Change{
+			{Op: OpAssociate, Association: &Association{UA: "staff", Target: "records", Operations: []string{"read"}}},
+			{Op: OpCreateProhibition, Prohibition: &Prohibition{Name: "no_write", Subject: "intern", Operations: []string{"write"}, Containers: []string{"records"}}},
+			{Op: OpCreateObligation, Obligation: &Obligation{Name: "mask", Subject: "intern", Operations: []string{"read"}, Target: "records", Response: map[string]interface{}{"type": "mask", "fields": []string{"ssn"}}}},
+			{Op: OpCreateConstraint, Constraint: &Constraint{Name: "sod", Type: StaticSoD, Attributes: []string{"doctor", "intern"}}},
+			{Op: OpCreateConstraint, Constraint: &Constraint{Name: "claims_sod", Type: DynamicSoD, Operations: []string{"submit", "approve"}, Containers: []string{"claims"}}},
+		},
+	)
+	return g
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		change []Change
+		want   []string
+	}{
+		{name: "identical"},
+		{
+			name:   "node added",
+			change: createNode("nurse", UserAttribute, "staff"),
+			want:   []string{`+ UA "nurse"`, `+ assign "nurse" to "staff"`},
+		},
+		{
+			name:   "node removed",
+			change: []Change{{Op: OpDeleteNode, Node: &Node{Name: "alice"}}},
+			want:   []string{`- assign "alice" to "doctor"`, `- node "alice"`},
+		},
+		{
+			name:   "properties changed",
+			change: []Change{{Op: OpUpdateNode, Node: &Node{Name: "rec", Type: Object, Properties: map[string]string{"type": "record"}}}},
+			want:   []string{`~ properties of "rec" = map[type:record]`},
+		},
+		{
+			name: "type changed",
+			change: []Change{
+				{Op: OpDeleteNode, Node: &Node{Name: "rec"}},
+				{Op: OpCreateNode, Node: &Node{Name: "rec", Type: ObjectAttribute}},
+				{Op: OpAssign, Assignment: &Assignment{Child: "rec", Parent: "records"}},
+			},
+			want: []string{`- assign "rec" to "records"`, `- node "rec"`, `+ OA "rec"`, `+ assign "rec" to "records"`},
+		},
+		{
+			name:   "association operations changed",
+			change: []Change{{Op: OpAssociate, Association: &Association{UA: "staff", Target: "records", Operations: []string{"write", "read"}}}},
+			want:   []string{`+ associate "staff" and "records" with [read write]`},
+		},
+		{
+			name:   "association removed",
+			change: []Change{{Op: OpDissociate, Association: &Association{UA: "staff", Target: "records"}}},
+			want:   []string{`- associate "staff" and "records"`},
+		},
+		{
+			name: "prohibition changed",
+			change: []Change{
+				{Op: OpDeleteProhibition, Prohibition: &Prohibition{Name: "no_write"}},
+				{Op: OpCreateProhibition, Prohibition: &Prohibition{Name: "no_write", Subject: "intern", Operations: []string{"write"}, Containers: []string{"records"}, Intersection: true}},
+			},
+			want: []string{`- prohibition "no_write"`, `+ prohibition "no_write" on "intern" [write]`},
+		},
+		{
+			name: "obligation response changed",
+			change: []Change{
+				{Op: OpDeleteObligation, Obligation: &Obligation{Name: "mask"}},
+				{Op: OpCreateObligation, Obligation: &Obligation{Name: "mask", Subject: "intern", Operations: []string{"read"}, Target: "records", Response: map[string]interface{}{"type": "mask", "fields": []string{"ssn", "salary"}}}},
+			},
+			want: []string{`- obligation "mask"`, `+ obligation "mask" on "intern" [read]`},
+		},
+		{
+			name: "obligation rebuilt with an equal response",
+			change: []Change{
+				{Op: OpDeleteObligation, Obligation: &Obligation{Name: "mask"}},
+				{Op: OpCreateObligation, Obligation: &Obligation{Name: "mask", Subject: "intern", Operations: []string{"read", "read"}, Target: "records", Response: map[string]interface{}{"type": "mask", "fields": []interface{}{"ssn"}}}},
+			},
+		},
+		{
+			name: "constraint container re-created as a policy class",
+			change: []Change{
+				{Op: OpDeleteConstraint, Constraint: &Constraint{Name: "claims_sod"}},
+				{Op: OpDeleteNode, Node: &Node{Name: "claims"}},
+				{Op: OpCreateNode, Node: &Node{Name: "claims", Type: PolicyClass}},
+				{Op: OpCreateConstraint, Constraint: &Constraint{Name: "claims_sod", Type: DynamicSoD, Operations: []string{"approve", "submit"}, Containers: []string{"claims"}}},
+			},
+			want: []string{
+				`- constraint "claims_sod"`,
+				`- assign "claims" to "hospital"`,
+				`- node "claims"`,
+				`+ PC "claims"`,
+				`+ constraint "claims_sod" separating [approve submit] on [claims]`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := hospital(t)
+			b := a.Clone()
+			apply(t, b, tt.change)
+
+			changes := Diff(a, b)
+			got := make([]string, len(changes))
+			for i, ch := range changes {
+				got[i] = ch.String()
+			}
+			if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+				t.Fatalf("Diff() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+
+			// Applying the diff to a yields b
+			c := a.Clone()
+			apply(t, c, changes)
+			if rest := Diff(c, b); len(rest) > 0 {
+				t.Fatalf("graphs differ after applying the diff by %v", rest)
+			}
+		})
+	}
+}
+
+func TestHasOperation(t *testing.T) {
+	g := hospital(t)
+	tests := []struct {
+		op   string
+		want bool
+	}{
+		{op: "read", want: true},
+		// Prohibited, obligated and separated operations are not granted
+		{op: "write"},
+		{op: "submit"},
+		{op: ""},
+	}
+	for _, tt := range tests {
+		if got := g.HasOperation(tt.op); got != tt.want {
+			t.Errorf("HasOperation(%q) = %v, want %v", tt.op, got, tt.want)
+		}
+	}
+}
+
+func TestObligationResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		response map[string]interface{}
+		err      string
+	}{
+		{name: "type only", response: map[string]interface{}{"type": "audit"}},
+		{name: "scalars and lists", response: map[string]interface{}{"type": "limit", "max": 10, "ratio": 0.5, "strict": true, "scope": "user", "ids": []string{"a"}, "none": []string{}}},
+		{name: "no type", response: map[string]interface{}{"message": "x"}, err: "response has no type"},
+		{name: "type not a string", response: map[string]interface{}{"type": 1}, err: "response type must be a string"},
+		{name: "null", response: map[string]interface{}{"type": "x", "a": nil}, err: `response field "a" must be`},
+		{name: "nested object", response: map[string]interface{}{"type": "x", "a": map[string]string{"b": "c"}}, err: `response field "a" must be`},
+		{name: "list of numbers", response: map[string]interface{}{"type": "x", "a": []int{1}}, err: `response field "a" must contain only strings`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := hospital(t)
+			err := g.Apply(Change{Op: OpCreateObligation, Obligation: &Obligation{Name: "o", Subject: "staff", Operations: []string{"read"}, Target: "records", Response: tt.response}})
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Apply() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
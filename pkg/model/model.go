@@ -1 +1,235 @@
 package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// NodeType is the kind of an NGAC policy element
+type NodeType string
+
+const (
+	PolicyClass     NodeType = "PC"
+	UserAttribute   NodeType = "UA"
+	ObjectAttribute NodeType = "OA"
+	User            NodeType = "U"
+	Object          NodeType = "O"
+)
+
+// Node is a policy element in the graph
+type Node struct {
+	Name       string            `json:"name" yaml:"name"`
+	Type       NodeType          `json:"type" yaml:"type"`
+	Properties map[string]string `json:"properties,omitempty" yaml:"properties,omitempty"`
+}
+
+// Assignment places a child node inside a parent container
+type Assignment struct {
+	Child  string `json:"child" yaml:"child"`
+	Parent string `json:"parent" yaml:"parent"`
+}
+
+// Association grants the operations to members of a user attribute on a target
+type Association struct {
+	UA         string   `json:"ua" yaml:"ua"`
+	Target     string   `json:"target" yaml:"target"`
+	Operations []string `json:"operations" yaml:"operations"`
+}
+
+// Prohibition denies operations to a subject on the given containers.
+// When Intersection is set the object must be contained in every container,
+// otherwise membership in any of them is enough.
+type Prohibition struct {
+	Name         string   `json:"name" yaml:"name"`
+	Subject      string   `json:"subject" yaml:"subject"`
+	Operations   []string `json:"operations" yaml:"operations"`
+	Containers   []string `json:"containers" yaml:"containers"`
+	Intersection bool     `json:"intersection,omitempty" yaml:"intersection,omitempty"`
+}
+
+// Obligation attaches a response to decisions matching its subject,
+// operations and target
+type Obligation struct {
+	Name       string                 `json:"name" yaml:"name"`
+	Subject    string                 `json:"subject" yaml:"subject"`
+	Operations []string               `json:"operations" yaml:"operations"`
+	Target     string                 `json:"target" yaml:"target"`
+	Response   map[string]interface{} `json:"response" yaml:"response"`
+}
+
+// ConstraintType distinguishes static from dynamic separation of duty
+type ConstraintType string
+
+const (
+	// StaticSoD forbids a user from being contained in more than one of the
+	// constraint's user attributes
+	StaticSoD ConstraintType = "static"
+	// DynamicSoD forbids a user from performing more than one of the
+	// constraint's operations on the same object
+	DynamicSoD ConstraintType = "dynamic"
+)
+
+// Constraint is a separation of duty constraint. Static constraints name
+// mutually exclusive user attributes. Dynamic constraints name mutually
+// exclusive operations on objects in the given containers, or on any object
+// when there are none.
+type Constraint struct {
+	Name       string         `json:"name" yaml:"name"`
+	Type       ConstraintType `json:"type" yaml:"type"`
+	Attributes []string       `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+	Operations []string       `json:"operations,omitempty" yaml:"operations,omitempty"`
+	Containers []string       `json:"containers,omitempty" yaml:"containers,omitempty"`
+}
+
+// ChangeOp names a single graph mutation
+type ChangeOp string
+
+const (
+	OpCreateNode        ChangeOp = "create_node"
+	OpUpdateNode        ChangeOp = "update_node"
+	OpDeleteNode        ChangeOp = "delete_node"
+	OpAssign            ChangeOp = "assign"
+	OpDeassign          ChangeOp = "deassign"
+	OpAssociate         ChangeOp = "associate"
+	OpDissociate        ChangeOp = "dissociate"
+	OpCreateProhibition ChangeOp = "create_prohibition"
+	OpDeleteProhibition ChangeOp = "delete_prohibition"
+	OpCreateObligation  ChangeOp = "create_obligation"
+	OpDeleteObligation  ChangeOp = "delete_obligation"
+	OpCreateConstraint  ChangeOp = "create_constraint"
+	OpDeleteConstraint  ChangeOp = "delete_constraint"
+)
+
+// Change is a single mutation of the graph. Only the field matching Op is set.
+type Change struct {
+	Op          ChangeOp     `json:"op" yaml:"op"`
+	Node        *Node        `json:"node,omitempty" yaml:"node,omitempty"`
+	Assignment  *Assignment  `json:"assignment,omitempty" yaml:"assignment,omitempty"`
+	Association *Association `json:"association,omitempty" yaml:"association,omitempty"`
+	Prohibition *Prohibition `json:"prohibition,omitempty" yaml:"prohibition,omitempty"`
+	Obligation  *Obligation  `json:"obligation,omitempty" yaml:"obligation,omitempty"`
+	Constraint  *Constraint  `json:"constraint,omitempty" yaml:"constraint,omitempty"`
+}
+
+// Revision is a committed set of changes
+type Revision struct {
+	Number    uint64    `json:"revision" yaml:"revision"`
+	Author    string    `json:"author" yaml:"author"`
+	Timestamp time.Time `json:"timestamp" yaml:"timestamp"`
+	Message   string    `json:"message,omitempty" yaml:"message,omitempty"`
+	// Tags carry provenance such as the hash of the files a revision was synced from
+	Tags    map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Changes []Change          `json:"changes" yaml:"changes"`
+}
+
+// EventType distinguishes watch events
+type EventType string
+
+const (
+	// EventChange carries a committed revision
+	EventChange EventType = "change"
+	// EventResync tells the watcher its position was compacted away and
+	// it must reload the full graph before watching again
+	EventResync EventType = "resync"
+)
+
+// Event is delivered to store watchers
+type Event struct {
+	Type      EventType `json:"type"`
+	Revision  *Revision `json:"revision,omitempty"`
+	Compacted uint64    `json:"compacted,omitempty"`
+}
+
+// CompactedError is returned when a revision is older than the store retains
+type CompactedError struct {
+	Revision  uint64 `json:"revision"`
+	Compacted uint64 `json:"compacted"`
+}
+
+func (e *CompactedError) Error() string {
+	return fmt.Sprintf("revision %d has been compacted, oldest available is %d", e.Revision, e.Compacted)
+}
+
+// ErrNoChanges is returned when a commit has no changes
+var ErrNoChanges = errors.New("no changes to commit")
+
+// InvalidChangeError is returned when a change cannot be applied to the
+// graph. Index counts from zero.
+type InvalidChangeError struct {
+	Index  int
+	Change Change
+	Err    error
+}
+
+func (e *InvalidChangeError) Error() string {
+	return fmt.Sprintf("change %d (%s): %v", e.Index+1, e.Change, e.Err)
+}
+
+func (e *InvalidChangeError) Unwrap() error {
+	return e.Err
+}
+
+// ConflictError is returned when a commit was prepared against a revision
+// that is no longer the latest
+type ConflictError struct {
+	Base   uint64 `json:"base"`
+	Latest uint64 `json:"latest"`
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("commit was based on revision %d but the latest is %d", e.Base, e.Latest)
+}
+
+// ConstraintError is returned when a commit would leave a user in more than
+// one of the attributes a static separation of duty constraint keeps apart
+type ConstraintError struct {
+	Constraint string   `json:"constraint"`
+	User       string   `json:"user"`
+	Attributes []string `json:"attributes"`
+}
+
+func (e *ConstraintError) Error() string {
+	quoted := make([]string, len(e.Attributes))
+	for i, a := range e.Attributes {
+		quoted[i] = fmt.Sprintf("%q", a)
+	}
+	return fmt.Sprintf("constraint %q: user %q would be a member of mutually exclusive attributes %s",
+		e.Constraint, e.User, strings.Join(quoted, ", "))
+}
+
+// String renders the change as a single human readable line
+func (c Change) String() string {
+	switch {
+	case c.Node != nil && c.Op == OpCreateNode:
+		return fmt.Sprintf("+ %s %q", c.Node.Type, c.Node.Name)
+	case c.Node != nil && c.Op == OpUpdateNode:
+		return fmt.Sprintf("~ properties of %q = %v", c.Node.Name, c.Node.Properties)
+	case c.Node != nil:
+		return fmt.Sprintf("- node %q", c.Node.Name)
+	case c.Assignment != nil && c.Op == OpAssign:
+		return fmt.Sprintf("+ assign %q to %q", c.Assignment.Child, c.Assignment.Parent)
+	case c.Assignment != nil:
+		return fmt.Sprintf("- assign %q to %q", c.Assignment.Child, c.Assignment.Parent)
+	case c.Association != nil && c.Op == OpAssociate:
+		return fmt.Sprintf("+ associate %q and %q with %v", c.Association.UA, c.Association.Target, c.Association.Operations)
+	case c.Association != nil:
+		return fmt.Sprintf("- associate %q and %q", c.Association.UA, c.Association.Target)
+	case c.Prohibition != nil && c.Op == OpCreateProhibition:
+		return fmt.Sprintf("+ prohibition %q on %q %v", c.Prohibition.Name, c.Prohibition.Subject, c.Prohibition.Operations)
+	case c.Prohibition != nil:
+		return fmt.Sprintf("- prohibition %q", c.Prohibition.Name)
+	case c.Obligation != nil && c.Op == OpCreateObligation:
+		return fmt.Sprintf("+ obligation %q on %q %v", c.Obligation.Name, c.Obligation.Subject, c.Obligation.Operations)
+	case c.Obligation != nil:
+		return fmt.Sprintf("- obligation %q", c.Obligation.Name)
+	case c.Constraint != nil && c.Op == OpCreateConstraint && c.Constraint.Type == StaticSoD:
+		return fmt.Sprintf("+ constraint %q separating %v", c.Constraint.Name, c.Constraint.Attributes)
+	case c.Constraint != nil && c.Op == OpCreateConstraint:
+		return fmt.Sprintf("+ constraint %q separating %v on %v", c.Constraint.Name, c.Constraint.Operations, c.Constraint.Containers)
+	case c.Constraint != nil:
+		return fmt.Sprintf("- constraint %q", c.Constraint.Name)
+	}
+	return string(c.Op)
+}
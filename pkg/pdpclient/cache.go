@@ -0,0 +1,89 @@
+package pdpclient
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+
+	"github.com/kumarabd/policy-machine/pkg/service"
+)
+
+// cache is an LRU of decisions. Decisions on the latest revision are tagged
+// with the generation current when they were requested; invalidate bumps the
+// generation so they all miss without walking the list. Decisions as of a
+// past revision never change and survive invalidation.
+type cache struct {
+	mu    sync.Mutex
+	size  int
+	gen   uint64
+	order *list.List
+	items map[string]*list.Element
+}
+
+type entry struct {
+	key      string
+	gen      uint64
+	latest   bool
+	decision service.Decision
+}
+
+func newCache(size int) *cache {
+	return &cache{size: size, order: list.New(), items: map[string]*list.Element{}}
+}
+
+func cacheKey(req service.CheckRequest) string {
+	return fmt.Sprintf("%s\x00%s\x00%s\x00%d", req.User, req.Object, req.Operation, req.AsOf)
+}
+
+func (c *cache) get(req service.CheckRequest) (*service.Decision, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[cacheKey(req)]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if e.latest && e.gen != c.gen {
+		c.order.Remove(el)
+		delete(c.items, e.key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	d := e.decision
+	return &d, true
+}
+
+// put stores a decision requested at generation gen. A decision fetched
+// across an invalidation is dropped since it may predate the change.
+func (c *cache) put(req service.CheckRequest, d *service.Decision, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	latest := req.AsOf == 0
+	if latest && gen != c.gen {
+		return
+	}
+	key := cacheKey(req)
+	if el, ok := c.items[key]; ok {
+		c.order.Remove(el)
+	}
+	c.items[key] = c.order.PushFront(&entry{key: key, gen: gen, latest: latest, decision: *d})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*entry).key)
+	}
+}
+
+func (c *cache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+func (c *cache) invalidate() {
+	c.mu.Lock()
+	c.gen++
+	c.mu.Unlock()
+}
@@ -0,0 +1,240 @@
+// Package pdpclient is a Go client for the policy machine decision API.
+// It implements the same Check and BatchCheck methods as the in-process
+// service.Handler, and both are a pep.PDP, so callers can switch between
+// local and remote evaluation.
+package pdpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kumarabd/policy-machine/pkg/pep"
+	"github.com/kumarabd/policy-machine/pkg/service"
+)
+
+var _ pep.PDP = (*Client)(nil)
+
+// Config configures a Client
+type Config struct {
+	// URL is the base URL of the policy machine, e.g. http://localhost:8000
+	URL string `json:"url" yaml:"url"`
+	// Timeout bounds each decision request, 5 seconds by default
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+	// MaxConns is the number of pooled idle connections kept to the server
+	MaxConns int `json:"max_conns" yaml:"max_conns"`
+	// CacheSize is the number of decisions kept in the local LRU cache.
+	// Zero disables caching and the watch subscription.
+	CacheSize int `json:"cache_size" yaml:"cache_size"`
+	// Headers are sent with every request, e.g. for authentication
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+}
+
+// Client asks a remote policy machine for decisions
+type Client struct {
+	url     string
+	headers map[string]string
+	http    *http.Client
+	stream  *http.Client
+
+	cache  *cache
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu       sync.RWMutex
+	watching bool
+}
+
+// New creates a Client. With a cache configured it subscribes to the
+// server's change stream until Close is called.
+func New(cfg Config) (*Client, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("pdpclient: url is required")
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.MaxConns == 0 {
+		cfg.MaxConns = 16
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = cfg.MaxConns
+	transport.MaxIdleConnsPerHost = cfg.MaxConns
+
+	c := &Client{
+		url:     strings.TrimSuffix(cfg.URL, "/"),
+		headers: cfg.Headers,
+		http:    &http.Client{Timeout: cfg.Timeout, Transport: transport},
+		stream:  &http.Client{Transport: transport},
+		done:    make(chan struct{}),
+	}
+	if cfg.CacheSize <= 0 {
+		close(c.done)
+		return c, nil
+	}
+
+	c.cache = newCache(cfg.CacheSize)
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	go c.watch(ctx)
+	return c, nil
+}
+
+// Close stops the watch subscription
+func (c *Client) Close() error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	<-c.done
+	c.http.CloseIdleConnections()
+	return nil
+}
+
+// Decide implements pep.PDP
+func (c *Client) Decide(ctx context.Context, req pep.Request) (*pep.Decision, error) {
+	d, err := c.Check(ctx, service.CheckRequest{User: req.Subject, Object: req.Resource, Operation: req.Action})
+	if err != nil {
+		return nil, err
+	}
+	return d.PEP(), nil
+}
+
+// Check asks for a single decision, answering from the cache when possible
+func (c *Client) Check(ctx context.Context, req service.CheckRequest) (*service.Decision, error) {
+	if d, ok := c.cached(req); ok {
+		return d, nil
+	}
+	gen := c.generation()
+
+	var d service.Decision
+	if err := c.do(ctx, http.MethodPost, "/pdp/v1/check", req, &d); err != nil {
+		return nil, err
+	}
+	c.store(req, &d, gen)
+	return &d, nil
+}
+
+// BatchCheck asks for several decisions in one round trip. Cached decisions
+// are answered locally and only the rest are sent.
+func (c *Client) BatchCheck(ctx context.Context, reqs []service.CheckRequest) ([]*service.Decision, error) {
+	out := make([]*service.Decision, len(reqs))
+	var missing []int
+	for i, req := range reqs {
+		if d, ok := c.cached(req); ok {
+			out[i] = d
+			continue
+		}
+		missing = append(missing, i)
+	}
+	if len(missing) == 0 {
+		return out, nil
+	}
+	gen := c.generation()
+
+	body := struct {
+		Requests []service.CheckRequest `json:"requests"`
+	}{}
+	for _, i := range missing {
+		body.Requests = append(body.Requests, reqs[i])
+	}
+	var resp struct {
+		Decisions []*service.Decision `json:"decisions"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/pdp/v1/batch_check", body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Decisions) != len(missing) {
+		return nil, fmt.Errorf("pdpclient: expected %d decisions, got %d", len(missing), len(resp.Decisions))
+	}
+	for j, i := range missing {
+		out[i] = resp.Decisions[j]
+		c.store(reqs[i], resp.Decisions[j], gen)
+	}
+	return out, nil
+}
+
+// Revision returns the latest revision of the server's policy graph
+func (c *Client) Revision(ctx context.Context) (uint64, error) {
+	var resp struct {
+		Revision uint64 `json:"revision"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/pdp/v1/revision", nil, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Revision, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, &payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("pdpclient: %s %s returned %d: %s", method, path, resp.StatusCode, e.Error)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// cached looks a decision up. Decisions on the latest revision are only
+// served while the watch subscription keeps the cache fresh.
+func (c *Client) cached(req service.CheckRequest) (*service.Decision, bool) {
+	if c.cache == nil || (req.AsOf == 0 && !c.isWatching()) {
+		return nil, false
+	}
+	return c.cache.get(req)
+}
+
+func (c *Client) store(req service.CheckRequest, d *service.Decision, gen uint64) {
+	if c.cache == nil || d.Dynamic || (req.AsOf == 0 && !c.isWatching()) {
+		return
+	}
+	c.cache.put(req, d, gen)
+}
+
+func (c *Client) generation() uint64 {
+	if c.cache == nil {
+		return 0
+	}
+	return c.cache.generation()
+}
+
+func (c *Client) isWatching() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.watching
+}
+
+func (c *Client) setWatching(v bool) {
+	c.mu.Lock()
+	c.watching = v
+	c.mu.Unlock()
+	// Anything cached before or after a gap in the stream may be stale
+	c.cache.invalidate()
+}
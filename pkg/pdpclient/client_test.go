@@ -0,0 +1,138 @@
+package pdpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kumarabd/policy-machine/pkg/service"
+)
+
+// fakePDP answers every check with allow, counting the checks it served,
+// and streams an event to the watchers for every value sent on events
+type fakePDP struct {
+	checks  atomic.Int64
+	watches atomic.Int64
+	// watchStatus rejects the watch when set
+	watchStatus int
+	// dynamic marks the decisions as depending on history
+	dynamic bool
+	events  chan string
+}
+
+func (f *fakePDP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/pdp/v1/revision":
+		fmt.Fprint(w, `{"revision": 1}`)
+	case "/pdp/v1/check":
+		f.checks.Add(1)
+		json.NewEncoder(w).Encode(service.Decision{Allowed: true, Decision: service.DecisionAllow, Revision: 1, Dynamic: f.dynamic})
+	case "/pdp/v1/watch":
+		if f.watchStatus != 0 {
+			w.WriteHeader(f.watchStatus)
+			return
+		}
+		f.watches.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case ev := <-f.events:
+				fmt.Fprintf(w, "event:%s\ndata:{}\n\n", ev)
+				w.(http.Flusher).Flush()
+			}
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// eventually polls cond until it holds or a second passes
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatal("condition not met within a second")
+}
+
+func TestClientCache(t *testing.T) {
+	req := service.CheckRequest{User: "alice", Object: "rec", Operation: "read"}
+	tests := []struct {
+		name string
+		pdp  *fakePDP
+		// between runs after the first check, before the second
+		between func(t *testing.T, f *fakePDP, c *Client)
+		asOf    uint64
+		// checks is the number of checks the server serves for two
+		// client checks
+		checks int64
+	}{
+		{name: "cached while watching", pdp: &fakePDP{}, checks: 1},
+		{
+			name: "invalidated by a change",
+			pdp:  &fakePDP{},
+			between: func(t *testing.T, f *fakePDP, c *Client) {
+				gen := c.generation()
+				f.events <- "change"
+				eventually(t, func() bool { return c.generation() != gen })
+			},
+			checks: 2,
+		},
+		{
+			name: "invalidated by a resync",
+			pdp:  &fakePDP{},
+			between: func(t *testing.T, f *fakePDP, c *Client) {
+				f.events <- "resync"
+				// The client reconnects after a resync
+				eventually(t, func() bool { return f.watches.Load() == 2 })
+			},
+			checks: 2,
+		},
+		{name: "not cached without the watch", pdp: &fakePDP{watchStatus: http.StatusUnauthorized}, checks: 2},
+		{name: "past revision cached without the watch", pdp: &fakePDP{watchStatus: http.StatusUnauthorized}, asOf: 1, checks: 1},
+		{name: "dynamic decisions not cached", pdp: &fakePDP{dynamic: true}, checks: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.pdp.events = make(chan string)
+			srv := httptest.NewServer(tt.pdp)
+			defer srv.Close()
+			c, err := New(Config{URL: srv.URL, CacheSize: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			if tt.pdp.watchStatus == 0 {
+				eventually(t, c.isWatching)
+			}
+
+			ctx := context.Background()
+			req := req
+			req.AsOf = tt.asOf
+			if _, err := c.Check(ctx, req); err != nil {
+				t.Fatal(err)
+			}
+			if tt.between != nil {
+				tt.between(t, tt.pdp, c)
+				eventually(t, c.isWatching)
+			}
+			if _, err := c.Check(ctx, req); err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.pdp.checks.Load(); got != tt.checks {
+				t.Fatalf("server served %d checks, want %d", got, tt.checks)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package pdpclient
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	watchBackoffMin = 500 * time.Millisecond
+	watchBackoffMax = 30 * time.Second
+)
+
+// watch keeps a subscription to the server's change stream, invalidating
+// cached decisions on every committed revision and reconnecting with backoff
+func (c *Client) watch(ctx context.Context) {
+	defer close(c.done)
+
+	backoff := watchBackoffMin
+	for ctx.Err() == nil {
+		// Failures are retried; until then decisions bypass the cache
+		connected, _ := c.subscribe(ctx)
+		c.setWatching(false)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = watchBackoffMin
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > watchBackoffMax {
+			backoff = watchBackoffMax
+		}
+	}
+}
+
+// subscribe streams changes after the current revision until the stream
+// ends. It reports whether the stream was established.
+func (c *Client) subscribe(ctx context.Context) (bool, error) {
+	rev, err := c.Revision(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/pdp/v1/watch?from=%d", c.url, rev), nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.stream.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("pdpclient: watch returned %d", resp.StatusCode)
+	}
+
+	c.setWatching(true)
+	r := bufio.NewReader(resp.Body)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return true, nil
+			}
+			return true, err
+		}
+		// Every event, change or resync, may alter decisions; comments
+		// are keepalives
+		if event, ok := strings.CutPrefix(strings.TrimRight(line, "\r\n"), "event:"); ok {
+			c.cache.invalidate()
+			if strings.TrimSpace(event) == "resync" {
+				return true, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package pep
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Gin returns a gin middleware enforcing every request. Route parameters are
+// passed to the resource mapping, and the obligations of allowed decisions
+// are also set on the gin context under "obligations". The enforced
+// decision, allowed or denied, is set under "decision" for request logging.
+func (e *Enforcer) Gin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		params := make(map[string]string, len(c.Params))
+		for _, p := range c.Params {
+			params[p.Key] = p.Value
+		}
+
+		ctx, d, err := e.Enforce(c.Request.Context(), Target{
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.Path,
+			Params:     params,
+			Attributes: HTTPAttributes(c.Request.Header),
+		})
+		if err != nil {
+			var denied *DeniedError
+			if errors.As(err, &denied) {
+				c.Set("decision", denied.Decision)
+			}
+			status, body := httpError(err)
+			c.AbortWithStatusJSON(status, body)
+			return
+		}
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Set("decision", d)
+		c.Set("obligations", d.Obligations)
+		c.Next()
+	}
+}
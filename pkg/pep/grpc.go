@@ -0,0 +1,65 @@
+package pep
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor enforces every unary call
+func (e *Enforcer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := e.enforceGRPC(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor enforces every streaming call when the stream opens
+func (e *Enforcer) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := e.enforceGRPC(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func (e *Enforcer) enforceGRPC(ctx context.Context, fullMethod string) (context.Context, error) {
+	attrs := Attributes{}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for k, v := range md {
+			if len(v) > 0 {
+				attrs[strings.ToLower(k)] = v[0]
+			}
+		}
+	}
+
+	ctx, _, err := e.Enforce(ctx, Target{Path: fullMethod, Attributes: attrs})
+	if err != nil {
+		var denied *DeniedError
+		if errors.As(err, &denied) {
+			return ctx, status.Error(codes.PermissionDenied, denied.Error())
+		}
+		return ctx, status.Error(codes.Internal, "authorization failed")
+	}
+	return ctx, nil
+}
+
+// serverStream carries the enforced context into the stream handler
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,46 @@
+package pep
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// deniedBody is the JSON body written for denied HTTP requests
+type deniedBody struct {
+	Error       string       `json:"error"`
+	Reason      string       `json:"reason,omitempty"`
+	Obligations []Obligation `json:"obligations,omitempty"`
+}
+
+// HTTP wraps a net/http handler, enforcing every request before it
+func (e *Enforcer) HTTP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, _, err := e.Enforce(r.Context(), Target{
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Attributes: HTTPAttributes(r.Header),
+		})
+		if err != nil {
+			status, body := httpError(err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			_ = json.NewEncoder(w).Encode(body)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// httpError maps an Enforce error onto a status code and response body
+func httpError(err error) (int, deniedBody) {
+	var denied *DeniedError
+	if errors.As(err, &denied) {
+		return http.StatusForbidden, deniedBody{
+			Error:       "access denied",
+			Reason:      denied.Decision.Reason,
+			Obligations: denied.Decision.Obligations,
+		}
+	}
+	return http.StatusInternalServerError, deniedBody{Error: "authorization failed"}
+}
@@ -0,0 +1,82 @@
+package pep
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Attributes are the request headers or gRPC metadata, keyed in lower case
+type Attributes map[string]string
+
+// Get returns the attribute regardless of the case of key
+func (a Attributes) Get(key string) string {
+	return a[strings.ToLower(key)]
+}
+
+// HTTPAttributes collects the first value of every header
+func HTTPAttributes(h http.Header) Attributes {
+	out := Attributes{}
+	for k, v := range h {
+		if len(v) > 0 {
+			out[strings.ToLower(k)] = v[0]
+		}
+	}
+	return out
+}
+
+// Target describes the call being enforced
+type Target struct {
+	// Method is the HTTP method, empty for gRPC calls
+	Method string
+	// Path is the URL path, or the full method name of a gRPC call
+	Path string
+	// Params holds route parameters when the router provides them
+	Params     map[string]string
+	Attributes Attributes
+}
+
+// SubjectFunc extracts the subject of a call
+type SubjectFunc func(ctx context.Context, attrs Attributes) string
+
+// HeaderSubject reads the subject from a header or metadata key
+func HeaderSubject(key string) SubjectFunc {
+	return func(_ context.Context, attrs Attributes) string {
+		return attrs.Get(key)
+	}
+}
+
+// ResourceFunc maps a call onto the resource and action to decide
+type ResourceFunc func(ctx context.Context, t Target) (resource, action string)
+
+// DefaultResource takes the resource from the resource_id route parameter or
+// else the last path segment, never from headers the client controls. HTTP
+// methods map to read, write and delete; gRPC calls use the full method name
+// as the resource and the action "call".
+func DefaultResource(_ context.Context, t Target) (string, string) {
+	if t.Method == "" {
+		return t.Path, "call"
+	}
+
+	resource := t.Params["resource_id"]
+	if resource == "" {
+		path, _, _ := strings.Cut(t.Path, "?")
+		segments := strings.Split(strings.Trim(path, "/"), "/")
+		resource = segments[len(segments)-1]
+	}
+	return resource, ActionFromMethod(t.Method)
+}
+
+// ActionFromMethod maps HTTP methods to actions
+func ActionFromMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return "read"
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return "write"
+	case http.MethodDelete:
+		return "delete"
+	default:
+		return "read"
+	}
+}
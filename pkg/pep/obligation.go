@@ -0,0 +1,62 @@
+package pep
+
+import (
+	"context"
+	"fmt"
+)
+
+// MaskedValue replaces fields hidden by a mask obligation
+const MaskedValue = "***MASKED***"
+
+// Obligation is a response attached to an allowed decision, such as
+// {"type": "mask", "fields": [...]} or {"type": "log", "message": "..."}
+type Obligation map[string]interface{}
+
+// ObligationHandler fulfils an obligation before the call proceeds
+type ObligationHandler func(ctx context.Context, o Obligation) error
+
+// Type returns the obligation type
+func (o Obligation) Type() string {
+	t, _ := o["type"].(string)
+	return t
+}
+
+// Fields returns the fields listed by the obligation
+func (o Obligation) Fields() []string {
+	switch fields := o["fields"].(type) {
+	case []string:
+		return fields
+	case []interface{}:
+		out := make([]string, 0, len(fields))
+		for _, f := range fields {
+			out = append(out, fmt.Sprint(f))
+		}
+		return out
+	}
+	return nil
+}
+
+// MaskFields lists every field hidden by the mask obligations
+func MaskFields(obligations []Obligation) []string {
+	var out []string
+	for _, o := range obligations {
+		if o.Type() == "mask" {
+			out = append(out, o.Fields()...)
+		}
+	}
+	return out
+}
+
+// Mask returns a copy of data with the fields of mask obligations replaced
+func Mask(data map[string]interface{}, obligations []Obligation) map[string]interface{} {
+	out := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		out[k] = v
+	}
+	for _, f := range MaskFields(obligations) {
+		if _, ok := out[f]; ok {
+			out[f] = MaskedValue
+		}
+	}
+	return out
+}
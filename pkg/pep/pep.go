@@ -0,0 +1,198 @@
+// Package pep provides policy enforcement points for services protected by
+// the policy machine. An Enforcer extracts the subject, maps the call onto a
+// resource and action, asks a PDP for a decision and fulfils the obligations
+// of allowed decisions. Adapters wire it into net/http, gin and gRPC.
+package pep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Request is an access decision request in transport neutral form
+type Request struct {
+	Subject    string
+	Resource   string
+	Action     string
+	Attributes Attributes
+}
+
+// Decision is the answer of a PDP
+type Decision struct {
+	Allowed     bool
+	Reason      string
+	Obligations []Obligation
+	Revision    uint64
+}
+
+// PDP decides access requests
+type PDP interface {
+	Decide(ctx context.Context, req Request) (*Decision, error)
+}
+
+// DeniedError is returned by Enforce when the PDP denies the request or an
+// obligation of an allowed decision cannot be fulfilled
+type DeniedError struct {
+	Decision *Decision
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("access denied: %s", e.Decision.Reason)
+}
+
+// Recorder keeps an audit trail of enforced decisions. A decision that
+// cannot be recorded is not enforced.
+type Recorder interface {
+	Record(ctx context.Context, req Request, d *Decision) error
+}
+
+// Enforcer is the transport agnostic enforcement core shared by the adapters
+type Enforcer struct {
+	pdp      PDP
+	subject  SubjectFunc
+	resource ResourceFunc
+	handlers map[string]ObligationHandler
+	fallback ObligationHandler
+	recorder Recorder
+}
+
+// Option configures an Enforcer
+type Option func(*Enforcer)
+
+// WithSubject replaces the subject extraction, HeaderSubject("x-user-id") by default
+func WithSubject(f SubjectFunc) Option {
+	return func(e *Enforcer) { e.subject = f }
+}
+
+// WithResource replaces the resource mapping, DefaultResource by default
+func WithResource(f ResourceFunc) Option {
+	return func(e *Enforcer) { e.resource = f }
+}
+
+// WithObligationHandler registers a handler run for every obligation of the
+// given type on allowed decisions. A failing handler denies the request.
+func WithObligationHandler(obligationType string, h ObligationHandler) Option {
+	return func(e *Enforcer) { e.handlers[obligationType] = h }
+}
+
+// WithDefaultObligationHandler registers a handler run for obligations of
+// every type without a handler of its own
+func WithDefaultObligationHandler(h ObligationHandler) Option {
+	return func(e *Enforcer) { e.fallback = h }
+}
+
+// WithRecorder records every enforced decision, including denials by
+// obligation handlers and requests the PDP failed to decide
+func WithRecorder(r Recorder) Option {
+	return func(e *Enforcer) { e.recorder = r }
+}
+
+// New creates an Enforcer asking the given PDP
+func New(pdp PDP, opts ...Option) *Enforcer {
+	e := &Enforcer{
+		pdp:      pdp,
+		subject:  HeaderSubject("x-user-id"),
+		resource: DefaultResource,
+		handlers: map[string]ObligationHandler{},
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Enforce decides the call described by the target. On success the returned
+// context carries the decision and its obligations. A denial is reported as
+// a *DeniedError; any other error means no decision could be made.
+func (e *Enforcer) Enforce(ctx context.Context, t Target) (context.Context, *Decision, error) {
+	resource, action := e.resource(ctx, t)
+	req := Request{
+		Subject:    e.subject(ctx, t.Attributes),
+		Resource:   resource,
+		Action:     action,
+		Attributes: t.Attributes,
+	}
+
+	d, err := e.decide(ctx, req)
+	if e.recorder != nil {
+		enforced := d
+		var denied *DeniedError
+		switch {
+		case errors.As(err, &denied):
+			enforced = denied.Decision
+		case err != nil:
+			enforced = &Decision{Reason: fmt.Sprintf("no decision: %v", err)}
+		}
+		if rerr := e.recorder.Record(ctx, req, enforced); rerr != nil {
+			return ctx, d, fmt.Errorf("failed to record decision: %w", rerr)
+		}
+	}
+	if err != nil {
+		return ctx, d, err
+	}
+	return context.WithValue(ctx, decisionKey{}, d), d, nil
+}
+
+// decide asks the PDP and fulfils the obligations of an allowed decision
+func (e *Enforcer) decide(ctx context.Context, req Request) (*Decision, error) {
+	d, err := e.pdp.Decide(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if !d.Allowed {
+		return d, &DeniedError{Decision: d}
+	}
+
+	for _, o := range d.Obligations {
+		h, ok := e.handlers[o.Type()]
+		if !ok {
+			h = e.fallback
+		}
+		if h == nil {
+			continue
+		}
+		if err := fulfil(ctx, h, o); err != nil {
+			return d, &DeniedError{Decision: &Decision{
+				Reason:      fmt.Sprintf("obligation %q not fulfilled: %v", o.Type(), err),
+				Obligations: d.Obligations,
+				Revision:    d.Revision,
+			}}
+		}
+	}
+	return d, nil
+}
+
+// fulfil runs an obligation handler in its own span
+func fulfil(ctx context.Context, h ObligationHandler, o Obligation) error {
+	ctx, span := otel.Tracer("github.com/kumarabd/policy-machine/pkg/pep").Start(ctx, "pep.obligation",
+		trace.WithAttributes(attribute.String("authz.obligation", o.Type())))
+	defer span.End()
+	if err := h(ctx, o); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
+type decisionKey struct{}
+
+// FromContext returns the decision stored by an adapter
+func FromContext(ctx context.Context) (*Decision, bool) {
+	d, ok := ctx.Value(decisionKey{}).(*Decision)
+	return d, ok
+}
+
+// ObligationsFromContext returns the obligations of the decision stored by an adapter
+func ObligationsFromContext(ctx context.Context) []Obligation {
+	if d, ok := FromContext(ctx); ok {
+		return d.Obligations
+	}
+	return nil
+}
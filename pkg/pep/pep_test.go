@@ -0,0 +1,349 @@
+package pep
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// pdpFunc decides with a function and keeps the last request
+type pdpFunc struct {
+	decide func(Request) (*Decision, error)
+	last   Request
+}
+
+func (p *pdpFunc) Decide(_ context.Context, req Request) (*Decision, error) {
+	p.last = req
+	return p.decide(req)
+}
+
+// allowAlice allows alice with a mask obligation and denies everyone else
+func allowAlice() *pdpFunc {
+	return &pdpFunc{decide: func(req Request) (*Decision, error) {
+		if req.Subject != "alice" {
+			return &Decision{Reason: "not alice"}, nil
+		}
+		return &Decision{Allowed: true, Reason: "alice", Obligations: []Obligation{
+			{"type": "mask", "fields": []interface{}{"ssn"}},
+		}}, nil
+	}}
+}
+
+type recorderFunc func(ctx context.Context, req Request, d *Decision) error
+
+func (f recorderFunc) Record(ctx context.Context, req Request, d *Decision) error {
+	return f(ctx, req, d)
+}
+
+func TestEnforce(t *testing.T) {
+	failing := func(context.Context, Obligation) error { return errors.New("sink down") }
+	tests := []struct {
+		name    string
+		pdp     *pdpFunc
+		opts    []Option
+		target  Target
+		allowed bool
+		// denied expects a *DeniedError and err any other error
+		denied bool
+		err    bool
+		reason string
+		// resource and action are the mapped request
+		resource, action string
+	}{
+		{
+			name:     "allowed",
+			pdp:      allowAlice(),
+			target:   Target{Method: http.MethodGet, Path: "/records/rec_1", Attributes: Attributes{"x-user-id": "alice"}},
+			allowed:  true,
+			reason:   "alice",
+			resource: "rec_1",
+			action:   "read",
+		},
+		{
+			name:     "denied",
+			pdp:      allowAlice(),
+			target:   Target{Method: http.MethodDelete, Path: "/records/rec_1", Attributes: Attributes{"x-user-id": "bob"}},
+			denied:   true,
+			reason:   "not alice",
+			resource: "rec_1",
+			action:   "delete",
+		},
+		{
+			name:     "resource from the route parameter",
+			pdp:      allowAlice(),
+			target:   Target{Method: http.MethodPost, Path: "/records/rec_1/notes?x=1", Params: map[string]string{"resource_id": "rec_1"}, Attributes: Attributes{"x-user-id": "alice"}},
+			allowed:  true,
+			reason:   "alice",
+			resource: "rec_1",
+			action:   "write",
+		},
+		{
+			name:     "query string ignored",
+			pdp:      allowAlice(),
+			target:   Target{Method: http.MethodGet, Path: "/records/rec_2?x-resource-id=rec_1", Attributes: Attributes{"x-user-id": "alice"}},
+			allowed:  true,
+			reason:   "alice",
+			resource: "rec_2",
+			action:   "read",
+		},
+		{
+			name:     "grpc method",
+			pdp:      allowAlice(),
+			target:   Target{Path: "/records.v1.Records/Get", Attributes: Attributes{"x-user-id": "alice"}},
+			allowed:  true,
+			reason:   "alice",
+			resource: "/records.v1.Records/Get",
+			action:   "call",
+		},
+		{
+			name:     "custom subject",
+			pdp:      allowAlice(),
+			opts:     []Option{WithSubject(HeaderSubject("x-forwarded-user"))},
+			target:   Target{Method: http.MethodGet, Path: "/records/rec_1", Attributes: Attributes{"x-user-id": "bob", "x-forwarded-user": "alice"}},
+			allowed:  true,
+			reason:   "alice",
+			resource: "rec_1",
+			action:   "read",
+		},
+		{
+			name:     "obligation handler fails",
+			pdp:      allowAlice(),
+			opts:     []Option{WithObligationHandler("mask", failing)},
+			target:   Target{Method: http.MethodGet, Path: "/records/rec_1", Attributes: Attributes{"x-user-id": "alice"}},
+			denied:   true,
+			reason:   `obligation "mask" not fulfilled: sink down`,
+			resource: "rec_1",
+			action:   "read",
+		},
+		{
+			name:     "default obligation handler fails",
+			pdp:      allowAlice(),
+			opts:     []Option{WithDefaultObligationHandler(failing)},
+			target:   Target{Method: http.MethodGet, Path: "/records/rec_1", Attributes: Attributes{"x-user-id": "alice"}},
+			denied:   true,
+			reason:   `obligation "mask" not fulfilled: sink down`,
+			resource: "rec_1",
+			action:   "read",
+		},
+		{
+			name: "own handler preferred over the default",
+			pdp:  allowAlice(),
+			opts: []Option{
+				WithObligationHandler("mask", func(context.Context, Obligation) error { return nil }),
+				WithDefaultObligationHandler(failing),
+			},
+			target:   Target{Method: http.MethodGet, Path: "/records/rec_1", Attributes: Attributes{"x-user-id": "alice"}},
+			allowed:  true,
+			reason:   "alice",
+			resource: "rec_1",
+			action:   "read",
+		},
+		{
+			name:     "pdp error",
+			pdp:      &pdpFunc{decide: func(Request) (*Decision, error) { return nil, errors.New("unreachable") }},
+			target:   Target{Method: http.MethodGet, Path: "/records/rec_1", Attributes: Attributes{"x-user-id": "alice"}},
+			err:      true,
+			resource: "rec_1",
+			action:   "read",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var recorded *Decision
+			recorder := recorderFunc(func(_ context.Context, _ Request, d *Decision) error {
+				recorded = d
+				return nil
+			})
+			e := New(tt.pdp, append(tt.opts, WithRecorder(recorder))...)
+			ctx, d, err := e.Enforce(context.Background(), tt.target)
+
+			var denied *DeniedError
+			switch {
+			case tt.denied:
+				if !errors.As(err, &denied) {
+					t.Fatalf("Enforce() error = %v, want a denial", err)
+				}
+				if denied.Decision.Reason != tt.reason {
+					t.Errorf("reason %q, want %q", denied.Decision.Reason, tt.reason)
+				}
+				if recorded != denied.Decision {
+					t.Errorf("recorded %+v, want the denial", recorded)
+				}
+			case tt.err:
+				if err == nil || errors.As(err, &denied) {
+					t.Fatalf("Enforce() error = %v, want a failure", err)
+				}
+				if recorded == nil || recorded.Allowed {
+					t.Errorf("recorded %+v, want a denial", recorded)
+				}
+			default:
+				if err != nil {
+					t.Fatal(err)
+				}
+				if d.Allowed != tt.allowed || d.Reason != tt.reason {
+					t.Errorf("decision %+v", d)
+				}
+				if got, ok := FromContext(ctx); !ok || got != d {
+					t.Errorf("context decision %+v", got)
+				}
+				if got := MaskFields(ObligationsFromContext(ctx)); !reflect.DeepEqual(got, []string{"ssn"}) {
+					t.Errorf("context mask fields %v", got)
+				}
+				if recorded != d {
+					t.Errorf("recorded %+v, want %+v", recorded, d)
+				}
+			}
+			if tt.pdp.last.Resource != tt.resource || tt.pdp.last.Action != tt.action {
+				t.Errorf("request %s %s, want %s %s", tt.pdp.last.Resource, tt.pdp.last.Action, tt.resource, tt.action)
+			}
+		})
+	}
+}
+
+func TestEnforceRecorderFails(t *testing.T) {
+	e := New(allowAlice(), WithRecorder(recorderFunc(func(context.Context, Request, *Decision) error {
+		return errors.New("disk full")
+	})))
+	_, _, err := e.Enforce(context.Background(), Target{Method: http.MethodGet, Path: "/rec_1", Attributes: Attributes{"x-user-id": "alice"}})
+	var denied *DeniedError
+	if err == nil || errors.As(err, &denied) {
+		t.Fatalf("Enforce() error = %v, want a recording failure", err)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	tests := []struct {
+		name   string
+		pdp    *pdpFunc
+		user   string
+		status int
+		body   deniedBody
+	}{
+		{name: "allowed", pdp: allowAlice(), user: "alice", status: http.StatusOK},
+		{name: "denied", pdp: allowAlice(), user: "bob", status: http.StatusForbidden, body: deniedBody{Error: "access denied", Reason: "not alice"}},
+		{
+			name:   "pdp error",
+			pdp:    &pdpFunc{decide: func(Request) (*Decision, error) { return nil, errors.New("unreachable") }},
+			user:   "alice",
+			status: http.StatusInternalServerError,
+			body:   deniedBody{Error: "authorization failed"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data := Mask(map[string]interface{}{"name": "alice", "ssn": "123"}, ObligationsFromContext(r.Context()))
+				_ = json.NewEncoder(w).Encode(data)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/records/rec_1", nil)
+			req.Header.Set("X-User-ID", tt.user)
+			rec := httptest.NewRecorder()
+			New(tt.pdp).HTTP(next).ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusOK {
+				var data map[string]interface{}
+				if err := json.NewDecoder(rec.Body).Decode(&data); err != nil {
+					t.Fatal(err)
+				}
+				if data["ssn"] != MaskedValue || data["name"] != "alice" {
+					t.Errorf("body %v, want ssn masked", data)
+				}
+				return
+			}
+			var body deniedBody
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(body, tt.body) {
+				t.Errorf("body %+v, want %+v", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestGin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		user   string
+		status int
+	}{
+		{name: "allowed", user: "alice", status: http.StatusOK},
+		{name: "denied", user: "bob", status: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdp := allowAlice()
+			var decision interface{}
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				c.Next()
+				decision, _ = c.Get("decision")
+			})
+			r.GET("/records/:resource_id/data", New(pdp).Gin(), func(c *gin.Context) {
+				if fields := MaskFields(c.MustGet("obligations").([]Obligation)); !reflect.DeepEqual(fields, []string{"ssn"}) {
+					t.Errorf("obligations mask %v", fields)
+				}
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/records/rec_1/data", nil)
+			req.Header.Set("X-User-ID", tt.user)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d", rec.Code, tt.status)
+			}
+			if pdp.last.Resource != "rec_1" {
+				t.Errorf("resource %q, want the route parameter", pdp.last.Resource)
+			}
+			if d, ok := decision.(*Decision); !ok || d.Allowed != (tt.status == http.StatusOK) {
+				t.Errorf("decision %+v", decision)
+			}
+		})
+	}
+}
+
+func TestGRPC(t *testing.T) {
+	tests := []struct {
+		name string
+		pdp  *pdpFunc
+		user string
+		code codes.Code
+	}{
+		{name: "allowed", pdp: allowAlice(), user: "alice", code: codes.OK},
+		{name: "denied", pdp: allowAlice(), user: "bob", code: codes.PermissionDenied},
+		{name: "pdp error", pdp: &pdpFunc{decide: func(Request) (*Decision, error) { return nil, errors.New("unreachable") }}, user: "alice", code: codes.Internal},
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/records.v1.Records/Get"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-user-id", tt.user))
+			_, err := New(tt.pdp).UnaryServerInterceptor()(ctx, nil, info, func(ctx context.Context, _ interface{}) (interface{}, error) {
+				if _, ok := FromContext(ctx); !ok {
+					t.Error("handler context has no decision")
+				}
+				return nil, nil
+			})
+			if got := status.Code(err); got != tt.code {
+				t.Fatalf("code %s, want %s", got, tt.code)
+			}
+			if tt.pdp.last.Resource != info.FullMethod || tt.pdp.last.Action != "call" {
+				t.Errorf("request %+v", tt.pdp.last)
+			}
+		})
+	}
+}
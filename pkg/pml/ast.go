@@ -0,0 +1,106 @@
+package pml
+
+import "github.com/kumarabd/policy-machine/pkg/model"
+
+// Statement is a single parsed PML statement
+type Statement interface {
+	Pos() Position
+}
+
+// CreateNode is `create <type> "name" [assign to [...]] [with properties {...}]`
+type CreateNode struct {
+	At         Position
+	Name       string
+	Type       model.NodeType
+	Parents    []string
+	Properties map[string]string
+}
+
+// SetProperties is `set properties of "name" to {...}`
+type SetProperties struct {
+	At         Position
+	Name       string
+	Properties map[string]string
+}
+
+// Assign is `assign "child" to [...]`
+type Assign struct {
+	At      Position
+	Child   string
+	Parents []string
+}
+
+// Deassign is `deassign "child" from [...]`
+type Deassign struct {
+	At      Position
+	Child   string
+	Parents []string
+}
+
+// Associate is `associate "ua" and "target" with [...]`
+type Associate struct {
+	At         Position
+	UA         string
+	Target     string
+	Operations []string
+}
+
+// Dissociate is `dissociate "ua" and "target"`
+type Dissociate struct {
+	At     Position
+	UA     string
+	Target string
+}
+
+// CreateProhibition is
+// `create prohibition "name" deny <user|user attribute> "subject" access rights [...] on <union|intersection> of [...]`
+type CreateProhibition struct {
+	At           Position
+	Name         string
+	SubjectType  model.NodeType
+	Subject      string
+	Operations   []string
+	Containers   []string
+	Intersection bool
+}
+
+// CreateObligation is
+// `create obligation "name" when "subject" performs [...] on "target" do <type> ["..."|"..."]`
+type CreateObligation struct {
+	At         Position
+	Name       string
+	Subject    string
+	Operations []string
+	Target     string
+	Response   map[string]interface{}
+}
+
+// CreateConstraint is
+// `create constraint "name" separates user attributes [...]` or
+// `create constraint "name" separates operations [...] [on [...]]`
+type CreateConstraint struct {
+	At         Position
+	Name       string
+	Type       model.ConstraintType
+	Attributes []string
+	Operations []string
+	Containers []string
+}
+
+// Delete is `delete <node|prohibition|obligation|constraint> "name"`
+type Delete struct {
+	At   Position
+	Kind string
+	Name string
+}
+
+func (s *CreateNode) Pos() Position        { return s.At }
+func (s *SetProperties) Pos() Position     { return s.At }
+func (s *Assign) Pos() Position            { return s.At }
+func (s *Deassign) Pos() Position          { return s.At }
+func (s *Associate) Pos() Position         { return s.At }
+func (s *Dissociate) Pos() Position        { return s.At }
+func (s *CreateProhibition) Pos() Position { return s.At }
+func (s *CreateObligation) Pos() Position  { return s.At }
+func (s *CreateConstraint) Pos() Position  { return s.At }
+func (s *Delete) Pos() Position            { return s.At }
@@ -0,0 +1,123 @@
+package pml
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+)
+
+// Store is the part of the policy store the executor needs
+type Store interface {
+	Graph(ctx context.Context, revision uint64) (*model.Graph, uint64, error)
+	Commit(ctx context.Context, author, message string, changes []model.Change) (*model.Revision, error)
+}
+
+// Compile translates statements into graph changes. Each statement is
+// validated against a scratch copy of g so errors point at the statement
+// that caused them.
+func Compile(g *model.Graph, stmts []Statement) ([]model.Change, error) {
+	scratch := g.Clone()
+	var out []model.Change
+	for _, stmt := range stmts {
+		changes, err := changesFor(scratch, stmt)
+		if err != nil {
+			return nil, &Error{Pos: stmt.Pos(), Msg: err.Error()}
+		}
+		for _, ch := range changes {
+			if err := scratch.Apply(ch); err != nil {
+				return nil, &Error{Pos: stmt.Pos(), Msg: err.Error()}
+			}
+		}
+		out = append(out, changes...)
+	}
+	return out, nil
+}
+
+// Execute parses src and commits every statement to the store as a single revision
+func Execute(ctx context.Context, store Store, author, message, src string) (*model.Revision, error) {
+	stmts, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	g, _, err := store.Graph(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := Compile(g, stmts)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("policy contains no statements")
+	}
+	return store.Commit(ctx, author, message, changes)
+}
+
+func changesFor(g *model.Graph, stmt Statement) ([]model.Change, error) {
+	switch s := stmt.(type) {
+	case *CreateNode:
+		out := []model.Change{{Op: model.OpCreateNode, Node: &model.Node{Name: s.Name, Type: s.Type, Properties: s.Properties}}}
+		for _, parent := range s.Parents {
+			out = append(out, model.Change{Op: model.OpAssign, Assignment: &model.Assignment{Child: s.Name, Parent: parent}})
+		}
+		return out, nil
+	case *SetProperties:
+		return []model.Change{{Op: model.OpUpdateNode, Node: &model.Node{Name: s.Name, Properties: s.Properties}}}, nil
+	case *Assign:
+		var out []model.Change
+		for _, parent := range s.Parents {
+			out = append(out, model.Change{Op: model.OpAssign, Assignment: &model.Assignment{Child: s.Child, Parent: parent}})
+		}
+		return out, nil
+	case *Deassign:
+		var out []model.Change
+		for _, parent := range s.Parents {
+			out = append(out, model.Change{Op: model.OpDeassign, Assignment: &model.Assignment{Child: s.Child, Parent: parent}})
+		}
+		return out, nil
+	case *Associate:
+		return []model.Change{{Op: model.OpAssociate, Association: &model.Association{UA: s.UA, Target: s.Target, Operations: s.Operations}}}, nil
+	case *Dissociate:
+		return []model.Change{{Op: model.OpDissociate, Association: &model.Association{UA: s.UA, Target: s.Target}}}, nil
+	case *CreateProhibition:
+		if n, ok := g.Node(s.Subject); ok && n.Type != s.SubjectType {
+			return nil, fmt.Errorf("prohibition %q: subject %q is a %s, not a %s", s.Name, s.Subject, n.Type, s.SubjectType)
+		}
+		return []model.Change{{Op: model.OpCreateProhibition, Prohibition: &model.Prohibition{
+			Name:         s.Name,
+			Subject:      s.Subject,
+			Operations:   s.Operations,
+			Containers:   s.Containers,
+			Intersection: s.Intersection,
+		}}}, nil
+	case *CreateObligation:
+		return []model.Change{{Op: model.OpCreateObligation, Obligation: &model.Obligation{
+			Name:       s.Name,
+			Subject:    s.Subject,
+			Operations: s.Operations,
+			Target:     s.Target,
+			Response:   s.Response,
+		}}}, nil
+	case *CreateConstraint:
+		return []model.Change{{Op: model.OpCreateConstraint, Constraint: &model.Constraint{
+			Name:       s.Name,
+			Type:       s.Type,
+			Attributes: s.Attributes,
+			Operations: s.Operations,
+			Containers: s.Containers,
+		}}}, nil
+	case *Delete:
+		switch s.Kind {
+		case "node":
+			return []model.Change{{Op: model.OpDeleteNode, Node: &model.Node{Name: s.Name}}}, nil
+		case "prohibition":
+			return []model.Change{{Op: model.OpDeleteProhibition, Prohibition: &model.Prohibition{Name: s.Name}}}, nil
+		case "obligation":
+			return []model.Change{{Op: model.OpDeleteObligation, Obligation: &model.Obligation{Name: s.Name}}}, nil
+		case "constraint":
+			return []model.Change{{Op: model.OpDeleteConstraint, Constraint: &model.Constraint{Name: s.Name}}}, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported statement %T", stmt)
+}
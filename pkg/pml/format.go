@@ -0,0 +1,265 @@
+package pml
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+)
+
+var typeKeywords = map[model.NodeType]string{
+	model.PolicyClass:     "policy class",
+	model.UserAttribute:   "user attribute",
+	model.ObjectAttribute: "object attribute",
+	model.User:            "user",
+	model.Object:          "object",
+}
+
+// Format renders the graph as canonical PML. Identical graphs produce
+// identical output, and executing the output against an empty store
+// reproduces the graph.
+func Format(g *model.Graph) (string, error) {
+	var b strings.Builder
+
+	section := func(title string) {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "// %s\n", title)
+	}
+
+	nodes := orderNodes(g)
+	for _, typ := range []model.NodeType{model.PolicyClass, model.UserAttribute, model.ObjectAttribute, model.User, model.Object} {
+		if len(nodes[typ]) == 0 {
+			continue
+		}
+		section(typeTitles[typ])
+		for _, n := range nodes[typ] {
+			fmt.Fprintf(&b, "create %s %s", typeKeywords[n.Type], quote(n.Name))
+			if parents := g.Parents(n.Name); len(parents) > 0 {
+				fmt.Fprintf(&b, " assign to %s", quoteList(parents))
+			}
+			if len(n.Properties) > 0 {
+				fmt.Fprintf(&b, " with properties %s", quoteMap(n.Properties))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if assocs := g.Associations(); len(assocs) > 0 {
+		section("Associations")
+		for _, a := range assocs {
+			fmt.Fprintf(&b, "associate %s and %s with %s\n", quote(a.UA), quote(a.Target), quoteList(a.Operations))
+		}
+	}
+
+	if prohibitions := g.Prohibitions(); len(prohibitions) > 0 {
+		section("Prohibitions")
+		for _, p := range prohibitions {
+			subject, _ := g.Node(p.Subject)
+			set := "union"
+			if p.Intersection {
+				set = "intersection"
+			}
+			fmt.Fprintf(&b, "create prohibition %s deny %s %s access rights %s on %s of %s\n",
+				quote(p.Name), typeKeywords[subject.Type], quote(p.Subject), quoteList(p.Operations), set, quoteList(p.Containers))
+		}
+	}
+
+	if obligations := g.Obligations(); len(obligations) > 0 {
+		section("Obligations")
+		for _, o := range obligations {
+			response, err := formatResponse(o.Response)
+			if err != nil {
+				return "", fmt.Errorf("obligation %q: %w", o.Name, err)
+			}
+			fmt.Fprintf(&b, "create obligation %s when %s performs %s on %s do %s\n",
+				quote(o.Name), quote(o.Subject), quoteList(o.Operations), quote(o.Target), response)
+		}
+	}
+
+	if constraints := g.Constraints(); len(constraints) > 0 {
+		section("Constraints")
+		for _, c := range constraints {
+			if c.Type == model.StaticSoD {
+				fmt.Fprintf(&b, "create constraint %s separates user attributes %s\n", quote(c.Name), quoteList(c.Attributes))
+				continue
+			}
+			fmt.Fprintf(&b, "create constraint %s separates operations %s", quote(c.Name), quoteList(c.Operations))
+			if len(c.Containers) > 0 {
+				fmt.Fprintf(&b, " on %s", quoteList(c.Containers))
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String(), nil
+}
+
+var typeTitles = map[model.NodeType]string{
+	model.PolicyClass:     "Policy classes",
+	model.UserAttribute:   "User attributes",
+	model.ObjectAttribute: "Object attributes",
+	model.User:            "Users",
+	model.Object:          "Objects",
+}
+
+// orderNodes groups nodes by type, ordering attributes so every parent is
+// created before its children, with ties broken by name
+func orderNodes(g *model.Graph) map[model.NodeType][]model.Node {
+	out := map[model.NodeType][]model.Node{}
+	byType := map[model.NodeType][]model.Node{}
+	for _, n := range g.Nodes() {
+		byType[n.Type] = append(byType[n.Type], n)
+	}
+
+	for typ, nodes := range byType {
+		if typ != model.UserAttribute && typ != model.ObjectAttribute {
+			out[typ] = nodes
+			continue
+		}
+
+		// Kahn's algorithm over same-typed parents
+		pending := map[string]int{}
+		index := map[string]model.Node{}
+		for _, n := range nodes {
+			index[n.Name] = n
+		}
+		for _, n := range nodes {
+			for _, p := range g.Parents(n.Name) {
+				if _, ok := index[p]; ok {
+					pending[n.Name]++
+				}
+			}
+		}
+		var ready []string
+		for _, n := range nodes {
+			if pending[n.Name] == 0 {
+				ready = append(ready, n.Name)
+			}
+		}
+		for len(ready) > 0 {
+			sort.Strings(ready)
+			name := ready[0]
+			ready = ready[1:]
+			out[typ] = append(out[typ], index[name])
+			for _, c := range g.Children(name) {
+				if _, ok := index[c]; !ok {
+					continue
+				}
+				pending[c]--
+				if pending[c] == 0 {
+					ready = append(ready, c)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// formatResponse renders an obligation response using the shortest syntax
+// the parser accepts for it
+func formatResponse(response map[string]interface{}) (string, error) {
+	typ, ok := response["type"].(string)
+	if !ok || !isIdentifier(typ) {
+		return "", fmt.Errorf("response type %v is not a valid identifier", response["type"])
+	}
+
+	args := map[string]interface{}{}
+	for k, v := range response {
+		if k == "type" {
+			continue
+		}
+		switch val := v.(type) {
+		case string, bool, float64:
+			args[k] = val
+		case int:
+			args[k] = float64(val)
+		case []string:
+			args[k] = val
+		case []interface{}:
+			list := make([]string, 0, len(val))
+			for _, item := range val {
+				s, ok := item.(string)
+				if !ok {
+					return "", fmt.Errorf("response field %q must contain only strings", k)
+				}
+				list = append(list, s)
+			}
+			args[k] = list
+		default:
+			return "", fmt.Errorf("response field %q must be a string, number, boolean or list of strings", k)
+		}
+	}
+
+	if len(args) == 0 {
+		return typ, nil
+	}
+	if len(args) == 1 {
+		if fields, ok := args["fields"].([]string); ok {
+			return typ + " " + quoteList(fields), nil
+		}
+		if msg, ok := args["message"].(string); ok {
+			return typ + " " + quote(msg), nil
+		}
+	}
+
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		switch val := args[k].(type) {
+		case string:
+			parts = append(parts, quote(k)+": "+quote(val))
+		case []string:
+			parts = append(parts, quote(k)+": "+quoteList(val))
+		case bool:
+			parts = append(parts, quote(k)+": "+strconv.FormatBool(val))
+		case float64:
+			parts = append(parts, quote(k)+": "+strconv.FormatFloat(val, 'g', -1, 64))
+		}
+	}
+	return typ + " {" + strings.Join(parts, ", ") + "}", nil
+}
+
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
+
+func quoteList(list []string) string {
+	parts := make([]string, len(list))
+	for i, s := range list {
+		parts[i] = quote(s)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+func quoteMap(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = quote(k) + ": " + quote(m[k])
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if i == 0 && !isIdentStart(r) || i > 0 && !isIdentPart(r) {
+			return false
+		}
+	}
+	return true
+}
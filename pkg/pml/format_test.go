@@ -0,0 +1,135 @@
+package pml
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+)
+
+// compile builds a graph from PML source
+func compile(t *testing.T, src string) *model.Graph {
+	t.Helper()
+	stmts, err := Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := model.NewGraph()
+	changes, err := Compile(g, stmts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ch := range changes {
+		if err := g.Apply(ch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return g
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	hospital, err := os.ReadFile("../../policies/hospital.pml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{name: "empty", src: ""},
+		{name: "hospital", src: string(hospital)},
+		{
+			name: "attributes named against their hierarchy",
+			src: `create PC "pc"
+create OA "b" in ["pc"]
+create OA "a" in ["b"]
+create OA "c" in ["a", "pc"]
+create O "o" in ["c"] with properties {"type": "record", "owner": "a \"quoted\"\tname"}`,
+		},
+		{
+			name: "intersection prohibition on a user",
+			src: `create PC "pc"
+create UA "staff" in ["pc"]
+create U "alice" in ["staff"]
+create OA "x" in ["pc"]
+create OA "y" in ["pc"]
+create prohibition "p" deny user "alice" access rights ["write"] on intersection of ["x", "y"]`,
+		},
+		{
+			name: "obligation responses",
+			src: `create PC "pc"
+create UA "staff" in ["pc"]
+create OA "records" in ["pc"]
+create obligation "bare" when "staff" performs ["read"] on "records" do audit
+create obligation "fields" when "staff" performs ["read"] on "records" do mask ["ssn"]
+create obligation "message" when "staff" performs ["read"] on "records" do log "line\nbreak"
+create obligation "scalars" when "staff" performs ["read"] on "records" do rate_limit {"max": 10, "ratio": 0.25, "big": 1e21, "strict": true, "dry_run": false, "tags": [], "scope": "user"}`,
+		},
+		{
+			name: "dynamic constraints",
+			src: `create PC "pc"
+create OA "claims" in ["pc"]
+create constraint "anywhere" separates operations ["submit", "approve"]
+create constraint "on_claims" separates operations ["submit", "approve"] on ["claims"]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := compile(t, tt.src)
+			out, err := Format(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			again := compile(t, out)
+			if d := model.Diff(g, again); len(d) > 0 {
+				t.Fatalf("formatted policy differs by %+v:\n%s", d, out)
+			}
+			// The output is canonical, so formatting it again is a no-op
+			if twice, err := Format(again); err != nil || twice != out {
+				t.Fatalf("formatting is not stable (%v):\n%s\nthen\n%s", err, out, twice)
+			}
+		})
+	}
+}
+
+func TestFormatResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		response map[string]interface{}
+		want     string
+		err      string
+	}{
+		{name: "type only", response: map[string]interface{}{"type": "audit"}, want: "audit"},
+		{name: "fields", response: map[string]interface{}{"type": "mask", "fields": []interface{}{"ssn"}}, want: `mask ["ssn"]`},
+		{name: "message", response: map[string]interface{}{"type": "log", "message": "hi"}, want: `log "hi"`},
+		{
+			name:     "arguments in key order",
+			response: map[string]interface{}{"type": "limit", "max": 10.0, "on": true, "scope": "user", "ids": []string{"a"}},
+			want:     `limit {"ids": ["a"], "max": 10, "on": true, "scope": "user"}`,
+		},
+		{name: "fields with another argument", response: map[string]interface{}{"type": "mask", "fields": []string{"a"}, "on": false}, want: `mask {"fields": ["a"], "on": false}`},
+		{name: "integer", response: map[string]interface{}{"type": "limit", "max": 3}, want: `limit {"max": 3}`},
+		{name: "fraction and exponent", response: map[string]interface{}{"type": "limit", "r": -0.5, "big": 1e21}, want: `limit {"big": 1e+21, "r": -0.5}`},
+		{name: "invalid type", response: map[string]interface{}{"type": "not valid"}, err: "not a valid identifier"},
+		{name: "nested object", response: map[string]interface{}{"type": "x", "a": map[string]interface{}{}}, err: `response field "a" must be a string, number, boolean or list of strings`},
+		{name: "list of numbers", response: map[string]interface{}{"type": "x", "a": []interface{}{1.0}}, err: `response field "a" must contain only strings`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formatResponse(tt.response)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("formatResponse() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("formatResponse() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
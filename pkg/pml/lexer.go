@@ -0,0 +1,278 @@
+package pml
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Position is a 1-based location in the source
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
+// Error is a syntax or execution error tied to a source location
+type Error struct {
+	Pos Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokLBrack
+	tokRBrack
+	tokLBrace
+	tokRBrace
+	tokComma
+	tokColon
+	tokSemi
+)
+
+var tokenNames = map[tokenKind]string{
+	tokEOF:    "end of file",
+	tokIdent:  "keyword",
+	tokString: "string",
+	tokNumber: "number",
+	tokLBrack: "'['",
+	tokRBrack: "']'",
+	tokLBrace: "'{'",
+	tokRBrace: "'}'",
+	tokComma:  "','",
+	tokColon:  "':'",
+	tokSemi:   "';'",
+}
+
+var punctuation = map[rune]tokenKind{
+	'[': tokLBrack, ']': tokRBrack, '{': tokLBrace, '}': tokRBrace,
+	',': tokComma, ':': tokColon, ';': tokSemi,
+}
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  Position
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokIdent:
+		return fmt.Sprintf("%q", t.text)
+	case tokString:
+		return fmt.Sprintf("string %q", t.text)
+	case tokNumber:
+		return fmt.Sprintf("number %s", t.text)
+	default:
+		return tokenNames[t.kind]
+	}
+}
+
+// lexer splits PML source into tokens. Keywords are bare words, names are
+// double-quoted strings, numbers are written as in JSON, and comments use //
+// or /* */.
+type lexer struct {
+	src  []rune
+	off  int
+	line int
+	col  int
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{src: []rune(src), line: 1, col: 1}
+}
+
+func (l *lexer) tokens() ([]token, error) {
+	var out []token
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, tok)
+		if tok.kind == tokEOF {
+			return out, nil
+		}
+	}
+}
+
+func (l *lexer) peek(n int) rune {
+	if l.off+n >= len(l.src) {
+		return 0
+	}
+	return l.src[l.off+n]
+}
+
+func (l *lexer) advance() rune {
+	r := l.src[l.off]
+	l.off++
+	if r == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	return r
+}
+
+func (l *lexer) pos() Position {
+	return Position{Line: l.line, Column: l.col}
+}
+
+func (l *lexer) next() (token, error) {
+	if err := l.skipSpace(); err != nil {
+		return token{}, err
+	}
+	start := l.pos()
+	if l.off >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+
+	r := l.peek(0)
+	if kind, ok := punctuation[r]; ok {
+		l.advance()
+		return token{kind: kind, text: string(r), pos: start}, nil
+	}
+	if r == '"' {
+		return l.string(start)
+	}
+	if r == '-' || unicode.IsDigit(r) {
+		return l.number(start)
+	}
+	if isIdentStart(r) {
+		var b strings.Builder
+		for l.off < len(l.src) && isIdentPart(l.peek(0)) {
+			b.WriteRune(l.advance())
+		}
+		return token{kind: tokIdent, text: b.String(), pos: start}, nil
+	}
+	return token{}, &Error{Pos: start, Msg: fmt.Sprintf("unexpected character %q", r)}
+}
+
+func (l *lexer) skipSpace() error {
+	for l.off < len(l.src) {
+		r := l.peek(0)
+		switch {
+		case unicode.IsSpace(r):
+			l.advance()
+		case r == '/' && l.peek(1) == '/':
+			for l.off < len(l.src) && l.peek(0) != '\n' {
+				l.advance()
+			}
+		case r == '/' && l.peek(1) == '*':
+			start := l.pos()
+			l.advance()
+			l.advance()
+			for {
+				if l.off >= len(l.src) {
+					return &Error{Pos: start, Msg: "unterminated comment"}
+				}
+				if l.peek(0) == '*' && l.peek(1) == '/' {
+					l.advance()
+					l.advance()
+					break
+				}
+				l.advance()
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (l *lexer) string(start Position) (token, error) {
+	l.advance()
+	var b strings.Builder
+	for {
+		if l.off >= len(l.src) || l.peek(0) == '\n' {
+			return token{}, &Error{Pos: start, Msg: "unterminated string"}
+		}
+		r := l.advance()
+		switch r {
+		case '"':
+			return token{kind: tokString, text: b.String(), pos: start}, nil
+		case '\\':
+			if l.off >= len(l.src) {
+				return token{}, &Error{Pos: start, Msg: "unterminated string"}
+			}
+			esc := l.advance()
+			switch esc {
+			case '"', '\\':
+				b.WriteRune(esc)
+			case 'n':
+				b.WriteRune('\n')
+			case 't':
+				b.WriteRune('\t')
+			default:
+				return token{}, &Error{Pos: Position{Line: l.line, Column: l.col - 2}, Msg: fmt.Sprintf("unknown escape sequence \\%c", esc)}
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+// number scans a JSON number: an optional minus sign, digits, an optional
+// fraction and an optional exponent
+func (l *lexer) number(start Position) (token, error) {
+	var b strings.Builder
+	digits := func() int {
+		n := 0
+		for l.off < len(l.src) && unicode.IsDigit(l.peek(0)) {
+			b.WriteRune(l.advance())
+			n++
+		}
+		return n
+	}
+	invalid := func() (token, error) {
+		return token{}, &Error{Pos: start, Msg: fmt.Sprintf("invalid number %q", b.String())}
+	}
+
+	if l.peek(0) == '-' {
+		b.WriteRune(l.advance())
+	}
+	if digits() == 0 {
+		return invalid()
+	}
+	if l.off < len(l.src) && l.peek(0) == '.' {
+		b.WriteRune(l.advance())
+		if digits() == 0 {
+			return invalid()
+		}
+	}
+	if l.off < len(l.src) && (l.peek(0) == 'e' || l.peek(0) == 'E') {
+		b.WriteRune(l.advance())
+		if l.peek(0) == '+' || l.peek(0) == '-' {
+			b.WriteRune(l.advance())
+		}
+		if digits() == 0 {
+			return invalid()
+		}
+	}
+	if l.off < len(l.src) && isIdentPart(l.peek(0)) {
+		b.WriteRune(l.advance())
+		return invalid()
+	}
+	return token{kind: tokNumber, text: b.String(), pos: start}, nil
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,583 @@
+package pml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+)
+
+// Parse parses PML source into statements
+func Parse(src string) ([]Statement, error) {
+	toks, err := newLexer(src).tokens()
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	return p.parse()
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return &Error{Pos: t.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// isKeyword reports whether the next token is the given keyword
+func (p *parser) isKeyword(kw string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && strings.EqualFold(t.text, kw)
+}
+
+func (p *parser) keyword(kws ...string) (token, error) {
+	var first token
+	for i, kw := range kws {
+		t := p.next()
+		if i == 0 {
+			first = t
+		}
+		if t.kind != tokIdent || !strings.EqualFold(t.text, kw) {
+			return t, p.errorf(t, "expected %q, found %s", strings.Join(kws, " "), t)
+		}
+	}
+	return first, nil
+}
+
+func (p *parser) expect(kind tokenKind) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, p.errorf(t, "expected %s, found %s", tokenNames[kind], t)
+	}
+	return t, nil
+}
+
+func (p *parser) str() (string, error) {
+	t, err := p.expect(tokString)
+	if err != nil {
+		return "", err
+	}
+	if t.text == "" {
+		return "", p.errorf(t, "name must not be empty")
+	}
+	return t.text, nil
+}
+
+// list parses ["a", "b", ...]
+func (p *parser) list() ([]string, error) {
+	if _, err := p.expect(tokLBrack); err != nil {
+		return nil, err
+	}
+	var out []string
+	for p.peek().kind != tokRBrack {
+		s, err := p.str()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+		if p.peek().kind != tokComma {
+			break
+		}
+		p.next()
+	}
+	if _, err := p.expect(tokRBrack); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// object parses {"key": "value" | number | true | false | [...], ...}
+func (p *parser) object() (map[string]interface{}, error) {
+	if _, err := p.expect(tokLBrace); err != nil {
+		return nil, err
+	}
+	out := map[string]interface{}{}
+	for p.peek().kind != tokRBrace {
+		kt := p.peek()
+		key, err := p.str()
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := out[key]; dup {
+			return nil, p.errorf(kt, "duplicate key %q", key)
+		}
+		if _, err := p.expect(tokColon); err != nil {
+			return nil, err
+		}
+		switch p.peek().kind {
+		case tokLBrack:
+			l, err := p.list()
+			if err != nil {
+				return nil, err
+			}
+			if l == nil {
+				// an empty list, not null, as JSON decodes []
+				l = []string{}
+			}
+			out[key] = l
+		case tokNumber:
+			t := p.next()
+			f, err := strconv.ParseFloat(t.text, 64)
+			if err != nil {
+				return nil, p.errorf(t, "invalid number %s", t.text)
+			}
+			out[key] = f
+		case tokIdent:
+			t := p.next()
+			switch t.text {
+			case "true":
+				out[key] = true
+			case "false":
+				out[key] = false
+			default:
+				return nil, p.errorf(t, "expected value, found %s", t)
+			}
+		default:
+			t, err := p.expect(tokString)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = t.text
+		}
+		if p.peek().kind != tokComma {
+			break
+		}
+		p.next()
+	}
+	if _, err := p.expect(tokRBrace); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// properties parses an object whose values are all strings
+func (p *parser) properties() (map[string]string, error) {
+	start := p.peek()
+	obj, err := p.object()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(obj))
+	for k, v := range obj {
+		s, ok := v.(string)
+		if !ok {
+			return nil, p.errorf(start, "property %q must be a string", k)
+		}
+		out[k] = s
+	}
+	return out, nil
+}
+
+// nodeType parses a node type phrase such as "user attribute" or "UA"
+func (p *parser) nodeType() (model.NodeType, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return "", p.errorf(t, "expected node type, found %s", t)
+	}
+	switch strings.ToLower(t.text) {
+	case "pc":
+		return model.PolicyClass, nil
+	case "ua":
+		return model.UserAttribute, nil
+	case "oa":
+		return model.ObjectAttribute, nil
+	case "u":
+		return model.User, nil
+	case "o":
+		return model.Object, nil
+	case "policy":
+		if _, err := p.keyword("class"); err != nil {
+			return "", err
+		}
+		return model.PolicyClass, nil
+	case "user":
+		if p.isKeyword("attribute") {
+			p.next()
+			return model.UserAttribute, nil
+		}
+		return model.User, nil
+	case "object":
+		if p.isKeyword("attribute") {
+			p.next()
+			return model.ObjectAttribute, nil
+		}
+		return model.Object, nil
+	}
+	return "", p.errorf(t, "unknown node type %s", t)
+}
+
+func (p *parser) parse() ([]Statement, error) {
+	var stmts []Statement
+	for {
+		for p.peek().kind == tokSemi {
+			p.next()
+		}
+		if p.peek().kind == tokEOF {
+			return stmts, nil
+		}
+		stmt, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+	}
+}
+
+func (p *parser) statement() (Statement, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return nil, p.errorf(t, "expected statement, found %s", t)
+	}
+	switch strings.ToLower(t.text) {
+	case "create":
+		switch {
+		case p.isKeyword("prohibition"):
+			p.next()
+			return p.createProhibition(t.pos)
+		case p.isKeyword("obligation"):
+			p.next()
+			return p.createObligation(t.pos)
+		case p.isKeyword("constraint"):
+			p.next()
+			return p.createConstraint(t.pos)
+		default:
+			return p.createNode(t.pos)
+		}
+	case "set":
+		return p.setProperties(t.pos)
+	case "assign":
+		return p.assign(t.pos)
+	case "deassign":
+		return p.deassign(t.pos)
+	case "associate":
+		return p.associate(t.pos)
+	case "dissociate":
+		return p.dissociate(t.pos)
+	case "delete":
+		return p.delete(t.pos)
+	}
+	return nil, p.errorf(t, "unknown statement %s", t)
+}
+
+func (p *parser) createNode(at Position) (Statement, error) {
+	typ, err := p.nodeType()
+	if err != nil {
+		return nil, err
+	}
+	name, err := p.str()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &CreateNode{At: at, Name: name, Type: typ}
+
+	// "assign to" needs two tokens of lookahead so that a following
+	// assign statement is not mistaken for the parent clause
+	assignTo := p.isKeyword("assign") && p.toks[p.pos+1].kind == tokIdent && strings.EqualFold(p.toks[p.pos+1].text, "to")
+	if assignTo || p.isKeyword("in") {
+		p.next()
+		if assignTo {
+			p.next()
+		}
+		if stmt.Parents, err = p.list(); err != nil {
+			return nil, err
+		}
+	}
+	if typ == model.PolicyClass && len(stmt.Parents) > 0 {
+		return nil, &Error{Pos: at, Msg: "a policy class cannot be assigned"}
+	}
+
+	if p.isKeyword("with") {
+		if _, err := p.keyword("with", "properties"); err != nil {
+			return nil, err
+		}
+		if stmt.Properties, err = p.properties(); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+func (p *parser) setProperties(at Position) (Statement, error) {
+	if _, err := p.keyword("properties", "of"); err != nil {
+		return nil, err
+	}
+	name, err := p.str()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.keyword("to"); err != nil {
+		return nil, err
+	}
+	props, err := p.properties()
+	if err != nil {
+		return nil, err
+	}
+	return &SetProperties{At: at, Name: name, Properties: props}, nil
+}
+
+func (p *parser) assign(at Position) (Statement, error) {
+	child, err := p.str()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.keyword("to"); err != nil {
+		return nil, err
+	}
+	parents, err := p.list()
+	if err != nil {
+		return nil, err
+	}
+	return &Assign{At: at, Child: child, Parents: parents}, nil
+}
+
+func (p *parser) deassign(at Position) (Statement, error) {
+	child, err := p.str()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.keyword("from"); err != nil {
+		return nil, err
+	}
+	parents, err := p.list()
+	if err != nil {
+		return nil, err
+	}
+	return &Deassign{At: at, Child: child, Parents: parents}, nil
+}
+
+func (p *parser) associate(at Position) (Statement, error) {
+	ua, err := p.str()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.keyword("and"); err != nil {
+		return nil, err
+	}
+	target, err := p.str()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.keyword("with"); err != nil {
+		return nil, err
+	}
+	lt := p.peek()
+	ops, err := p.list()
+	if err != nil {
+		return nil, err
+	}
+	if len(ops) == 0 {
+		return nil, p.errorf(lt, "access rights must not be empty")
+	}
+	return &Associate{At: at, UA: ua, Target: target, Operations: ops}, nil
+}
+
+func (p *parser) dissociate(at Position) (Statement, error) {
+	ua, err := p.str()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.keyword("and"); err != nil {
+		return nil, err
+	}
+	target, err := p.str()
+	if err != nil {
+		return nil, err
+	}
+	return &Dissociate{At: at, UA: ua, Target: target}, nil
+}
+
+func (p *parser) createProhibition(at Position) (Statement, error) {
+	name, err := p.str()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.keyword("deny"); err != nil {
+		return nil, err
+	}
+	tt := p.peek()
+	typ, err := p.nodeType()
+	if err != nil {
+		return nil, err
+	}
+	if typ != model.User && typ != model.UserAttribute {
+		return nil, p.errorf(tt, "prohibition subject must be a user or user attribute")
+	}
+	subject, err := p.str()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.keyword("access", "rights"); err != nil {
+		return nil, err
+	}
+	lt := p.peek()
+	ops, err := p.list()
+	if err != nil {
+		return nil, err
+	}
+	if len(ops) == 0 {
+		return nil, p.errorf(lt, "access rights must not be empty")
+	}
+	if _, err := p.keyword("on"); err != nil {
+		return nil, err
+	}
+	stmt := &CreateProhibition{At: at, Name: name, SubjectType: typ, Subject: subject, Operations: ops}
+	switch {
+	case p.isKeyword("union"):
+		if _, err := p.keyword("union", "of"); err != nil {
+			return nil, err
+		}
+	case p.isKeyword("intersection"):
+		if _, err := p.keyword("intersection", "of"); err != nil {
+			return nil, err
+		}
+		stmt.Intersection = true
+	}
+	ct := p.peek()
+	if stmt.Containers, err = p.list(); err != nil {
+		return nil, err
+	}
+	if len(stmt.Containers) == 0 {
+		return nil, p.errorf(ct, "prohibition containers must not be empty")
+	}
+	return stmt, nil
+}
+
+func (p *parser) createObligation(at Position) (Statement, error) {
+	name, err := p.str()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.keyword("when"); err != nil {
+		return nil, err
+	}
+	subject, err := p.str()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.keyword("performs"); err != nil {
+		return nil, err
+	}
+	lt := p.peek()
+	ops, err := p.list()
+	if err != nil {
+		return nil, err
+	}
+	if len(ops) == 0 {
+		return nil, p.errorf(lt, "operations must not be empty")
+	}
+	if _, err := p.keyword("on"); err != nil {
+		return nil, err
+	}
+	target, err := p.str()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.keyword("do"); err != nil {
+		return nil, err
+	}
+
+	// The response is a type keyword followed by an optional field list,
+	// message string or argument object
+	rt := p.next()
+	if rt.kind != tokIdent {
+		return nil, p.errorf(rt, "expected obligation response type, found %s", rt)
+	}
+	response := map[string]interface{}{}
+	switch p.peek().kind {
+	case tokLBrack:
+		fields, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		response["fields"] = fields
+	case tokString:
+		response["message"] = p.next().text
+	case tokLBrace:
+		if response, err = p.object(); err != nil {
+			return nil, err
+		}
+		if _, ok := response["type"]; ok {
+			return nil, p.errorf(rt, "response arguments must not set \"type\"")
+		}
+	}
+	response["type"] = rt.text
+
+	return &CreateObligation{At: at, Name: name, Subject: subject, Operations: ops, Target: target, Response: response}, nil
+}
+
+func (p *parser) createConstraint(at Position) (Statement, error) {
+	name, err := p.str()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.keyword("separates"); err != nil {
+		return nil, err
+	}
+	stmt := &CreateConstraint{At: at, Name: name}
+	switch {
+	case p.isKeyword("user"):
+		if _, err := p.keyword("user", "attributes"); err != nil {
+			return nil, err
+		}
+		lt := p.peek()
+		if stmt.Attributes, err = p.list(); err != nil {
+			return nil, err
+		}
+		if len(stmt.Attributes) < 2 {
+			return nil, p.errorf(lt, "a constraint must separate at least two user attributes")
+		}
+		stmt.Type = model.StaticSoD
+	case p.isKeyword("operations"):
+		p.next()
+		lt := p.peek()
+		if stmt.Operations, err = p.list(); err != nil {
+			return nil, err
+		}
+		if len(stmt.Operations) < 2 {
+			return nil, p.errorf(lt, "a constraint must separate at least two operations")
+		}
+		stmt.Type = model.DynamicSoD
+		if p.isKeyword("on") {
+			p.next()
+			ct := p.peek()
+			if stmt.Containers, err = p.list(); err != nil {
+				return nil, err
+			}
+			if len(stmt.Containers) == 0 {
+				return nil, p.errorf(ct, "constraint containers must not be empty")
+			}
+		}
+	default:
+		t := p.next()
+		return nil, p.errorf(t, "expected \"user attributes\" or \"operations\", found %s", t)
+	}
+	return stmt, nil
+}
+
+func (p *parser) delete(at Position) (Statement, error) {
+	t := p.next()
+	kind := strings.ToLower(t.text)
+	if t.kind != tokIdent || (kind != "node" && kind != "prohibition" && kind != "obligation" && kind != "constraint") {
+		return nil, p.errorf(t, "expected node, prohibition, obligation or constraint, found %s", t)
+	}
+	name, err := p.str()
+	if err != nil {
+		return nil, err
+	}
+	return &Delete{At: at, Kind: kind, Name: name}, nil
+}
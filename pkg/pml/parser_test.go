@@ -0,0 +1,139 @@
+package pml
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+)
+
+func TestParse(t *testing.T) {
+	at := func(line, column int) Position { return Position{Line: line, Column: column} }
+	tests := []struct {
+		name string
+		src  string
+		want []Statement
+	}{
+		{name: "empty", src: "  // nothing\n/* at all */;;", want: nil},
+		{
+			name: "create node",
+			src:  `create object attribute "records" assign to ["hospital", "ward"] with properties {"kind": "patient_record"}`,
+			want: []Statement{&CreateNode{At: at(1, 1), Name: "records", Type: model.ObjectAttribute, Parents: []string{"hospital", "ward"}, Properties: map[string]string{"kind": "patient_record"}}},
+		},
+		{
+			name: "short node types and in",
+			src:  "create PC \"pc\"\ncreate UA \"staff\" in [\"pc\"]",
+			want: []Statement{
+				&CreateNode{At: at(1, 1), Name: "pc", Type: model.PolicyClass},
+				&CreateNode{At: at(2, 1), Name: "staff", Type: model.UserAttribute, Parents: []string{"pc"}},
+			},
+		},
+		{
+			name: "escapes",
+			src:  `create user "a \"b\"\\c\n"`,
+			want: []Statement{&CreateNode{At: at(1, 1), Name: "a \"b\"\\c\n", Type: model.User}},
+		},
+		{
+			name: "assignments and associations",
+			src:  `assign "alice" to ["staff"]; deassign "alice" from ["staff"]; associate "staff" and "records" with ["read", "write"]; dissociate "staff" and "records"`,
+			want: []Statement{
+				&Assign{At: at(1, 1), Child: "alice", Parents: []string{"staff"}},
+				&Deassign{At: at(1, 30), Child: "alice", Parents: []string{"staff"}},
+				&Associate{At: at(1, 63), UA: "staff", Target: "records", Operations: []string{"read", "write"}},
+				&Dissociate{At: at(1, 119), UA: "staff", Target: "records"},
+			},
+		},
+		{
+			name: "set properties",
+			src:  `set properties of "rec" to {"owner": "alice"}`,
+			want: []Statement{&SetProperties{At: at(1, 1), Name: "rec", Properties: map[string]string{"owner": "alice"}}},
+		},
+		{
+			name: "prohibition",
+			src:  `create prohibition "p" deny user attribute "interns" access rights ["write"] on intersection of ["records", "ward"]`,
+			want: []Statement{&CreateProhibition{At: at(1, 1), Name: "p", SubjectType: model.UserAttribute, Subject: "interns", Operations: []string{"write"}, Containers: []string{"records", "ward"}, Intersection: true}},
+		},
+		{
+			name: "obligation with fields",
+			src:  `create obligation "o" when "staff" performs ["read"] on "records" do mask ["ssn"]`,
+			want: []Statement{&CreateObligation{At: at(1, 1), Name: "o", Subject: "staff", Operations: []string{"read"}, Target: "records", Response: map[string]interface{}{"type": "mask", "fields": []string{"ssn"}}}},
+		},
+		{
+			name: "obligation with message",
+			src:  `create obligation "o" when "staff" performs ["read"] on "records" do log "read a record"`,
+			want: []Statement{&CreateObligation{At: at(1, 1), Name: "o", Subject: "staff", Operations: []string{"read"}, Target: "records", Response: map[string]interface{}{"type": "log", "message": "read a record"}}},
+		},
+		{
+			name: "obligation with scalar arguments",
+			src:  `create obligation "o" when "staff" performs ["read"] on "records" do rate_limit {"max": 10, "ratio": -2.5e-1, "strict": true, "dry_run": false, "scope": "user", "tags": []}`,
+			want: []Statement{&CreateObligation{At: at(1, 1), Name: "o", Subject: "staff", Operations: []string{"read"}, Target: "records", Response: map[string]interface{}{
+				"type": "rate_limit", "max": 10.0, "ratio": -0.25, "strict": true, "dry_run": false, "scope": "user", "tags": []string{},
+			}}},
+		},
+		{
+			name: "constraints",
+			src:  "create constraint \"s\" separates user attributes [\"a\", \"b\"]\ncreate constraint \"d\" separates operations [\"submit\", \"approve\"] on [\"claims\"]",
+			want: []Statement{
+				&CreateConstraint{At: at(1, 1), Name: "s", Type: model.StaticSoD, Attributes: []string{"a", "b"}},
+				&CreateConstraint{At: at(2, 1), Name: "d", Type: model.DynamicSoD, Operations: []string{"submit", "approve"}, Containers: []string{"claims"}},
+			},
+		},
+		{
+			name: "delete",
+			src:  `delete obligation "o"`,
+			want: []Statement{&Delete{At: at(1, 1), Kind: "obligation", Name: "o"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Parse() =\n%s\nwant\n%s", dump(got), dump(tt.want))
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{name: "unknown statement", src: `grant "a"`, want: `line 1, column 1: unknown statement "grant"`},
+		{name: "unterminated string", src: `create user "alice`, want: "line 1, column 13: unterminated string"},
+		{name: "unterminated comment", src: "/* open", want: "line 1, column 1: unterminated comment"},
+		{name: "unknown escape", src: `create user "a\qb"`, want: `line 1, column 15: unknown escape sequence \q`},
+		{name: "unexpected character", src: `create user @`, want: `line 1, column 13: unexpected character '@'`},
+		{name: "missing name", src: "create user\n[\"a\"]", want: "line 2, column 1:"},
+		{name: "static constraint of one attribute", src: `create constraint "c" separates user attributes ["a"]`, want: "at least two user attributes"},
+		{name: "dynamic constraint of one operation", src: `create constraint "c" separates operations ["a"]`, want: "at least two operations"},
+		{name: "empty constraint containers", src: `create constraint "c" separates operations ["a", "b"] on []`, want: "containers must not be empty"},
+		{name: "response sets type", src: `create obligation "o" when "s" performs ["r"] on "t" do log {"type": "x"}`, want: `must not set "type"`},
+		{name: "duplicate response key", src: `create obligation "o" when "s" performs ["r"] on "t" do log {"a": "x", "a": "y"}`, want: `duplicate key "a"`},
+		{name: "invalid number", src: `create obligation "o" when "s" performs ["r"] on "t" do log {"a": 1.}`, want: `invalid number "1."`},
+		{name: "bare word value", src: `create obligation "o" when "s" performs ["r"] on "t" do log {"a": yes}`, want: `expected value, found "yes"`},
+		{name: "number property", src: `create object "o" with properties {"size": 1}`, want: `property "size" must be a string`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Parse() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func dump(stmts []Statement) string {
+	var b strings.Builder
+	for _, s := range stmts {
+		fmt.Fprintf(&b, "%+v\n", s)
+	}
+	return b.String()
+}
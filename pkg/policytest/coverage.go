@@ -0,0 +1,77 @@
+package policytest
+
+import (
+	"fmt"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+	"github.com/kumarabd/policy-machine/pkg/service"
+)
+
+// Coverage counts how often the associations and prohibitions of a policy
+// took part in the decisions of its tests. An association takes part when it
+// grants the operation; a prohibition when it denies it.
+type Coverage struct {
+	Policies     []string
+	Associations []Item
+	Prohibitions []Item
+}
+
+// Item is a covered association, named "ua -> target", or prohibition
+type Item struct {
+	Name string
+	Hits int
+}
+
+func newCoverage(policies []string, g *model.Graph) *Coverage {
+	c := &Coverage{Policies: policies}
+	for _, a := range g.Associations() {
+		c.Associations = append(c.Associations, Item{Name: associationName(a.UA, a.Target)})
+	}
+	for _, p := range g.Prohibitions() {
+		c.Prohibitions = append(c.Prohibitions, Item{Name: p.Name})
+	}
+	return c
+}
+
+func (c *Coverage) record(exp *service.Explanation) {
+	for _, pc := range exp.PolicyClasses {
+		for _, g := range pc.Grants {
+			hit(c.Associations, associationName(g.UA, g.Target))
+		}
+	}
+	for _, p := range exp.Prohibitions {
+		hit(c.Prohibitions, p)
+	}
+}
+
+// Covered counts the items exercised at least once
+func Covered(items []Item) int {
+	n := 0
+	for _, item := range items {
+		if item.Hits > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// Percent is the share of items exercised, 100 when there are none
+func Percent(items []Item) float64 {
+	if len(items) == 0 {
+		return 100
+	}
+	return 100 * float64(Covered(items)) / float64(len(items))
+}
+
+func hit(items []Item, name string) {
+	for i := range items {
+		if items[i].Name == name {
+			items[i].Hits++
+			return
+		}
+	}
+}
+
+func associationName(ua, target string) string {
+	return fmt.Sprintf("%s -> %s", ua, target)
+}
@@ -0,0 +1,92 @@
+package policytest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML, one testsuite per suite file.
+// A suite that could not be run is reported as a single errored case.
+func WriteJUnit(w io.Writer, r *Report) error {
+	out := junitSuites{}
+	var total time.Duration
+	for _, s := range r.Suites {
+		js := junitSuite{Name: s.Path, Time: junitTime(s.Duration)}
+		if s.Err != nil {
+			js.Cases = append(js.Cases, junitCase{
+				Name:      "load",
+				Classname: s.Path,
+				Time:      junitTime(0),
+				Error:     &junitMessage{Message: s.Err.Error(), Text: s.Err.Error()},
+			})
+			js.Errors++
+		}
+		for _, c := range s.Cases {
+			jc := junitCase{Name: c.Name, Classname: s.Path, Time: junitTime(c.Duration)}
+			switch {
+			case c.Err != nil:
+				jc.Error = &junitMessage{Message: c.Err.Error(), Text: c.Err.Error()}
+				js.Errors++
+			case c.Failure != "":
+				jc.Failure = &junitMessage{Message: c.Failure, Text: c.Failure}
+				js.Failures++
+			}
+			js.Cases = append(js.Cases, jc)
+		}
+		js.Tests = len(js.Cases)
+		out.Tests += js.Tests
+		out.Failures += js.Failures
+		out.Errors += js.Errors
+		total += s.Duration
+		out.Suites = append(out.Suites, js)
+	}
+	out.Time = junitTime(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,199 @@
+package policytest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+	"github.com/kumarabd/policy-machine/pkg/service"
+)
+
+// Policy decides and explains requests against a loaded graph
+type Policy interface {
+	Explain(ctx context.Context, req service.CheckRequest) (*service.Explanation, error)
+}
+
+// OpenFunc makes a policy of a graph. The returned function releases it.
+type OpenFunc func(g *model.Graph) (Policy, func(), error)
+
+// Report is the outcome of running suites
+type Report struct {
+	Suites []*SuiteResult
+	// Coverage is reported per set of policies, in the order first tested
+	Coverage []*Coverage
+}
+
+// Failed reports whether any suite could not be run or any case failed
+func (r *Report) Failed() bool {
+	for _, s := range r.Suites {
+		if s.Err != nil || s.Failures() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// SuiteResult is the outcome of one suite
+type SuiteResult struct {
+	Path     string
+	Policies []string
+	Cases    []CaseResult
+	Duration time.Duration
+	// Err is set when the suite could not be run
+	Err error
+}
+
+// Failures counts the failed and errored cases
+func (s *SuiteResult) Failures() int {
+	n := 0
+	for _, c := range s.Cases {
+		if c.Failure != "" || c.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// CaseResult is the outcome of one case
+type CaseResult struct {
+	Name     string
+	Duration time.Duration
+	// Failure explains an unexpected decision
+	Failure string
+	// Err is set when no decision could be made
+	Err error
+}
+
+// Run runs every suite file, loading each policy once
+func Run(ctx context.Context, paths []string, open OpenFunc) *Report {
+	r := &Report{}
+	coverage := map[string]*Coverage{}
+	for _, path := range paths {
+		start := time.Now()
+		result := &SuiteResult{Path: path}
+		r.Suites = append(r.Suites, result)
+
+		suite, err := LoadSuite(path)
+		if err != nil {
+			result.Err = err
+			continue
+		}
+		result.Policies = suite.Policies
+		g, err := suite.Graph()
+		if err != nil {
+			result.Err = err
+			continue
+		}
+
+		key := strings.Join(suite.Policies, ",")
+		cov, ok := coverage[key]
+		if !ok {
+			cov = newCoverage(suite.Policies, g)
+			coverage[key] = cov
+			r.Coverage = append(r.Coverage, cov)
+		}
+
+		policy, release, err := open(g)
+		if err != nil {
+			result.Err = err
+			continue
+		}
+		for _, c := range suite.Tests {
+			result.Cases = append(result.Cases, runCase(ctx, policy, c, cov))
+		}
+		release()
+		result.Duration = time.Since(start)
+	}
+	return r
+}
+
+func runCase(ctx context.Context, policy Policy, c Case, cov *Coverage) CaseResult {
+	start := time.Now()
+	result := CaseResult{Name: c.Name}
+	exp, err := policy.Explain(ctx, service.CheckRequest{User: c.User, Object: c.Object, Operation: c.Op})
+	result.Duration = time.Since(start)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	cov.record(exp)
+	result.Failure = check(c, &exp.Decision)
+	return result
+}
+
+// check compares a decision with the expectations of a case, returning why
+// they differ
+func check(c Case, d *service.Decision) string {
+	if d.Allowed != *c.Allow {
+		expected, got := "deny", "allow"
+		if *c.Allow {
+			expected, got = got, expected
+		}
+		return fmt.Sprintf("expected %s, got %s: %s", expected, got, d.Reason)
+	}
+	if c.Reason != "" && !strings.Contains(d.Reason, c.Reason) {
+		return fmt.Sprintf("expected reason containing %q, got %q", c.Reason, d.Reason)
+	}
+	if c.Obligations == nil {
+		return ""
+	}
+
+	// Obligations are compared as JSON values, as the decision reports them
+	var actual []interface{}
+	b, _ := json.Marshal(d.Obligations)
+	_ = json.Unmarshal(b, &actual)
+	if len(c.Obligations) == 0 && len(actual) > 0 {
+		return fmt.Sprintf("expected no obligations, got %s", b)
+	}
+	for _, want := range c.Obligations {
+		var expected interface{}
+		wb, _ := json.Marshal(want)
+		_ = json.Unmarshal(wb, &expected)
+		if !containsMatch(actual, expected) {
+			return fmt.Sprintf("expected obligation %s, got %s", wb, b)
+		}
+	}
+	return ""
+}
+
+// matches reports whether actual has every key of expected with a matching
+// value. Lists match when every expected item matches an actual one.
+func matches(expected, actual interface{}) bool {
+	switch want := expected.(type) {
+	case map[string]interface{}:
+		got, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, v := range want {
+			if !matches(v, got[k]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		got, ok := actual.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, v := range want {
+			if !containsMatch(got, v) {
+				return false
+			}
+		}
+		return true
+	}
+	return fmt.Sprint(expected) == fmt.Sprint(actual)
+}
+
+func containsMatch(items []interface{}, expected interface{}) bool {
+	for _, item := range items {
+		if matches(expected, item) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package policytest
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kumarabd/gokit/logger"
+	"github.com/kumarabd/policy-machine/internal/metrics"
+	"github.com/kumarabd/policy-machine/pkg/model"
+	"github.com/kumarabd/policy-machine/pkg/service"
+	"github.com/kumarabd/policy-machine/pkg/store"
+	"github.com/rs/zerolog"
+)
+
+// open serves a graph from a memory store, as the test command does
+func open(g *model.Graph) (Policy, func(), error) {
+	m, err := metrics.New("test", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	st, err := store.New(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	svc, err := service.New(&logger.Handler{Logger: zerolog.Nop()}, m, st, &service.Config{})
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := svc.Replace(context.Background(), "test", "fixture", nil, g); err != nil {
+		return nil, nil, err
+	}
+	return svc, func() {}, nil
+}
+
+func writeSuite(t *testing.T, dir, name, src string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	policy, err := filepath.Abs("../../policies/hospital.pml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writeSuite(t, dir, "pass_test.yaml", "policy: "+policy+`
+tests:
+  - user: alice
+    object: rec_1
+    op: read
+    allow: true
+    obligations:
+      - type: log
+`)
+	writeSuite(t, dir, "fail_test.yaml", "policy: "+policy+`
+tests:
+  - name: wrong decision
+    user: bob
+    object: rec_2
+    op: read
+    allow: true
+  - name: wrong reason
+    user: bob
+    object: rec_2
+    op: read
+    allow: false
+    reason: doctor_intern_sod
+  - name: missing obligation
+    user: bob
+    object: rec_1
+    op: read
+    allow: true
+    obligations:
+      - type: mask
+        fields: [ssn, address]
+  - name: unexpected obligation
+    user: bob
+    object: rec_1
+    op: read
+    allow: true
+    obligations: []
+  - name: unknown user
+    user: eve
+    object: rec_1
+    op: read
+    allow: true
+`)
+	writeSuite(t, dir, "invalid_test.yaml", "policy: "+policy+"\ntests:\n  - user: alice\n    object: rec_1\n    op: read\n    alow: true\n")
+	writeSuite(t, dir, "notes.yaml", "not a suite")
+
+	files, err := Discover([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("Discover() = %v, want the three suites", files)
+	}
+	r := Run(context.Background(), files, open)
+	if !r.Failed() {
+		t.Error("Failed() = false")
+	}
+
+	results := map[string]*SuiteResult{}
+	for _, s := range r.Suites {
+		results[filepath.Base(s.Path)] = s
+	}
+	if s := results["pass_test.yaml"]; s.Err != nil || s.Failures() != 0 || s.Cases[0].Name != "alice can read rec_1" {
+		t.Errorf("pass_test.yaml: %v %+v", s.Err, s.Cases)
+	}
+	if s := results["invalid_test.yaml"]; s.Err == nil || !strings.Contains(s.Err.Error(), "alow") {
+		t.Errorf("invalid_test.yaml error = %v", s.Err)
+	}
+	fail := results["fail_test.yaml"]
+	if fail.Err != nil || fail.Failures() != 5 {
+		t.Fatalf("fail_test.yaml: %v, %d failures, want 5", fail.Err, fail.Failures())
+	}
+	for _, c := range fail.Cases {
+		if c.Failure == "" && c.Err == nil {
+			t.Errorf("%s passed", c.Name)
+		}
+	}
+
+	// Both runnable suites share the policy and so its coverage
+	if len(r.Coverage) != 1 {
+		t.Fatalf("%d coverage reports, want 1", len(r.Coverage))
+	}
+	cov := r.Coverage[0]
+	if len(cov.Associations) != 2 || Covered(cov.Associations) != 2 || Covered(cov.Prohibitions) != 1 {
+		t.Errorf("coverage %+v", cov)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	var junit junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &junit); err != nil {
+		t.Fatal(err)
+	}
+	if junit.Tests != 7 || junit.Failures+junit.Errors != 6 || len(junit.Suites) != 3 {
+		t.Errorf("junit totals %d tests, %d failures, %d errors in %d suites", junit.Tests, junit.Failures, junit.Errors, len(junit.Suites))
+	}
+}
+
+func TestMatches(t *testing.T) {
+	actual := map[string]interface{}{"type": "mask", "fields": []interface{}{"ssn", "salary"}, "count": float64(2)}
+	tests := []struct {
+		name     string
+		expected interface{}
+		want     bool
+	}{
+		{name: "subset of keys", expected: map[string]interface{}{"type": "mask"}, want: true},
+		{name: "subset of list", expected: map[string]interface{}{"fields": []interface{}{"salary"}}, want: true},
+		{name: "number", expected: map[string]interface{}{"count": float64(2)}, want: true},
+		{name: "other value", expected: map[string]interface{}{"type": "log"}},
+		{name: "missing item", expected: map[string]interface{}{"fields": []interface{}{"ssn", "address"}}},
+		{name: "missing key", expected: map[string]interface{}{"mode": "full"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matches(tt.expected, actual); got != tt.want {
+				t.Fatalf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package policytest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kumarabd/policy-machine/pkg/export"
+	"github.com/kumarabd/policy-machine/pkg/gitops"
+	"github.com/kumarabd/policy-machine/pkg/model"
+	"gopkg.in/yaml.v3"
+)
+
+// Suite is a file of test cases run against a policy
+type Suite struct {
+	// Path is the suite file
+	Path string `yaml:"-"`
+	// Policy is a policy file or directory, relative to the suite file
+	Policy string `yaml:"policy,omitempty"`
+	// Policies are applied in order when the policy is split across files
+	Policies []string `yaml:"policies,omitempty"`
+	Tests    []Case   `yaml:"tests"`
+}
+
+// Case is a request and the decision expected for it
+type Case struct {
+	Name   string `yaml:"name"`
+	User   string `yaml:"user"`
+	Object string `yaml:"object"`
+	Op     string `yaml:"op"`
+	// Allow is the expected decision
+	Allow *bool `yaml:"allow"`
+	// Reason is expected within the reason of the decision
+	Reason string `yaml:"reason,omitempty"`
+	// Obligations must each match an obligation of the decision, where
+	// lists match when their items are all present. An empty list expects
+	// no obligations.
+	Obligations []map[string]interface{} `yaml:"obligations,omitempty"`
+}
+
+// IsSuite reports whether a file holds test cases rather than policy. The
+// GitOps sync skips these files.
+func IsSuite(path string) bool {
+	return strings.HasSuffix(path, "_test.yaml") || strings.HasSuffix(path, "_test.yml")
+}
+
+// Discover lists the suite files among paths, walking directories for
+// files ending in _test.yaml or _test.yml
+func Discover(paths []string) ([]string, error) {
+	var files []string
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, root)
+			continue
+		}
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if !d.IsDir() && IsSuite(path) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// LoadSuite reads a suite file, rejecting unknown keys
+func LoadSuite(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Suite{Path: path}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(s); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid test suite: %w", err)
+	}
+
+	if s.Policy != "" {
+		s.Policies = append([]string{s.Policy}, s.Policies...)
+	}
+	if len(s.Policies) == 0 {
+		return nil, errors.New("invalid test suite: policy is required")
+	}
+	for i, p := range s.Policies {
+		if !filepath.IsAbs(p) {
+			s.Policies[i] = filepath.Join(filepath.Dir(path), p)
+		}
+	}
+	for i, c := range s.Tests {
+		if c.User == "" || c.Object == "" || c.Op == "" || c.Allow == nil {
+			return nil, fmt.Errorf("invalid test suite: test %d: user, object, op and allow are required", i+1)
+		}
+		if c.Name == "" {
+			s.Tests[i].Name = c.title()
+		}
+	}
+	return s, nil
+}
+
+// Graph loads the policies of the suite, one after another, into a graph
+func (s *Suite) Graph() (*model.Graph, error) {
+	var files []string
+	for _, path := range s.Policies {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		// Directories are loaded as the GitOps sync loads them
+		dirFiles, err := gitops.Files(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, dirFiles...)
+	}
+
+	g := model.NewGraph()
+	for _, path := range files {
+		format, err := export.FormatFromPath(path)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := export.Load(g, data, format); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return g, nil
+}
+
+// title describes a case without a name, such as "alice can read rec_1"
+func (c Case) title() string {
+	verb := "cannot"
+	if *c.Allow {
+		verb = "can"
+	}
+	return fmt.Sprintf("%s %s %s %s", c.User, verb, c.Op, c.Object)
+}
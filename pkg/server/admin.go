@@ -0,0 +1,182 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kumarabd/policy-machine/pkg/model"
+	"github.com/kumarabd/policy-machine/pkg/service"
+)
+
+// TransactionRequest is a batch of graph changes committed as one revision
+type TransactionRequest struct {
+	Message string         `json:"message"`
+	Changes []model.Change `json:"changes"`
+}
+
+// GraphResponse is the serialized form of the policy graph
+type GraphResponse struct {
+	Revision uint64 `json:"revision"`
+	model.Document
+}
+
+// errorBody renders a failed commit, identifying the separation of duty
+// constraint when one blocked it
+func errorBody(err error) gin.H {
+	body := gin.H{"error": err.Error()}
+	var cerr *model.ConstraintError
+	if errors.As(err, &cerr) {
+		body["constraint"] = cerr
+	}
+	return body
+}
+
+// commitStatus maps a failed commit onto a status code: 409 when the base
+// revision is no longer the latest, 422 when the changes are invalid and
+// 500 when the store failed
+func commitStatus(err error) int {
+	var (
+		conflict   *model.ConflictError
+		invalid    *model.InvalidChangeError
+		constraint *model.ConstraintError
+	)
+	switch {
+	case errors.As(err, &conflict):
+		return http.StatusConflict
+	case errors.As(err, &invalid), errors.As(err, &constraint), errors.Is(err, model.ErrNoChanges):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// GraphHandler returns the graph, optionally as of a past revision
+func (h *HTTPServer) GraphHandler(c *gin.Context) {
+	asOf, err := queryRevision(c, "as_of")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	g, rev, err := h.service.Graph(c.Request.Context(), asOf)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, GraphResponse{Revision: rev, Document: g.Document()})
+}
+
+// TransactionHandler commits a batch of changes as a new revision
+func (h *HTTPServer) TransactionHandler(c *gin.Context) {
+	var req TransactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rev, err := h.service.Commit(c.Request.Context(), caller(c.Request.Context()), req.Message, req.Changes)
+	if err != nil {
+		c.JSON(commitStatus(err), errorBody(err))
+		return
+	}
+	c.JSON(http.StatusCreated, rev)
+}
+
+// TransactionPreviewHandler reports the access a batch of changes would
+// grant and revoke without committing it. The user, object and operation
+// query parameters narrow the report.
+func (h *HTTPServer) TransactionPreviewHandler(c *gin.Context) {
+	var req TransactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var filter service.ImpactFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preview, err := h.service.Preview(c.Request.Context(), req.Changes, filter)
+	if err != nil {
+		c.JSON(commitStatus(err), errorBody(err))
+		return
+	}
+	c.JSON(http.StatusOK, preview)
+}
+
+// HistoryHandler lists committed revisions in the range (from, to]
+func (h *HTTPServer) HistoryHandler(c *gin.Context) {
+	from, err := queryRevision(c, "from")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := queryRevision(c, "to")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	revisions, err := h.service.History(c.Request.Context(), from, to)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"revisions": revisions})
+}
+
+// RevisionHandler returns a single committed revision
+func (h *HTTPServer) RevisionHandler(c *gin.Context) {
+	rev, err := strconv.ParseUint(c.Param("revision"), 10, 64)
+	if err != nil || rev == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid revision"})
+		return
+	}
+
+	revisions, err := h.service.History(c.Request.Context(), rev-1, rev)
+	if err != nil || len(revisions) != 1 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "revision not found"})
+		return
+	}
+	c.JSON(http.StatusOK, revisions[0])
+}
+
+// DiffHandler returns the changes between two revisions
+func (h *HTTPServer) DiffHandler(c *gin.Context) {
+	from, err := queryRevision(c, "from")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := queryRevision(c, "to")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if c.Query("to") == "" {
+		_, to, _ = h.service.Graph(c.Request.Context(), 0)
+	}
+
+	changes, err := h.service.Diff(c.Request.Context(), from, to)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "changes": changes})
+}
+
+// queryRevision parses an optional revision query parameter
+func queryRevision(c *gin.Context, key string) (uint64, error) {
+	v := c.Query(key)
+	if v == "" {
+		return 0, nil
+	}
+	rev, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s revision %q", key, v)
+	}
+	return rev, nil
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kumarabd/policy-machine/pkg/audit"
+)
+
+// maxDecisionLogUpload bounds the size of an uploaded decision log batch
+// as sent, before decompression
+const maxDecisionLogUpload = 4 << 20
+
+// DecisionLogHandler ingests a batch uploaded by OPA's decision log plugin
+func (h *HTTPServer) DecisionLogHandler(c *gin.Context) {
+	gzipped := strings.EqualFold(c.GetHeader("Content-Encoding"), "gzip")
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxDecisionLogUpload)
+	entries, err := audit.DecodeOPA(body, gzipped)
+	if err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) || errors.Is(err, audit.ErrBatchTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.audit.Append(entries); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// AuditSearchHandler searches the audit log by source, subject, resource,
+// action, decision and a [from, to) time range given in RFC 3339
+func (h *HTTPServer) AuditSearchHandler(c *gin.Context) {
+	q := audit.Query{
+		Source:   c.Query("source"),
+		Subject:  c.Query("subject"),
+		Resource: c.Query("resource"),
+		Action:   c.Query("action"),
+		Decision: c.Query("decision"),
+	}
+	var err error
+	if q.From, err = queryTime(c, "from"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if q.To, err = queryTime(c, "to"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if v := c.Query("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid limit %q", v)})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": h.audit.Search(q)})
+}
+
+// AuditExportHandler streams the audit log as JSON Lines within an
+// optional [from, to) time range
+func (h *HTTPServer) AuditExportHandler(c *gin.Context) {
+	from, err := queryTime(c, "from")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := queryTime(c, "to")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	if err := h.audit.Export(c.Writer, from, to); err != nil {
+		c.Error(err)
+	}
+}
+
+func queryTime(c *gin.Context, key string) (time.Time, error) {
+	v := c.Query(key)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s time %q", key, v)
+	}
+	return t, nil
+}
@@ -0,0 +1,327 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kumarabd/policy-machine/pkg/model"
+	"github.com/kumarabd/policy-machine/pkg/service"
+)
+
+// AuthZEN evaluation semantics for batched evaluations
+const (
+	semanticExecuteAll          = "execute_all"
+	semanticDenyOnFirstDeny     = "deny_on_first_deny"
+	semanticPermitOnFirstPermit = "permit_on_first_permit"
+)
+
+var errAuthZENInvalid = errors.New("subject, resource and action are required")
+
+// AuthZENEntity is an AuthZEN subject or resource. The id names a node in the
+// graph. Evaluations decide on the id alone; searches return the nodes whose
+// "type" property, or "user" and "object" for nodes without one, equals the
+// requested type.
+type AuthZENEntity struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// AuthZENAction names the operation being performed
+type AuthZENAction struct {
+	Name       string                 `json:"name"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// AuthZENRequest is an access evaluation or search request. A numeric
+// "as_of" in the context evaluates against that revision.
+type AuthZENRequest struct {
+	Subject  *AuthZENEntity         `json:"subject,omitempty"`
+	Resource *AuthZENEntity         `json:"resource,omitempty"`
+	Action   *AuthZENAction         `json:"action,omitempty"`
+	Context  map[string]interface{} `json:"context,omitempty"`
+}
+
+// AuthZENEvaluationsRequest batches evaluations. Top level fields are
+// defaults for every entry in Evaluations.
+type AuthZENEvaluationsRequest struct {
+	AuthZENRequest
+	Evaluations []AuthZENRequest       `json:"evaluations,omitempty"`
+	Options     map[string]interface{} `json:"options,omitempty"`
+}
+
+// AuthZENDecision is the result of an access evaluation
+type AuthZENDecision struct {
+	Decision bool                   `json:"decision"`
+	Context  map[string]interface{} `json:"context,omitempty"`
+}
+
+// AuthZENSearchResponse lists the matches of a search
+type AuthZENSearchResponse struct {
+	Results []interface{}          `json:"results"`
+	Page    map[string]interface{} `json:"page"`
+	Context map[string]interface{} `json:"context,omitempty"`
+}
+
+// AuthZENMiddleware echoes X-Request-ID as the AuthZEN API requires
+func AuthZENMiddleware(c *gin.Context) {
+	if id := c.GetHeader("X-Request-ID"); id != "" {
+		c.Header("X-Request-ID", id)
+	}
+	c.Next()
+}
+
+// AuthZENConfigurationHandler serves the PDP metadata document
+func (h *HTTPServer) AuthZENConfigurationHandler(c *gin.Context) {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	base := scheme + "://" + c.Request.Host
+	c.JSON(http.StatusOK, gin.H{
+		"policy_decision_point":       base,
+		"access_evaluation_endpoint":  base + "/access/v1/evaluation",
+		"access_evaluations_endpoint": base + "/access/v1/evaluations",
+		"search_subject_endpoint":     base + "/access/v1/search/subject",
+		"search_resource_endpoint":    base + "/access/v1/search/resource",
+		"search_action_endpoint":      base + "/access/v1/search/action",
+	})
+}
+
+// AuthZENEvaluationHandler evaluates a single access request
+func (h *HTTPServer) AuthZENEvaluationHandler(c *gin.Context) {
+	var req AuthZENRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	check, err := req.checkRequest()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	d, err := h.service.Check(c.Request.Context(), check)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, authZENDecision(d))
+}
+
+// AuthZENEvaluationsHandler evaluates a batch of access requests against one
+// graph revision, honouring the evaluations_semantic option
+func (h *HTTPServer) AuthZENEvaluationsHandler(c *gin.Context) {
+	var req AuthZENEvaluationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Without an evaluations array the request is a single evaluation
+	if len(req.Evaluations) == 0 {
+		check, err := req.AuthZENRequest.checkRequest()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		d, err := h.service.Check(c.Request.Context(), check)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, authZENDecision(d))
+		return
+	}
+
+	semantic := semanticExecuteAll
+	if v, ok := req.Options["evaluations_semantic"].(string); ok {
+		semantic = v
+	}
+	if semantic != semanticExecuteAll && semantic != semanticDenyOnFirstDeny && semantic != semanticPermitOnFirstPermit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown evaluations_semantic " + semantic})
+		return
+	}
+
+	checks := make([]service.CheckRequest, 0, len(req.Evaluations))
+	for _, e := range req.Evaluations {
+		check, err := req.AuthZENRequest.merge(e).checkRequest()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		checks = append(checks, check)
+	}
+
+	// deny_on_first_deny and permit_on_first_permit stop evaluating at the
+	// first deny or permit. The requests are decided one at a time against a
+	// snapshot pinned per revision, so a commit in between cannot change them.
+	var stop func(*service.Decision) bool
+	switch semantic {
+	case semanticDenyOnFirstDeny:
+		stop = func(d *service.Decision) bool { return !d.Allowed }
+	case semanticPermitOnFirstPermit:
+		stop = func(d *service.Decision) bool { return d.Allowed }
+	}
+	decisions, err := h.service.BatchCheckUntil(c.Request.Context(), checks, stop)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	out := make([]AuthZENDecision, 0, len(decisions))
+	for _, d := range decisions {
+		out = append(out, authZENDecision(d))
+	}
+	c.JSON(http.StatusOK, gin.H{"evaluations": out})
+}
+
+// AuthZENSearchSubjectHandler lists the subjects of the requested type that
+// may perform the action on the resource
+func (h *HTTPServer) AuthZENSearchSubjectHandler(c *gin.Context) {
+	var req AuthZENRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Subject == nil || req.Subject.Type == "" || !req.Resource.valid() || req.Action == nil || req.Action.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "subject.type, resource and action are required"})
+		return
+	}
+
+	users, rev, err := h.service.SearchSubjects(c.Request.Context(), req.Resource.ID, req.Action.Name, req.asOf())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	results := []interface{}{}
+	for _, n := range users {
+		if entityType(n) == req.Subject.Type {
+			results = append(results, AuthZENEntity{Type: req.Subject.Type, ID: n.Name})
+		}
+	}
+	c.JSON(http.StatusOK, searchResponse(results, rev))
+}
+
+// AuthZENSearchResourceHandler lists the resources of the requested type the
+// subject may perform the action on
+func (h *HTTPServer) AuthZENSearchResourceHandler(c *gin.Context) {
+	var req AuthZENRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.Subject.valid() || req.Resource == nil || req.Resource.Type == "" || req.Action == nil || req.Action.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "subject, resource.type and action are required"})
+		return
+	}
+
+	objects, rev, err := h.service.SearchResources(c.Request.Context(), req.Subject.ID, req.Action.Name, req.asOf())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	results := []interface{}{}
+	for _, n := range objects {
+		if entityType(n) == req.Resource.Type {
+			results = append(results, AuthZENEntity{Type: req.Resource.Type, ID: n.Name})
+		}
+	}
+	c.JSON(http.StatusOK, searchResponse(results, rev))
+}
+
+// AuthZENSearchActionHandler lists the actions the subject may perform on the resource
+func (h *HTTPServer) AuthZENSearchActionHandler(c *gin.Context) {
+	var req AuthZENRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.Subject.valid() || !req.Resource.valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "subject and resource are required"})
+		return
+	}
+
+	actions, rev, err := h.service.SearchActions(c.Request.Context(), req.Subject.ID, req.Resource.ID, req.asOf())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	results := []interface{}{}
+	for _, a := range actions {
+		results = append(results, AuthZENAction{Name: a})
+	}
+	c.JSON(http.StatusOK, searchResponse(results, rev))
+}
+
+func (e *AuthZENEntity) valid() bool {
+	return e != nil && e.Type != "" && e.ID != ""
+}
+
+// merge fills the fields missing from an evaluation with the request defaults
+func (r AuthZENRequest) merge(e AuthZENRequest) AuthZENRequest {
+	if e.Subject == nil {
+		e.Subject = r.Subject
+	}
+	if e.Resource == nil {
+		e.Resource = r.Resource
+	}
+	if e.Action == nil {
+		e.Action = r.Action
+	}
+	if e.Context == nil {
+		e.Context = r.Context
+	}
+	return e
+}
+
+func (r AuthZENRequest) asOf() uint64 {
+	if v, ok := r.Context["as_of"].(float64); ok && v > 0 {
+		return uint64(v)
+	}
+	return 0
+}
+
+// checkRequest maps the AuthZEN request onto an NGAC decision: the subject
+// is the user, the resource the object and the action the operation
+func (r AuthZENRequest) checkRequest() (service.CheckRequest, error) {
+	if !r.Subject.valid() || !r.Resource.valid() || r.Action == nil || r.Action.Name == "" {
+		return service.CheckRequest{}, errAuthZENInvalid
+	}
+	return service.CheckRequest{
+		User:      r.Subject.ID,
+		Object:    r.Resource.ID,
+		Operation: r.Action.Name,
+		AsOf:      r.asOf(),
+	}, nil
+}
+
+func authZENDecision(d *service.Decision) AuthZENDecision {
+	ctx := map[string]interface{}{
+		"reason_admin": map[string]string{"en": d.Reason},
+		"revision":     d.Revision,
+	}
+	if len(d.Obligations) > 0 {
+		ctx["obligations"] = d.Obligations
+	}
+	return AuthZENDecision{Decision: d.Allowed, Context: ctx}
+}
+
+func searchResponse(results []interface{}, rev uint64) AuthZENSearchResponse {
+	return AuthZENSearchResponse{
+		Results: results,
+		Page:    map[string]interface{}{"next_token": ""},
+		Context: map[string]interface{}{"revision": rev},
+	}
+}
+
+// entityType is the AuthZEN type of a node
+func entityType(n model.Node) string {
+	if t := n.Properties["type"]; t != "" {
+		return t
+	}
+	if n.Type == model.User {
+		return "user"
+	}
+	return "object"
+}
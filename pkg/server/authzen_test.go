@@ -0,0 +1,100 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// authZENCase is a conformance case of test/authzen. Responses match when
+// they have the expected status, the expected fields ignoring decision
+// contexts, and the expected lengths of the listed array fields.
+type authZENCase struct {
+	Name           string                 `json:"name"`
+	Endpoint       string                 `json:"endpoint"`
+	Request        json.RawMessage        `json:"request"`
+	Expected       map[string]interface{} `json:"expected"`
+	ExpectedLength map[string]int         `json:"expected_length"`
+	ExpectedStatus int                    `json:"expected_status"`
+}
+
+// TestAuthZEN runs the conformance cases in test/authzen against
+// policies/hospital.pml
+func TestAuthZEN(t *testing.T) {
+	srv := newTestServer(t, "../../policies/hospital.pml")
+
+	var cases []authZENCase
+	readJSON(t, "../../test/authzen/hospital.json", &cases)
+	if len(cases) == 0 {
+		t.Fatal("no conformance cases")
+	}
+	for i, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, srv.URL+tc.Endpoint, bytes.NewReader(tc.Request))
+			if err != nil {
+				t.Fatal(err)
+			}
+			id := fmt.Sprintf("conformance-%d", i)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Request-ID", id)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			want := tc.ExpectedStatus
+			if want == 0 {
+				want = http.StatusOK
+			}
+			if resp.StatusCode != want {
+				t.Fatalf("status %d, want %d", resp.StatusCode, want)
+			}
+			if got := resp.Header.Get("X-Request-ID"); got != id {
+				t.Errorf("X-Request-ID %q, want %q", got, id)
+			}
+			if want != http.StatusOK {
+				return
+			}
+
+			var body map[string]interface{}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			for key, value := range tc.Expected {
+				if got := withoutContext(body[key]); !reflect.DeepEqual(got, value) {
+					t.Errorf("%s = %v, want %v", key, got, value)
+				}
+			}
+			for key, n := range tc.ExpectedLength {
+				if got, _ := body[key].([]interface{}); len(got) != n {
+					t.Errorf("len(%s) = %d, want %d", key, len(got), n)
+				}
+			}
+		})
+	}
+}
+
+// withoutContext drops the context of a decision or of each decision in a list
+func withoutContext(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			if k != "context" {
+				out[k] = e
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = withoutContext(e)
+		}
+		return out
+	}
+	return v
+}
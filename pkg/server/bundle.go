@@ -0,0 +1,36 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BundleHandler serves the OPA bundle of the policies and the latest graph.
+// OPA polls with If-None-Match and gets 304 until the graph or the
+// policies change.
+func (h *HTTPServer) BundleHandler(c *gin.Context) {
+	g, rev, err := h.service.Graph(c.Request.Context(), 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	tag, err := h.bundler.ETag(rev)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("ETag", tag)
+	if c.GetHeader("If-None-Match") == tag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	data, tag, err := h.bundler.Build(g, rev)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("ETag", tag)
+	c.Data(http.StatusOK, "application/gzip", data)
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kumarabd/gokit/logger"
+	"github.com/kumarabd/policy-machine/internal/authz"
+	"github.com/kumarabd/policy-machine/internal/metrics"
+	"github.com/kumarabd/policy-machine/pkg/pml"
+	"github.com/rs/zerolog"
+)
+
+func TestBundleHandler(t *testing.T) {
+	l := &logger.Handler{Logger: zerolog.Nop()}
+	m, err := metrics.New("test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := newTestService(t, l, m, "../../policies/hospital.pml")
+	policies := t.TempDir()
+	writeRego := func(src string) {
+		if err := os.WriteFile(filepath.Join(policies, "authz.rego"), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeRego("package authz\n")
+	engine := gin.New()
+	engine.GET("/bundles/authz.tar.gz", (&HTTPServer{service: svc, bundler: authz.NewBundler(&authz.Config{Policies: policies})}).BundleHandler)
+
+	get := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/bundles/authz.tar.gz", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("")
+	if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+		t.Fatalf("status %d with %d bytes, want a bundle", rec.Code, rec.Body.Len())
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+
+	tests := []struct {
+		name   string
+		change func()
+		status int
+	}{
+		{name: "unchanged", status: http.StatusNotModified},
+		{
+			name: "graph changed",
+			change: func() {
+				if _, err := pml.Execute(context.Background(), svc, "test", "fixture", "create user \"zed\" assign to [\"doctor\"]\n"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			status: http.StatusOK,
+		},
+		{name: "policy changed", change: func() { writeRego("package authz\n\nallow := true\n") }, status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.change != nil {
+				tt.change()
+			}
+			rec := get(etag)
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d", rec.Code, tt.status)
+			}
+			got := rec.Header().Get("ETag")
+			if (got == etag) != (tt.status == http.StatusNotModified) {
+				t.Fatalf("ETag %s after %s", got, etag)
+			}
+			etag = got
+			if rec := get(etag); rec.Code != http.StatusNotModified {
+				t.Fatalf("status %d for the current ETag, want 304", rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kumarabd/policy-machine/pkg/export"
+)
+
+// ExportHandler renders the graph, or the subgraph under ?pc=, as pml, json or yaml
+func (h *HTTPServer) ExportHandler(c *gin.Context) {
+	format, err := export.ParseFormat(c.DefaultQuery("format", string(export.PML)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	asOf, err := queryRevision(c, "as_of")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	g, rev, err := h.service.Graph(c.Request.Context(), asOf)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if pc := c.Query("pc"); pc != "" {
+		if g, err = g.Subgraph(pc); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	out, err := export.Marshal(g, format)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("X-Policy-Revision", strconv.FormatUint(rev, 10))
+	c.Data(http.StatusOK, format.ContentType(), out)
+}
+
+// ImportHandler replaces the graph with the document in the request body
+func (h *HTTPServer) ImportHandler(c *gin.Context) {
+	format, err := export.ParseFormat(c.DefaultQuery("format", string(export.PML)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	g, err := export.Unmarshal(data, format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rev, err := h.service.Replace(c.Request.Context(), caller(c.Request.Context()), c.Query("message"), nil, g)
+	if err != nil {
+		c.JSON(commitStatus(err), errorBody(err))
+		return
+	}
+	if rev == nil {
+		c.JSON(http.StatusOK, gin.H{"message": "graph already up to date"})
+		return
+	}
+	c.JSON(http.StatusCreated, rev)
+}
@@ -0,0 +1,375 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/gin-gonic/gin"
+	"github.com/kumarabd/policy-machine/pkg/pep"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	// headerObligations carries every obligation of an allowed decision as JSON
+	headerObligations = "x-pm-obligations"
+	// headerMaskFields lists the fields mask obligations require to be hidden
+	headerMaskFields = "x-pm-mask-fields"
+)
+
+// ExtAuthzConfig maps the paths of proxied requests onto resources and
+// selects the trusted source of the subject. Neither is taken from client
+// headers.
+type ExtAuthzConfig struct {
+	// Routes are tried in order; paths matching none are decided on their
+	// last segment
+	Routes  []ExtAuthzRoute `json:"routes,omitempty" yaml:"routes,omitempty"`
+	Subject ExtAuthzSubject `json:"subject,omitempty" yaml:"subject,omitempty"`
+}
+
+// ExtAuthzSubject takes the subject and role from the claims of a verified
+// JWT or of the dynamic metadata another Envoy filter set. Without either
+// every request is refused as unauthenticated.
+type ExtAuthzSubject struct {
+	JWT      JWTConfig      `json:"jwt,omitempty" yaml:"jwt,omitempty"`
+	Metadata MetadataSource `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	// Claim names the subject, sub by default
+	Claim string `json:"claim,omitempty" yaml:"claim,omitempty"`
+	// RoleClaim names the role passed to OPA, role by default
+	RoleClaim string `json:"role_claim,omitempty" yaml:"role_claim,omitempty"`
+}
+
+// MetadataSource reads claims from Envoy's dynamic metadata, which is only
+// sent over gRPC and only for the namespaces listed in the filter's
+// metadata_context_namespaces
+type MetadataSource struct {
+	// Filter is the namespace, such as envoy.filters.http.jwt_authn
+	Filter string `json:"filter" yaml:"filter"`
+	// Key holds the claims in the namespace, the payload_in_metadata of jwt_authn
+	Key string `json:"key" yaml:"key"`
+}
+
+func (m MetadataSource) set() bool {
+	return m.Filter != "" || m.Key != ""
+}
+
+// ExtAuthzRoute maps the paths matching Path onto a resource. Segments of
+// Path starting with ":" match any one segment and a final "*" matches the
+// rest of the path. Resource is a fixed object or names a parameter such as
+// ":id", and defaults to ":resource_id". Action replaces the action of the
+// HTTP method when set.
+type ExtAuthzRoute struct {
+	Path     string `json:"path" yaml:"path"`
+	Resource string `json:"resource,omitempty" yaml:"resource,omitempty"`
+	Action   string `json:"action,omitempty" yaml:"action,omitempty"`
+}
+
+// validate reports every invalid route
+func (c *ExtAuthzConfig) validate() []error {
+	var errs []error
+	for i, r := range c.Routes {
+		key := fmt.Sprintf("ext_authz.routes[%d]", i)
+		if !strings.HasPrefix(r.Path, "/") {
+			errs = append(errs, fmt.Errorf("%s.path: %q must start with /", key, r.Path))
+			continue
+		}
+		if param := r.param(); param != "" && !slices.Contains(segments(r.Path), ":"+param) {
+			errs = append(errs, fmt.Errorf("%s.resource: path %q has no :%s parameter", key, r.Path, param))
+		}
+	}
+	subject := c.Subject
+	if subject.JWT.set() && subject.Metadata.set() {
+		errs = append(errs, errors.New("ext_authz.subject: set one of jwt and metadata"))
+	}
+	if subject.JWT.set() {
+		errs = append(errs, subject.JWT.validate("ext_authz.subject.jwt")...)
+	}
+	if m := subject.Metadata; m.set() && (m.Filter == "" || m.Key == "") {
+		errs = append(errs, errors.New("ext_authz.subject.metadata: filter and key are required"))
+	}
+	return errs
+}
+
+// param names the path parameter holding the resource, empty for a fixed one
+func (r ExtAuthzRoute) param() string {
+	switch {
+	case r.Resource == "":
+		return "resource_id"
+	case strings.HasPrefix(r.Resource, ":"):
+		return r.Resource[1:]
+	}
+	return ""
+}
+
+// match returns the resource of path when it matches the route
+func (r ExtAuthzRoute) match(path string) (string, bool) {
+	pattern, segs := segments(r.Path), segments(path)
+	if n := len(pattern); n > 0 && pattern[n-1] == "*" {
+		pattern = pattern[:n-1]
+		if len(segs) < len(pattern) {
+			return "", false
+		}
+	} else if len(segs) != len(pattern) {
+		return "", false
+	}
+	params := map[string]string{}
+	for i, p := range pattern {
+		switch {
+		case strings.HasPrefix(p, ":"):
+			params[p[1:]] = segs[i]
+		case p != segs[i]:
+			return "", false
+		}
+	}
+	if param := r.param(); param != "" {
+		return params[param], params[param] != ""
+	}
+	return r.Resource, true
+}
+
+// resolve maps a proxied request onto its resource and action from the path
+// alone
+func (c *ExtAuthzConfig) resolve(method, path string) (string, string) {
+	path, _, _ = strings.Cut(path, "?")
+	action := pep.ActionFromMethod(method)
+	for _, r := range c.Routes {
+		if resource, ok := r.match(path); ok {
+			if r.Action != "" {
+				action = r.Action
+			}
+			return resource, action
+		}
+	}
+	segs := segments(path)
+	if len(segs) == 0 {
+		return "", action
+	}
+	return segs[len(segs)-1], action
+}
+
+func segments(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// errNoSubject is returned when the subject cannot be taken from its
+// trusted source
+var errNoSubject = errors.New("unauthenticated")
+
+// extAuthz decides proxied requests with the configured OPA evaluator
+type extAuthz struct {
+	config   *ExtAuthzConfig
+	jwt      *jwtVerifier
+	pdp      pep.PDP
+	recorder pep.Recorder
+}
+
+func newExtAuthz(config *ExtAuthzConfig, pdp pep.PDP, recorder pep.Recorder) (*extAuthz, error) {
+	e := &extAuthz{config: config, pdp: pdp, recorder: recorder}
+	if config.Subject.JWT.set() {
+		v, err := newJWTVerifier(&config.Subject.JWT)
+		if err != nil {
+			return nil, fmt.Errorf("ext_authz: %w", err)
+		}
+		e.jwt = v
+	}
+	return e, nil
+}
+
+// claims returns the claims of the configured subject source
+func (e *extAuthz) claims(attrs pep.Attributes, metadata *corev3.Metadata) (map[string]interface{}, error) {
+	switch source := e.config.Subject.Metadata; {
+	case e.jwt != nil:
+		token, err := e.jwt.token(attrs)
+		if err != nil {
+			return nil, err
+		}
+		return e.jwt.verify(token)
+	case source.set():
+		claims := metadata.GetFilterMetadata()[source.Filter].GetFields()[source.Key].GetStructValue()
+		if claims == nil {
+			return nil, fmt.Errorf("no %s metadata in %s", source.Key, source.Filter)
+		}
+		return claims.AsMap(), nil
+	}
+	return nil, errors.New("no subject source configured")
+}
+
+// check decides a proxied request. The subject and role come from the
+// trusted source, the resource and action from the path and method, and the
+// request is evaluated as the DecisionInput of the authz middleware. The
+// proxy enforces the decision, so it is recorded like a pep decision.
+func (e *extAuthz) check(ctx context.Context, method, path string, attrs pep.Attributes, metadata *corev3.Metadata) (*pep.Decision, error) {
+	claims, err := e.claims(attrs, metadata)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNoSubject, err)
+	}
+	claim, roleClaim := e.config.Subject.Claim, e.config.Subject.RoleClaim
+	if claim == "" {
+		claim = "sub"
+	}
+	if roleClaim == "" {
+		roleClaim = "role"
+	}
+	subject, _ := claims[claim].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: no %s claim", errNoSubject, claim)
+	}
+	// the identity headers of the client are replaced by the claims
+	delete(attrs, "x-user-id")
+	delete(attrs, "x-user-role")
+	if role, ok := claims[roleClaim].(string); ok {
+		attrs["x-user-role"] = role
+	}
+
+	resource, action := e.config.resolve(method, path)
+	req := pep.Request{Subject: subject, Resource: resource, Action: action, Attributes: attrs}
+	d, err := e.pdp.Decide(ctx, req)
+	if err != nil || e.recorder == nil {
+		return d, err
+	}
+	if err := e.recorder.Record(ctx, req, d); err != nil {
+		return nil, fmt.Errorf("failed to record decision: %w", err)
+	}
+	return d, nil
+}
+
+// extAuthzServer implements Envoy's ext_authz Authorization service
+type extAuthzServer struct {
+	authv3.UnimplementedAuthorizationServer
+
+	*extAuthz
+}
+
+// DeniedResponse is the body returned to the client when ext_authz denies a request
+type DeniedResponse struct {
+	Error    string `json:"error"`
+	Decision string `json:"decision"`
+	Reason   string `json:"reason"`
+}
+
+// obligationHeaders renders the obligations of an allowed decision as headers
+func obligationHeaders(d *pep.Decision) (map[string]string, error) {
+	headers := map[string]string{}
+	if len(d.Obligations) == 0 {
+		return headers, nil
+	}
+	b, err := json.Marshal(d.Obligations)
+	if err != nil {
+		return nil, err
+	}
+	headers[headerObligations] = string(b)
+	if mask := pep.MaskFields(d.Obligations); len(mask) > 0 {
+		headers[headerMaskFields] = strings.Join(mask, ",")
+	}
+	return headers, nil
+}
+
+// deniedResponse returns the status and body of a denial or of a request
+// without a trusted subject
+func deniedResponse(d *pep.Decision, err error) (int, DeniedResponse) {
+	if err != nil {
+		return http.StatusUnauthorized, DeniedResponse{Error: errNoSubject.Error(), Decision: "deny", Reason: err.Error()}
+	}
+	return http.StatusForbidden, DeniedResponse{Error: "access denied", Decision: "deny", Reason: d.Reason}
+}
+
+// Check implements the ext_authz gRPC service
+func (s *extAuthzServer) Check(ctx context.Context, req *authv3.CheckRequest) (*authv3.CheckResponse, error) {
+	httpReq := req.GetAttributes().GetRequest().GetHttp()
+	attrs := pep.Attributes{}
+	for k, v := range httpReq.GetHeaders() {
+		attrs[strings.ToLower(k)] = v
+	}
+
+	d, err := s.check(ctx, httpReq.GetMethod(), httpReq.GetPath(), attrs, req.GetAttributes().GetMetadataContext())
+	if err != nil && !errors.Is(err, errNoSubject) {
+		return nil, grpcError(err)
+	}
+
+	if err != nil || !d.Allowed {
+		status, denied := deniedResponse(d, err)
+		body, err := json.Marshal(denied)
+		if err != nil {
+			return nil, err
+		}
+		code, httpCode := codes.PermissionDenied, typev3.StatusCode_Forbidden
+		if status == http.StatusUnauthorized {
+			code, httpCode = codes.Unauthenticated, typev3.StatusCode_Unauthorized
+		}
+		return &authv3.CheckResponse{
+			Status: &rpcstatus.Status{Code: int32(code), Message: denied.Reason},
+			HttpResponse: &authv3.CheckResponse_DeniedResponse{DeniedResponse: &authv3.DeniedHttpResponse{
+				Status:  &typev3.HttpStatus{Code: httpCode},
+				Headers: headerOptions(map[string]string{"content-type": "application/json"}),
+				Body:    string(body),
+			}},
+		}, nil
+	}
+
+	out, err := obligationHeaders(d)
+	if err != nil {
+		return nil, err
+	}
+	return &authv3.CheckResponse{
+		Status: &rpcstatus.Status{Code: int32(codes.OK)},
+		HttpResponse: &authv3.CheckResponse_OkResponse{OkResponse: &authv3.OkHttpResponse{
+			Headers: headerOptions(out),
+		}},
+	}, nil
+}
+
+func headerOptions(headers map[string]string) []*corev3.HeaderValueOption {
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]*corev3.HeaderValueOption, 0, len(headers))
+	for _, k := range keys {
+		out = append(out, &corev3.HeaderValueOption{
+			Header:       &corev3.HeaderValue{Key: k, Value: headers[k]},
+			AppendAction: corev3.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD,
+		})
+	}
+	return out
+}
+
+// ExtAuthzHandler implements the ext_authz HTTP service. Envoy forwards the
+// original method, path and headers under the configured path prefix; a 200
+// allows the request and the returned headers carry the obligations upstream.
+// Envoy sends no dynamic metadata here, so only a JWT subject source works.
+func (h *HTTPServer) ExtAuthzHandler(c *gin.Context) {
+	d, err := h.extAuthz.check(c.Request.Context(), c.Request.Method, c.Param("path"), pep.HTTPAttributes(c.Request.Header), nil)
+	if err != nil && !errors.Is(err, errNoSubject) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil || !d.Allowed {
+		c.JSON(deniedResponse(d, err))
+		return
+	}
+
+	headers, err := obligationHeaders(d)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for k, v := range headers {
+		c.Header(k, v)
+	}
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,251 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"github.com/gin-gonic/gin"
+	"github.com/kumarabd/policy-machine/internal/authz"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// fakeEvaluator allows alice and keeps the last input it evaluated
+type fakeEvaluator struct {
+	input authz.DecisionInput
+}
+
+func (f *fakeEvaluator) Evaluate(_ context.Context, req authz.DecisionRequest) (*authz.DecisionResult, error) {
+	f.input = req.Input
+	if req.Input.Subject.ID != "alice" {
+		return &authz.DecisionResult{Decision: "deny", Reason: "not alice"}, nil
+	}
+	return &authz.DecisionResult{Decision: "allow", Obligations: []map[string]interface{}{
+		{"type": "mask", "fields": []interface{}{"ssn"}},
+	}}, nil
+}
+
+func signHS256(t *testing.T, secret string, header, claims map[string]interface{}) string {
+	t.Helper()
+	input := encodeSegment(t, header) + "." + encodeSegment(t, claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestExtAuthzJWT(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "secret")
+	if err := os.WriteFile(keyFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	hs256 := map[string]interface{}{"alg": "HS256", "typ": "JWT"}
+	exp := float64(time.Now().Add(time.Hour).Unix())
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+		// role is the x-user-role attribute OPA should see
+		role string
+	}{
+		{
+			name:    "allowed",
+			headers: map[string]string{"Authorization": "Bearer " + signHS256(t, "s3cret", hs256, map[string]interface{}{"sub": "alice", "role": "admin", "iss": "idp", "exp": exp})},
+			status:  http.StatusOK,
+			role:    "admin",
+		},
+		{
+			name: "client identity headers ignored",
+			headers: map[string]string{
+				"Authorization": "Bearer " + signHS256(t, "s3cret", hs256, map[string]interface{}{"sub": "bob", "iss": "idp"}),
+				"X-User-ID":     "alice",
+				"X-User-Role":   "admin",
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name:    "no token",
+			headers: map[string]string{"X-User-ID": "alice"},
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "wrong secret",
+			headers: map[string]string{"Authorization": "Bearer " + signHS256(t, "other", hs256, map[string]interface{}{"sub": "alice", "iss": "idp"})},
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "unsigned token",
+			headers: map[string]string{"Authorization": "Bearer " + encodeSegment(t, map[string]interface{}{"alg": "none"}) + "." + encodeSegment(t, map[string]interface{}{"sub": "alice", "iss": "idp"}) + "."},
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "expired",
+			headers: map[string]string{"Authorization": "Bearer " + signHS256(t, "s3cret", hs256, map[string]interface{}{"sub": "alice", "iss": "idp", "exp": float64(time.Now().Add(-time.Minute).Unix())})},
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "other issuer",
+			headers: map[string]string{"Authorization": "Bearer " + signHS256(t, "s3cret", hs256, map[string]interface{}{"sub": "alice", "iss": "evil"})},
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "no subject claim",
+			headers: map[string]string{"Authorization": "Bearer " + signHS256(t, "s3cret", hs256, map[string]interface{}{"iss": "idp"})},
+			status:  http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluator := &fakeEvaluator{}
+			config := &ExtAuthzConfig{Subject: ExtAuthzSubject{JWT: JWTConfig{Algorithm: "HS256", KeyFile: keyFile, Issuer: "idp"}}}
+			e, err := newExtAuthz(config, authz.NewPDP(evaluator, nil), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			engine := gin.New()
+			engine.Any("/ext_authz/*path", (&HTTPServer{extAuthz: e}).ExtAuthzHandler)
+
+			req := httptest.NewRequest(http.MethodGet, "/ext_authz/records/rec_1", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			engine.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if got := rec.Header().Get(headerMaskFields); got != "ssn" {
+				t.Errorf("%s = %q, want ssn", headerMaskFields, got)
+			}
+			in := evaluator.input
+			if in.Resource.ID != "rec_1" || in.Action != "read" || in.Subject.Attributes["role"] != tt.role {
+				t.Errorf("input %+v", in)
+			}
+		})
+	}
+}
+
+func TestExtAuthzES256(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	v, err := newJWTVerifier(&JWTConfig{Algorithm: "ES256", KeyFile: keyFile, Audience: "pm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(claims map[string]interface{}) string {
+		input := encodeSegment(t, map[string]interface{}{"alg": "ES256"}) + "." + encodeSegment(t, claims)
+		digest := sha256.Sum256([]byte(input))
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+	}
+	if claims, err := v.verify(sign(map[string]interface{}{"sub": "alice", "aud": []string{"other", "pm"}})); err != nil || claims["sub"] != "alice" {
+		t.Errorf("valid token: %v %v", claims, err)
+	}
+	if _, err := v.verify(sign(map[string]interface{}{"sub": "alice", "aud": "other"})); err == nil {
+		t.Error("token for another audience accepted")
+	}
+	token := sign(map[string]interface{}{"sub": "alice", "aud": "pm"})
+	if _, err := v.verify(token[:len(token)-4] + "AAAA"); err == nil {
+		t.Error("tampered signature accepted")
+	}
+}
+
+func TestExtAuthzMetadata(t *testing.T) {
+	payload, err := structpb.NewStruct(map[string]interface{}{"sub": "alice", "role": "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		metadata *corev3.Metadata
+		code     codes.Code
+	}{
+		{
+			name: "allowed",
+			metadata: &corev3.Metadata{FilterMetadata: map[string]*structpb.Struct{
+				"envoy.filters.http.jwt_authn": {Fields: map[string]*structpb.Value{"jwt_payload": structpb.NewStructValue(payload)}},
+			}},
+			code: codes.OK,
+		},
+		{
+			name: "other namespace",
+			metadata: &corev3.Metadata{FilterMetadata: map[string]*structpb.Struct{
+				"lua": {Fields: map[string]*structpb.Value{"jwt_payload": structpb.NewStructValue(payload)}},
+			}},
+			code: codes.Unauthenticated,
+		},
+		{
+			name: "no metadata",
+			code: codes.Unauthenticated,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &ExtAuthzConfig{Subject: ExtAuthzSubject{Metadata: MetadataSource{Filter: "envoy.filters.http.jwt_authn", Key: "jwt_payload"}}}
+			e, err := newExtAuthz(config, authz.NewPDP(&fakeEvaluator{}, nil), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := (&extAuthzServer{extAuthz: e}).Check(context.Background(), &authv3.CheckRequest{Attributes: &authv3.AttributeContext{
+				Request: &authv3.AttributeContext_Request{Http: &authv3.AttributeContext_HttpRequest{
+					Method:  "GET",
+					Path:    "/records/rec_1",
+					Headers: map[string]string{"x-user-id": "alice"},
+				}},
+				MetadataContext: tt.metadata,
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := codes.Code(resp.GetStatus().GetCode()); got != tt.code {
+				t.Fatalf("code %s, want %s", got, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	pb "github.com/kumarabd/policy-machine/api/policymachine/v1"
+	"github.com/kumarabd/policy-machine/pkg/model"
+	"github.com/kumarabd/policy-machine/pkg/pml"
+	"github.com/kumarabd/policy-machine/pkg/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+type GRPCServerConfig struct {
+	Port string `json:"port" yaml:"port"`
+}
+
+// GRPCServer serves the authorization and graph admin APIs over gRPC
+type GRPCServer struct {
+	pb.UnimplementedAuthorizationServiceServer
+	pb.UnimplementedGraphAdminServiceServer
+
+	handler  *grpc.Server
+	health   *health.Server
+	service  *service.Handler
+	stopping context.Context
+}
+
+func newGRPCServer(service *service.Handler, auth *authenticator, extAuthz *extAuthz, stopping context.Context, opts ...grpc.ServerOption) *GRPCServer {
+	opts = append(opts, grpc.ChainUnaryInterceptor(auth.unaryInterceptor), grpc.ChainStreamInterceptor(auth.streamInterceptor))
+	s := &GRPCServer{
+		handler:  grpc.NewServer(opts...),
+		health:   health.NewServer(),
+		service:  service,
+		stopping: stopping,
+	}
+	pb.RegisterAuthorizationServiceServer(s.handler, s)
+	pb.RegisterGraphAdminServiceServer(s.handler, s)
+	authv3.RegisterAuthorizationServer(s.handler, &extAuthzServer{extAuthz: extAuthz})
+	healthpb.RegisterHealthServer(s.handler, s.health)
+	reflection.Register(s.handler)
+	return s
+}
+
+func (s *GRPCServer) Check(ctx context.Context, req *pb.CheckRequest) (*pb.Decision, error) {
+	d, err := s.service.Check(ctx, checkRequestFromProto(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return decisionToProto(d)
+}
+
+func (s *GRPCServer) BatchCheck(ctx context.Context, req *pb.BatchCheckRequest) (*pb.BatchCheckResponse, error) {
+	reqs := make([]service.CheckRequest, 0, len(req.GetRequests()))
+	for _, r := range req.GetRequests() {
+		reqs = append(reqs, checkRequestFromProto(r))
+	}
+	decisions, err := s.service.BatchCheck(ctx, reqs)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &pb.BatchCheckResponse{}
+	for _, d := range decisions {
+		pd, err := decisionToProto(d)
+		if err != nil {
+			return nil, err
+		}
+		resp.Decisions = append(resp.Decisions, pd)
+	}
+	return resp, nil
+}
+
+func (s *GRPCServer) Explain(ctx context.Context, req *pb.CheckRequest) (*pb.Explanation, error) {
+	exp, err := s.service.Explain(ctx, checkRequestFromProto(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return explanationToProto(exp)
+}
+
+func (s *GRPCServer) ListAccessibleObjects(ctx context.Context, req *pb.ListAccessibleObjectsRequest) (*pb.ListAccessibleObjectsResponse, error) {
+	objects, rev, err := s.service.AccessibleObjects(ctx, req.GetUser(), req.GetAsOf())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &pb.ListAccessibleObjectsResponse{Revision: rev}
+	for _, o := range objects {
+		resp.Objects = append(resp.Objects, &pb.AccessibleObject{Object: o.Object, Operations: o.Operations})
+	}
+	return resp, nil
+}
+
+func (s *GRPCServer) GetGraph(ctx context.Context, req *pb.GetGraphRequest) (*pb.Graph, error) {
+	g, rev, err := s.service.Graph(ctx, req.GetRevision())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return graphToProto(rev, g.Document())
+}
+
+func (s *GRPCServer) Commit(ctx context.Context, req *pb.CommitRequest) (*pb.Revision, error) {
+	changes := make([]model.Change, 0, len(req.GetChanges()))
+	for _, c := range req.GetChanges() {
+		changes = append(changes, changeFromProto(c))
+	}
+	rev, err := s.service.Commit(ctx, caller(ctx), req.GetMessage(), changes)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return revisionToProto(rev)
+}
+
+func (s *GRPCServer) ApplyPolicy(ctx context.Context, req *pb.ApplyPolicyRequest) (*pb.Revision, error) {
+	rev, err := pml.Execute(ctx, s.service, caller(ctx), req.GetMessage(), req.GetPml())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return revisionToProto(rev)
+}
+
+func (s *GRPCServer) ListRevisions(ctx context.Context, req *pb.ListRevisionsRequest) (*pb.ListRevisionsResponse, error) {
+	revisions, err := s.service.History(ctx, req.GetFrom(), req.GetTo())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &pb.ListRevisionsResponse{}
+	for i := range revisions {
+		r, err := revisionToProto(&revisions[i])
+		if err != nil {
+			return nil, err
+		}
+		resp.Revisions = append(resp.Revisions, r)
+	}
+	return resp, nil
+}
+
+func (s *GRPCServer) Diff(ctx context.Context, req *pb.DiffRequest) (*pb.DiffResponse, error) {
+	to := req.GetTo()
+	if to == 0 {
+		_, to, _ = s.service.Graph(ctx, 0)
+	}
+	changes, err := s.service.Diff(ctx, req.GetFrom(), to)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	resp := &pb.DiffResponse{}
+	for _, c := range changes {
+		pc, err := changeToProto(c)
+		if err != nil {
+			return nil, err
+		}
+		resp.Changes = append(resp.Changes, pc)
+	}
+	return resp, nil
+}
+
+func (s *GRPCServer) Watch(req *pb.WatchRequest, stream pb.GraphAdminService_WatchServer) error {
+	ctx, cancel := streamContext(stream.Context(), s.stopping)
+	defer cancel()
+	events, err := s.service.Watch(ctx, req.GetFrom())
+	if err != nil {
+		return grpcError(err)
+	}
+	for ev := range events {
+		out := &pb.WatchEvent{Type: string(ev.Type), Compacted: ev.Compacted}
+		if ev.Revision != nil {
+			if out.Revision, err = revisionToProto(ev.Revision); err != nil {
+				return err
+			}
+		}
+		if err := stream.Send(out); err != nil {
+			return err
+		}
+	}
+	if s.stopping.Err() != nil {
+		// Clients resume from their last revision on another server
+		return status.Error(codes.Unavailable, "server is shutting down")
+	}
+	return stream.Context().Err()
+}
+
+// grpcError maps service errors onto gRPC status codes
+func grpcError(err error) error {
+	var (
+		compacted *model.CompactedError
+		conflict  *model.ConflictError
+		perr      *pml.Error
+		invalid   *model.InvalidChangeError
+		violation *model.ConstraintError
+	)
+	switch {
+	case errors.As(err, &compacted):
+		return status.Error(codes.OutOfRange, err.Error())
+	case errors.As(err, &conflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.As(err, &perr), errors.As(err, &invalid), errors.As(err, &violation), errors.Is(err, model.ErrNoChanges):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.FailedPrecondition, err.Error())
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kumarabd/gokit/logger"
+	pb "github.com/kumarabd/policy-machine/api/policymachine/v1"
+	"github.com/kumarabd/policy-machine/internal/metrics"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestGRPC serves the gRPC services of run over the hospital policy and
+// returns a client connection to them
+func newTestGRPC(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	l := &logger.Handler{Logger: zerolog.Nop()}
+	m, err := metrics.New("test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{Auth: AuthConfig{Tokens: []Token{
+		{Name: "viewer", Token: "read-token", Scopes: []string{ScopeAdminRead}},
+		{Name: "ci", Token: "write-token", Scopes: []string{ScopeAdminRead, ScopeAdminWrite}},
+	}}}
+	h, err := New(l, m, config, newTestService(t, l, m, "../../policies/hospital.pml"), nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	go h.GRPCServer.handler.Serve(lis)
+	t.Cleanup(h.GRPCServer.handler.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestGRPCAuthorization(t *testing.T) {
+	client := pb.NewAuthorizationServiceClient(newTestGRPC(t))
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		req     *pb.CheckRequest
+		allowed bool
+		code    codes.Code
+	}{
+		{name: "allowed", req: &pb.CheckRequest{User: "alice", Object: "rec_1", Operation: "read"}, allowed: true},
+		{name: "prohibited", req: &pb.CheckRequest{User: "bob", Object: "rec_2", Operation: "read"}},
+		{name: "unknown revision", req: &pb.CheckRequest{User: "alice", Object: "rec_1", Operation: "read", AsOf: 999}, code: codes.FailedPrecondition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := client.Check(ctx, tt.req)
+			if status.Code(err) != tt.code {
+				t.Fatalf("Check() error = %v, want %s", err, tt.code)
+			}
+			if err == nil && d.GetAllowed() != tt.allowed {
+				t.Fatalf("Check() = %v, want allowed %v", d, tt.allowed)
+			}
+		})
+	}
+
+	batch, err := client.BatchCheck(ctx, &pb.BatchCheckRequest{Requests: []*pb.CheckRequest{tests[0].req, tests[1].req}})
+	if err != nil || len(batch.GetDecisions()) != 2 || !batch.Decisions[0].GetAllowed() || batch.Decisions[1].GetAllowed() {
+		t.Errorf("BatchCheck() = %v, %v", batch, err)
+	}
+	exp, err := client.Explain(ctx, tests[1].req)
+	if err != nil || len(exp.GetProhibitions()) != 1 || exp.Prohibitions[0] != "intern_no_sensitive" {
+		t.Errorf("Explain() = %v, %v", exp, err)
+	}
+	objects, err := client.ListAccessibleObjects(ctx, &pb.ListAccessibleObjectsRequest{User: "bob"})
+	if err != nil || len(objects.GetObjects()) != 1 || objects.Objects[0].GetObject() != "rec_1" {
+		t.Errorf("ListAccessibleObjects() = %v, %v", objects, err)
+	}
+}
+
+func TestGRPCGraphAdmin(t *testing.T) {
+	conn := newTestGRPC(t)
+	client := pb.NewGraphAdminServiceClient(conn)
+	apply := &pb.ApplyPolicyRequest{Author: "mallory", Message: "add carol", Pml: `create user "carol" assign to ["doctor"]`}
+
+	tests := []struct {
+		name string
+		call func() error
+		code codes.Code
+	}{
+		{name: "no token", call: func() error { _, err := client.GetGraph(context.Background(), &pb.GetGraphRequest{}); return err }, code: codes.Unauthenticated},
+		{name: "unknown token", call: func() error { _, err := client.GetGraph(withToken("guess"), &pb.GetGraphRequest{}); return err }, code: codes.Unauthenticated},
+		{name: "read", call: func() error { _, err := client.GetGraph(withToken("read-token"), &pb.GetGraphRequest{}); return err }},
+		{name: "write without scope", call: func() error { _, err := client.ApplyPolicy(withToken("read-token"), apply); return err }, code: codes.PermissionDenied},
+		{
+			name: "invalid policy",
+			call: func() error {
+				_, err := client.ApplyPolicy(withToken("write-token"), &pb.ApplyPolicyRequest{Pml: `create user "carol" assign to ["nobody"]`})
+				return err
+			},
+			code: codes.InvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); status.Code(err) != tt.code {
+				t.Fatalf("error = %v, want %s", err, tt.code)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(withToken("write-token"), 5*time.Second)
+	defer cancel()
+	g, err := client.GetGraph(ctx, &pb.GetGraphRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	watch, err := client.Watch(ctx, &pb.WatchRequest{From: g.GetRevision()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rev, err := client.ApplyPolicy(ctx, apply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The author is the token's name, not the one in the request
+	if rev.GetAuthor() != "ci" || rev.GetRevision() != g.GetRevision()+1 {
+		t.Errorf("ApplyPolicy() = revision %d by %q, want %d by ci", rev.GetRevision(), rev.GetAuthor(), g.GetRevision()+1)
+	}
+	for {
+		ev, err := watch.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.GetRevision().GetRevision() == rev.GetRevision() {
+			break
+		}
+	}
+
+	diff, err := client.Diff(ctx, &pb.DiffRequest{From: g.GetRevision()})
+	if err != nil || len(diff.GetChanges()) != 2 {
+		t.Errorf("Diff() = %v, %v, want the node and its assignment", diff, err)
+	}
+	revs, err := client.ListRevisions(ctx, &pb.ListRevisionsRequest{From: g.GetRevision()})
+	if err != nil || len(revs.GetRevisions()) != 1 || revs.Revisions[0].GetMessage() != "add carol" {
+		t.Errorf("ListRevisions() = %v, %v", revs, err)
+	}
+
+	health, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil || health.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("health = %v, %v", health, err)
+	}
+}
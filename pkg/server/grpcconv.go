@@ -0,0 +1,206 @@
+package server
+
+import (
+	"encoding/json"
+
+	pb "github.com/kumarabd/policy-machine/api/policymachine/v1"
+	"github.com/kumarabd/policy-machine/pkg/model"
+	"github.com/kumarabd/policy-machine/pkg/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func checkRequestFromProto(r *pb.CheckRequest) service.CheckRequest {
+	return service.CheckRequest{User: r.GetUser(), Object: r.GetObject(), Operation: r.GetOperation(), AsOf: r.GetAsOf()}
+}
+
+func decisionToProto(d *service.Decision) (*pb.Decision, error) {
+	out := &pb.Decision{Allowed: d.Allowed, Decision: d.Decision, Reason: d.Reason, Revision: d.Revision}
+	for _, o := range d.Obligations {
+		s, err := toStruct(o)
+		if err != nil {
+			return nil, err
+		}
+		out.Obligations = append(out.Obligations, s)
+	}
+	return out, nil
+}
+
+func explanationToProto(e *service.Explanation) (*pb.Explanation, error) {
+	d, err := decisionToProto(&e.Decision)
+	if err != nil {
+		return nil, err
+	}
+	out := &pb.Explanation{Decision: d, Prohibitions: e.Prohibitions}
+	for _, pc := range e.PolicyClasses {
+		p := &pb.PolicyClassExplanation{PolicyClass: pc.PolicyClass, Satisfied: pc.Satisfied}
+		for _, g := range pc.Grants {
+			p.Grants = append(p.Grants, &pb.Grant{Ua: g.UA, Target: g.Target, Operations: g.Operations, UserPath: g.UserPath, ObjectPath: g.ObjectPath})
+		}
+		out.PolicyClasses = append(out.PolicyClasses, p)
+	}
+	return out, nil
+}
+
+func graphToProto(rev uint64, doc model.Document) (*pb.Graph, error) {
+	out := &pb.Graph{Revision: rev}
+	for i := range doc.Nodes {
+		out.Nodes = append(out.Nodes, nodeToProto(&doc.Nodes[i]))
+	}
+	for i := range doc.Assignments {
+		out.Assignments = append(out.Assignments, assignmentToProto(&doc.Assignments[i]))
+	}
+	for i := range doc.Associations {
+		out.Associations = append(out.Associations, associationToProto(&doc.Associations[i]))
+	}
+	for i := range doc.Prohibitions {
+		out.Prohibitions = append(out.Prohibitions, prohibitionToProto(&doc.Prohibitions[i]))
+	}
+	for i := range doc.Obligations {
+		o, err := obligationToProto(&doc.Obligations[i])
+		if err != nil {
+			return nil, err
+		}
+		out.Obligations = append(out.Obligations, o)
+	}
+	for i := range doc.Constraints {
+		out.Constraints = append(out.Constraints, constraintToProto(&doc.Constraints[i]))
+	}
+	return out, nil
+}
+
+func revisionToProto(r *model.Revision) (*pb.Revision, error) {
+	out := &pb.Revision{
+		Revision:  r.Number,
+		Author:    r.Author,
+		Timestamp: timestamppb.New(r.Timestamp),
+		Message:   r.Message,
+		Tags:      r.Tags,
+	}
+	for _, c := range r.Changes {
+		pc, err := changeToProto(c)
+		if err != nil {
+			return nil, err
+		}
+		out.Changes = append(out.Changes, pc)
+	}
+	return out, nil
+}
+
+func changeToProto(c model.Change) (*pb.Change, error) {
+	out := &pb.Change{
+		Op:          string(c.Op),
+		Node:        nodeToProto(c.Node),
+		Assignment:  assignmentToProto(c.Assignment),
+		Association: associationToProto(c.Association),
+		Prohibition: prohibitionToProto(c.Prohibition),
+		Constraint:  constraintToProto(c.Constraint),
+	}
+	var err error
+	out.Obligation, err = obligationToProto(c.Obligation)
+	return out, err
+}
+
+func changeFromProto(c *pb.Change) model.Change {
+	out := model.Change{Op: model.ChangeOp(c.GetOp())}
+	if n := c.GetNode(); n != nil {
+		out.Node = &model.Node{Name: n.GetName(), Type: model.NodeType(n.GetType()), Properties: n.GetProperties()}
+	}
+	if a := c.GetAssignment(); a != nil {
+		out.Assignment = &model.Assignment{Child: a.GetChild(), Parent: a.GetParent()}
+	}
+	if a := c.GetAssociation(); a != nil {
+		out.Association = &model.Association{UA: a.GetUa(), Target: a.GetTarget(), Operations: a.GetOperations()}
+	}
+	if p := c.GetProhibition(); p != nil {
+		out.Prohibition = &model.Prohibition{
+			Name:         p.GetName(),
+			Subject:      p.GetSubject(),
+			Operations:   p.GetOperations(),
+			Containers:   p.GetContainers(),
+			Intersection: p.GetIntersection(),
+		}
+	}
+	if o := c.GetObligation(); o != nil {
+		out.Obligation = &model.Obligation{
+			Name:       o.GetName(),
+			Subject:    o.GetSubject(),
+			Operations: o.GetOperations(),
+			Target:     o.GetTarget(),
+			Response:   o.GetResponse().AsMap(),
+		}
+	}
+	if k := c.GetConstraint(); k != nil {
+		out.Constraint = &model.Constraint{
+			Name:       k.GetName(),
+			Type:       model.ConstraintType(k.GetType()),
+			Attributes: k.GetAttributes(),
+			Operations: k.GetOperations(),
+			Containers: k.GetContainers(),
+		}
+	}
+	return out
+}
+
+func nodeToProto(n *model.Node) *pb.Node {
+	if n == nil {
+		return nil
+	}
+	return &pb.Node{Name: n.Name, Type: string(n.Type), Properties: n.Properties}
+}
+
+func assignmentToProto(a *model.Assignment) *pb.Assignment {
+	if a == nil {
+		return nil
+	}
+	return &pb.Assignment{Child: a.Child, Parent: a.Parent}
+}
+
+func associationToProto(a *model.Association) *pb.Association {
+	if a == nil {
+		return nil
+	}
+	return &pb.Association{Ua: a.UA, Target: a.Target, Operations: a.Operations}
+}
+
+func prohibitionToProto(p *model.Prohibition) *pb.Prohibition {
+	if p == nil {
+		return nil
+	}
+	return &pb.Prohibition{Name: p.Name, Subject: p.Subject, Operations: p.Operations, Containers: p.Containers, Intersection: p.Intersection}
+}
+
+func obligationToProto(o *model.Obligation) (*pb.Obligation, error) {
+	if o == nil {
+		return nil, nil
+	}
+	response, err := toStruct(o.Response)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Obligation{Name: o.Name, Subject: o.Subject, Operations: o.Operations, Target: o.Target, Response: response}, nil
+}
+
+func constraintToProto(c *model.Constraint) *pb.Constraint {
+	if c == nil {
+		return nil
+	}
+	return &pb.Constraint{Name: c.Name, Type: string(c.Type), Attributes: c.Attributes, Operations: c.Operations, Containers: c.Containers}
+}
+
+// toStruct converts a free-form JSON object through its JSON encoding, since
+// responses may hold typed values such as []string that structpb rejects
+func toStruct(m map[string]interface{}) (*structpb.Struct, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s := &structpb.Struct{}
+	if err := protojson.Unmarshal(b, s); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return s, nil
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+)
+
+func TestChangeProtoRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		change model.Change
+	}{
+		{name: "node", change: model.Change{Op: model.OpCreateNode, Node: &model.Node{Name: "rec", Type: model.Object, Properties: map[string]string{"type": "record"}}}},
+		{name: "prohibition", change: model.Change{Op: model.OpCreateProhibition, Prohibition: &model.Prohibition{Name: "p", Subject: "intern", Operations: []string{"write"}, Containers: []string{"a", "b"}, Intersection: true}}},
+		{name: "static constraint", change: model.Change{Op: model.OpCreateConstraint, Constraint: &model.Constraint{Name: "sod", Type: model.StaticSoD, Attributes: []string{"doctor", "intern"}}}},
+		{name: "dynamic constraint", change: model.Change{Op: model.OpCreateConstraint, Constraint: &model.Constraint{Name: "claims_sod", Type: model.DynamicSoD, Operations: []string{"submit", "approve"}, Containers: []string{"claims"}}}},
+		{name: "constraint deleted", change: model.Change{Op: model.OpDeleteConstraint, Constraint: &model.Constraint{Name: "sod"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc, err := changeToProto(tt.change)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := changeFromProto(pc); !reflect.DeepEqual(got, tt.change) {
+				t.Fatalf("changeFromProto(changeToProto()) = %+v, want %+v", got, tt.change)
+			}
+		})
+	}
+}
+
+func TestGraphToProtoConstraints(t *testing.T) {
+	doc := model.Document{Constraints: []model.Constraint{
+		{Name: "claims_sod", Type: model.DynamicSoD, Operations: []string{"submit", "approve"}},
+		{Name: "sod", Type: model.StaticSoD, Attributes: []string{"doctor", "intern"}},
+	}}
+	g, err := graphToProto(3, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Constraints) != 2 || g.Constraints[0].GetType() != "dynamic" || g.Constraints[1].GetAttributes()[1] != "intern" {
+		t.Fatalf("graphToProto() constraints = %v", g.Constraints)
+	}
+}
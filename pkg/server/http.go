@@ -1,31 +1,68 @@
 package server
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/kumarabd/policy-machine/internal/authz"
+	"github.com/kumarabd/policy-machine/internal/health"
+	"github.com/kumarabd/policy-machine/internal/metrics"
+	"github.com/kumarabd/policy-machine/pkg/audit"
+	"github.com/kumarabd/policy-machine/pkg/gitops"
+	"github.com/kumarabd/policy-machine/pkg/pep"
 	"github.com/kumarabd/policy-machine/pkg/service"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type HTTPServerConfig struct {
 	Port string `json:"port" yaml:"port"`
+	// ReadTimeout is the number of seconds allowed to read a request, 30 by default
+	ReadTimeout int `json:"read_timeout,omitempty" yaml:"read_timeout,omitempty"`
+	// WriteTimeout is the number of seconds allowed to write a response.
+	// Zero, the default, leaves watch streams and long polls open.
+	WriteTimeout int `json:"write_timeout,omitempty" yaml:"write_timeout,omitempty"`
+	// IdleTimeout is the number of seconds a keep-alive connection is kept
+	// idle, 120 by default
+	IdleTimeout int `json:"idle_timeout,omitempty" yaml:"idle_timeout,omitempty"`
 }
 type HTTPServer struct {
-	handler     *gin.Engine
-	service     *service.Handler
-	authzClient *authz.Client
+	handler   *gin.Engine
+	service   *service.Handler
+	evaluator authz.Evaluator
+	bundler   *authz.Bundler
+	syncer    *gitops.Syncer
+	audit     *audit.Log
+	metrics   *metrics.Handler
+	health    *health.Registry
+	auth      *authenticator
+	extAuthz  *extAuthz
+	// stopping is cancelled on shutdown to end watch streams and long polls
+	stopping context.Context
 }
 
 func (h *HTTPServer) MetricsHandler(c *gin.Context) {
-	promhttp.Handler().ServeHTTP(c.Writer, c.Request)
+	h.metrics.HTTPHandler().ServeHTTP(c.Writer, c.Request)
 }
 
 func (h *HTTPServer) HealthHandler(c *gin.Context) {
 	c.JSON(200, http.StatusText(http.StatusOK))
 }
 
+// ReadyHandler reports the readiness of every component, with 503 until
+// all of them pass
+func (h *HTTPServer) ReadyHandler(c *gin.Context) {
+	if h.health == nil {
+		c.JSON(http.StatusOK, health.Report{Status: health.StatusOK, Components: map[string]health.Component{}})
+		return
+	}
+	report := h.health.Check(c.Request.Context())
+	status := http.StatusOK
+	if report.Status != health.StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
+
 // UserDataHandler demonstrates masking obligation
 func (h *HTTPServer) UserDataHandler(c *gin.Context) {
 	// Sample user data
@@ -39,45 +76,15 @@ func (h *HTTPServer) UserDataHandler(c *gin.Context) {
 		"department":  "Engineering",
 	}
 
-	// Get obligations from authorization middleware
-	obligations, exists := c.Get("obligations")
-	if !exists {
+	// Apply the masking obligations of the authorization decision
+	obligations := pep.ObligationsFromContext(c.Request.Context())
+	if len(obligations) == 0 {
 		c.JSON(http.StatusOK, userData)
 		return
 	}
 
-	// Apply masking obligations
-	maskedData := applyMaskingObligations(userData, obligations.([]map[string]interface{}))
-
 	c.JSON(http.StatusOK, gin.H{
-		"data":        maskedData,
+		"data":        pep.Mask(userData, obligations),
 		"obligations": obligations,
 	})
 }
-
-// applyMaskingObligations applies masking based on obligations
-func applyMaskingObligations(data map[string]interface{}, obligations []map[string]interface{}) map[string]interface{} {
-	maskedData := make(map[string]interface{})
-
-	// Copy original data
-	for k, v := range data {
-		maskedData[k] = v
-	}
-
-	// Apply masking obligations
-	for _, obligation := range obligations {
-		if obligation["type"] == "mask" {
-			if fields, ok := obligation["fields"].([]interface{}); ok {
-				for _, field := range fields {
-					if fieldStr, ok := field.(string); ok {
-						if _, exists := maskedData[fieldStr]; exists {
-							maskedData[fieldStr] = "***MASKED***"
-						}
-					}
-				}
-			}
-		}
-	}
-
-	return maskedData
-}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// jwtAlgorithms are the signing algorithms accepted for ext_authz tokens
+var jwtAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "HS256", "HS384", "HS512"}
+
+// JWTConfig verifies the bearer tokens the subject of ext_authz requests is
+// taken from. Tokens must be signed with Algorithm; the header algorithm is
+// not trusted.
+type JWTConfig struct {
+	Algorithm string `json:"algorithm" yaml:"algorithm"`
+	// KeyFile holds the PEM public key or certificate, or the shared secret
+	// for the HS algorithms
+	KeyFile string `json:"key_file" yaml:"key_file"`
+	// Issuer and Audience must match the iss and aud claims when set
+	Issuer   string `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	Audience string `json:"audience,omitempty" yaml:"audience,omitempty"`
+	// Header carries the token, authorization by default
+	Header string `json:"header,omitempty" yaml:"header,omitempty"`
+}
+
+func (c *JWTConfig) set() bool {
+	return c.Algorithm != "" || c.KeyFile != ""
+}
+
+func (c *JWTConfig) validate(key string) []error {
+	var errs []error
+	if !slices.Contains(jwtAlgorithms, c.Algorithm) {
+		errs = append(errs, fmt.Errorf("%s.algorithm: unsupported algorithm %q", key, c.Algorithm))
+	}
+	if c.KeyFile == "" {
+		errs = append(errs, fmt.Errorf("%s.key_file: required to verify tokens", key))
+	}
+	return errs
+}
+
+// jwtVerifier checks the signature and time claims of tokens signed with one key
+type jwtVerifier struct {
+	config *JWTConfig
+	hash   crypto.Hash
+	key    interface{}
+	now    func() time.Time
+}
+
+func newJWTVerifier(c *JWTConfig) (*jwtVerifier, error) {
+	if !slices.Contains(jwtAlgorithms, c.Algorithm) {
+		return nil, fmt.Errorf("unsupported jwt algorithm %q", c.Algorithm)
+	}
+	data, err := os.ReadFile(c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwt key: %w", err)
+	}
+	v := &jwtVerifier{config: c, now: time.Now}
+	switch c.Algorithm[2:] {
+	case "256":
+		v.hash = crypto.SHA256
+	case "384":
+		v.hash = crypto.SHA384
+	default:
+		v.hash = crypto.SHA512
+	}
+	if strings.HasPrefix(c.Algorithm, "HS") {
+		v.key = bytes.TrimSpace(data)
+		return v, nil
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("jwt key %s is not PEM encoded", c.KeyFile)
+	}
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse jwt certificate: %w", err)
+		}
+		v.key = cert.PublicKey
+	} else if v.key, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+		return nil, fmt.Errorf("failed to parse jwt key: %w", err)
+	}
+	switch v.key.(type) {
+	case *rsa.PublicKey:
+		if c.Algorithm[0] == 'E' {
+			return nil, fmt.Errorf("jwt key is an RSA key, %s needs an ECDSA key", c.Algorithm)
+		}
+	case *ecdsa.PublicKey:
+		if c.Algorithm[0] != 'E' {
+			return nil, fmt.Errorf("jwt key is an ECDSA key, %s needs an RSA key", c.Algorithm)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported jwt key type %T", v.key)
+	}
+	return v, nil
+}
+
+// token returns the bearer token of the configured header
+func (v *jwtVerifier) token(attrs map[string]string) (string, error) {
+	header := v.config.Header
+	if header == "" {
+		header = "authorization"
+	}
+	value := attrs[strings.ToLower(header)]
+	if value == "" {
+		return "", fmt.Errorf("no token in %s", header)
+	}
+	if scheme, token, ok := strings.Cut(value, " "); ok && strings.EqualFold(scheme, "bearer") {
+		return strings.TrimSpace(token), nil
+	}
+	return value, nil
+}
+
+// verify returns the claims of a valid token
+func (v *jwtVerifier) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token is not a JWS")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+	if header.Alg != v.config.Algorithm {
+		return nil, fmt.Errorf("token is signed with %q, expected %s", header.Alg, v.config.Algorithm)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature: %w", err)
+	}
+	if err := v.verifySignature(parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+	now := float64(v.now().Unix())
+	if exp, ok := claims["exp"].(float64); ok && now >= exp {
+		return nil, errors.New("token has expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now < nbf {
+		return nil, errors.New("token is not valid yet")
+	}
+	if v.config.Issuer != "" && claims["iss"] != v.config.Issuer {
+		return nil, fmt.Errorf("token issuer %v is not %q", claims["iss"], v.config.Issuer)
+	}
+	if v.config.Audience != "" && !hasAudience(claims["aud"], v.config.Audience) {
+		return nil, fmt.Errorf("token is not issued for %q", v.config.Audience)
+	}
+	return claims, nil
+}
+
+func (v *jwtVerifier) verifySignature(input string, sig []byte) error {
+	if key, ok := v.key.([]byte); ok {
+		mac := hmac.New(v.hash.New, key)
+		mac.Write([]byte(input))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return errors.New("invalid token signature")
+		}
+		return nil
+	}
+
+	h := v.hash.New()
+	h.Write([]byte(input))
+	digest := h.Sum(nil)
+	var err error
+	switch key := v.key.(type) {
+	case *rsa.PublicKey:
+		if v.config.Algorithm[0] == 'P' {
+			err = rsa.VerifyPSS(key, v.hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			err = rsa.VerifyPKCS1v15(key, v.hash, digest, sig)
+		}
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size || !ecdsa.Verify(key, digest, new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])) {
+			err = errors.New("verification error")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid token signature: %w", err)
+	}
+	return nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// hasAudience reports whether the aud claim, a string or a list, names audience
+func hasAudience(aud interface{}, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
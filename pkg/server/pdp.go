@@ -0,0 +1,92 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kumarabd/policy-machine/pkg/service"
+)
+
+// CheckHandler evaluates a decision against the policy graph, optionally as of a past revision
+func (h *HTTPServer) CheckHandler(c *gin.Context) {
+	var req service.CheckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	decision, err := h.service.Check(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, decision)
+}
+
+// BatchCheckRequest is a list of decisions evaluated in one call
+type BatchCheckRequest struct {
+	Requests []service.CheckRequest `json:"requests"`
+}
+
+// BatchCheckHandler evaluates several decisions in one call
+func (h *HTTPServer) BatchCheckHandler(c *gin.Context) {
+	var req BatchCheckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	decisions, err := h.service.BatchCheck(c.Request.Context(), req.Requests)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"decisions": decisions})
+}
+
+// ExplainHandler evaluates a decision and returns the paths that produced it
+func (h *HTTPServer) ExplainHandler(c *gin.Context) {
+	var req service.CheckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	exp, err := h.service.Explain(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, exp)
+}
+
+// AccessibleObjectsHandler lists the objects a user may access
+func (h *HTTPServer) AccessibleObjectsHandler(c *gin.Context) {
+	user := c.Query("user")
+	if user == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user is required"})
+		return
+	}
+	asOf, err := queryRevision(c, "as_of")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	objects, rev, err := h.service.AccessibleObjects(c.Request.Context(), user, asOf)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"revision": rev, "objects": objects})
+}
+
+// LatestRevisionHandler returns the latest revision decisions are evaluated against
+func (h *HTTPServer) LatestRevisionHandler(c *gin.Context) {
+	_, rev, err := h.service.Graph(c.Request.Context(), 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"revision": rev})
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kumarabd/policy-machine/pkg/pml"
+)
+
+// PolicyHandler executes a PML document as a single revision
+func (h *HTTPServer) PolicyHandler(c *gin.Context) {
+	src, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rev, err := pml.Execute(c.Request.Context(), h.service, caller(c.Request.Context()), c.Query("message"), string(src))
+	if err != nil {
+		var perr *pml.Error
+		if errors.As(err, &perr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "line": perr.Pos.Line, "column": perr.Pos.Column})
+			return
+		}
+		c.JSON(commitStatus(err), errorBody(err))
+		return
+	}
+	c.JSON(http.StatusCreated, rev)
+}
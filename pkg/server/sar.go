@@ -0,0 +1,187 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kumarabd/policy-machine/pkg/model"
+	"github.com/kumarabd/policy-machine/pkg/service"
+)
+
+const (
+	sarAPIVersion = "authorization.k8s.io/v1"
+	sarKind       = "SubjectAccessReview"
+)
+
+// SubjectAccessReview mirrors authorization.k8s.io/v1 SubjectAccessReview
+// as sent by the kube-apiserver authorization webhook
+type SubjectAccessReview struct {
+	APIVersion string                    `json:"apiVersion"`
+	Kind       string                    `json:"kind"`
+	Metadata   map[string]interface{}    `json:"metadata,omitempty"`
+	Spec       SubjectAccessReviewSpec   `json:"spec"`
+	Status     SubjectAccessReviewStatus `json:"status"`
+}
+
+type SubjectAccessReviewSpec struct {
+	ResourceAttributes    *ResourceAttributes    `json:"resourceAttributes,omitempty"`
+	NonResourceAttributes *NonResourceAttributes `json:"nonResourceAttributes,omitempty"`
+	User                  string                 `json:"user,omitempty"`
+	Groups                []string               `json:"groups,omitempty"`
+	Extra                 map[string][]string    `json:"extra,omitempty"`
+	UID                   string                 `json:"uid,omitempty"`
+}
+
+type ResourceAttributes struct {
+	Namespace   string `json:"namespace,omitempty"`
+	Verb        string `json:"verb,omitempty"`
+	Group       string `json:"group,omitempty"`
+	Version     string `json:"version,omitempty"`
+	Resource    string `json:"resource,omitempty"`
+	Subresource string `json:"subresource,omitempty"`
+	Name        string `json:"name,omitempty"`
+}
+
+type NonResourceAttributes struct {
+	Path string `json:"path,omitempty"`
+	Verb string `json:"verb,omitempty"`
+}
+
+type SubjectAccessReviewStatus struct {
+	Allowed         bool   `json:"allowed"`
+	Denied          bool   `json:"denied,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+	EvaluationError string `json:"evaluationError,omitempty"`
+}
+
+// SubjectAccessReviewHandler answers kube-apiserver authorization webhook
+// requests. The user, when it is a user node, and each of its groups that is
+// a user attribute are evaluated as subjects and the verb as the operation
+// against the most specific node named after the resource (see sarObjects).
+// A prohibition matching any subject denies the request; when nothing grants
+// it the webhook has no opinion.
+func (h *HTTPServer) SubjectAccessReviewHandler(c *gin.Context) {
+	var sar SubjectAccessReview
+	if err := c.ShouldBindJSON(&sar); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if sar.APIVersion != sarAPIVersion || sar.Kind != sarKind {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("expected %s %s", sarAPIVersion, sarKind)})
+		return
+	}
+
+	status, err := h.reviewAccess(c.Request.Context(), sar.Spec)
+	if err != nil {
+		status = SubjectAccessReviewStatus{EvaluationError: err.Error()}
+	}
+	c.JSON(http.StatusOK, SubjectAccessReview{
+		APIVersion: sarAPIVersion,
+		Kind:       sarKind,
+		Metadata:   sar.Metadata,
+		Spec:       sar.Spec,
+		Status:     status,
+	})
+}
+
+func (h *HTTPServer) reviewAccess(ctx context.Context, spec SubjectAccessReviewSpec) (SubjectAccessReviewStatus, error) {
+	g, _, err := h.service.Graph(ctx, 0)
+	if err != nil {
+		return SubjectAccessReviewStatus{}, err
+	}
+
+	var verb string
+	switch {
+	case spec.ResourceAttributes != nil:
+		verb = spec.ResourceAttributes.Verb
+	case spec.NonResourceAttributes != nil:
+		verb = spec.NonResourceAttributes.Verb
+	}
+
+	object := ""
+	for _, candidate := range sarObjects(spec) {
+		if _, ok := g.Node(candidate); ok {
+			object = candidate
+			break
+		}
+	}
+	if object == "" {
+		return SubjectAccessReviewStatus{Reason: "no policy element for the requested resource"}, nil
+	}
+
+	// The user must be a user node and groups user attributes, so a user
+	// cannot claim the permissions of an attribute sharing its name
+	var subjects []string
+	if n, ok := g.Node(spec.User); ok && n.Type == model.User {
+		subjects = append(subjects, spec.User)
+	}
+	for _, s := range spec.Groups {
+		if n, ok := g.Node(s); ok && n.Type == model.UserAttribute {
+			subjects = append(subjects, s)
+		}
+	}
+	if len(subjects) == 0 {
+		return SubjectAccessReviewStatus{Reason: fmt.Sprintf("user %q and its groups are not in the policy graph", spec.User)}, nil
+	}
+
+	var granted *service.Explanation
+	for _, s := range subjects {
+		exp, err := h.service.Explain(ctx, service.CheckRequest{User: s, Object: object, Operation: verb})
+		if err != nil {
+			return SubjectAccessReviewStatus{}, err
+		}
+		if len(exp.Prohibitions) > 0 {
+			return SubjectAccessReviewStatus{Denied: true, Reason: fmt.Sprintf("%s: %s", s, exp.Reason)}, nil
+		}
+		if exp.Allowed && granted == nil {
+			granted = exp
+		}
+	}
+	if granted == nil {
+		return SubjectAccessReviewStatus{Reason: fmt.Sprintf("no association grants %s on %q", verb, object)}, nil
+	}
+	return SubjectAccessReviewStatus{Allowed: true, Reason: fmt.Sprintf("%s granted on %q at revision %d", verb, object, granted.Revision)}, nil
+}
+
+// sarObjects lists the node names a review may be evaluated against, most
+// specific first. Resources are written as kubectl does, resource.group with
+// an optional /subresource, so a namespaced request for deployments in
+// "prod" tries "prod/deployments.apps/web", "prod/deployments.apps" and then
+// "prod". Non-resource requests use the URL path.
+func sarObjects(spec SubjectAccessReviewSpec) []string {
+	if ra := spec.ResourceAttributes; ra != nil {
+		res := ra.Resource
+		if ra.Group != "" {
+			res += "." + ra.Group
+		}
+		if ra.Subresource != "" {
+			res += "/" + ra.Subresource
+		}
+
+		var out []string
+		prefix := ""
+		if ra.Namespace != "" {
+			prefix = ra.Namespace + "/"
+		}
+		if ra.Name != "" {
+			out = append(out, prefix+res+"/"+ra.Name)
+		}
+		out = append(out, prefix+res)
+		if ra.Namespace != "" {
+			out = append(out, ra.Namespace)
+		}
+		return out
+	}
+	if nra := spec.NonResourceAttributes; nra != nil {
+		out := []string{nra.Path}
+		for p := strings.TrimSuffix(nra.Path, "/"); strings.Contains(p, "/"); {
+			p = p[:strings.LastIndex(p, "/")]
+			out = append(out, p+"/*")
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSubjectAccessReview replays the recorded reviews in test/sar against
+// test/sar/cluster.pml and compares the allowed and denied fields
+func TestSubjectAccessReview(t *testing.T) {
+	const dir = "../../test/sar"
+	srv := newTestServer(t, filepath.Join(dir, "cluster.pml"))
+
+	requests, err := filepath.Glob(filepath.Join(dir, "requests", "*.json"))
+	if err != nil || len(requests) == 0 {
+		t.Fatalf("no review fixtures in %s: %v", dir, err)
+	}
+	for _, path := range requests {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(name, func(t *testing.T) {
+			body, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want SubjectAccessReviewStatus
+			readJSON(t, filepath.Join(dir, "expected", name+".json"), &want)
+
+			resp, err := http.Post(srv.URL+"/k8s/v1/subjectaccessreview", "application/json", bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status %d", resp.StatusCode)
+			}
+			var got SubjectAccessReview
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.APIVersion != "authorization.k8s.io/v1" || got.Kind != "SubjectAccessReview" {
+				t.Errorf("got %s %s, want authorization.k8s.io/v1 SubjectAccessReview", got.APIVersion, got.Kind)
+			}
+			if got.Status.Allowed != want.Allowed || got.Status.Denied != want.Denied {
+				t.Errorf("got allowed=%t denied=%t (%s), want allowed=%t denied=%t",
+					got.Status.Allowed, got.Status.Denied, got.Status.Reason, want.Allowed, want.Denied)
+			}
+		})
+	}
+}
+
+func readJSON(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+}
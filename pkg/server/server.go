@@ -1,71 +1,353 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"os"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/kumarabd/gokit/logger"
 	"github.com/kumarabd/policy-machine/internal/authz"
+	"github.com/kumarabd/policy-machine/internal/health"
+	"github.com/kumarabd/policy-machine/internal/metrics"
+	"github.com/kumarabd/policy-machine/internal/middleware"
+	"github.com/kumarabd/policy-machine/internal/monitoring"
+	"github.com/kumarabd/policy-machine/internal/tracing"
+	"github.com/kumarabd/policy-machine/pkg/audit"
+	"github.com/kumarabd/policy-machine/pkg/gitops"
+	"github.com/kumarabd/policy-machine/pkg/pep"
 	"github.com/kumarabd/policy-machine/pkg/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	defaultReadTimeout   = 30
+	defaultIdleTimeout   = 120
+	defaultShutdownGrace = 30
 )
 
 type Config struct {
-	HTTP HTTPServerConfig `json:"http,omitempty" yaml:"http,omitempty"`
+	HTTP    HTTPServerConfig         `json:"http,omitempty" yaml:"http,omitempty"`
+	GRPC    GRPCServerConfig         `json:"grpc,omitempty" yaml:"grpc,omitempty"`
+	Logging middleware.LoggingConfig `json:"logging,omitempty" yaml:"logging,omitempty"`
+	// ShutdownDelay is the number of seconds between reporting unready and
+	// closing the listeners, for load balancers to notice
+	ShutdownDelay int `json:"shutdown_delay,omitempty" yaml:"shutdown_delay,omitempty"`
+	// ShutdownGrace is the number of seconds in-flight requests get to
+	// complete on shutdown, 30 by default
+	ShutdownGrace int `json:"shutdown_grace,omitempty" yaml:"shutdown_grace,omitempty"`
+	// TLS serves both HTTP and gRPC over TLS when set
+	TLS TLSConfig `json:"tls,omitempty" yaml:"tls,omitempty"`
+	// Auth lists the tokens of the admin API and the OPA endpoints
+	Auth AuthConfig `json:"auth,omitempty" yaml:"auth,omitempty"`
+	// ExtAuthz maps the paths of requests proxied to ext_authz onto resources
+	ExtAuthz ExtAuthzConfig `json:"ext_authz,omitempty" yaml:"ext_authz,omitempty"`
+}
+
+// TLSConfig names the PEM certificate and key of the server
+type TLSConfig struct {
+	CertFile string `json:"cert_file,omitempty" yaml:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty" yaml:"key_file,omitempty"`
+}
+
+// Enabled reports whether the server is served over TLS
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// Validate reports every invalid setting
+func (c *Config) Validate() error {
+	var errs []error
+	if err := validPort(c.HTTP.Port); err != nil {
+		errs = append(errs, fmt.Errorf("http.port: %w", err))
+	}
+	if c.GRPC.Port != "" {
+		if err := validPort(c.GRPC.Port); err != nil {
+			errs = append(errs, fmt.Errorf("grpc.port: %w", err))
+		}
+	}
+	for key, n := range map[string]int{
+		"http.read_timeout":  c.HTTP.ReadTimeout,
+		"http.write_timeout": c.HTTP.WriteTimeout,
+		"http.idle_timeout":  c.HTTP.IdleTimeout,
+		"shutdown_delay":     c.ShutdownDelay,
+		"shutdown_grace":     c.ShutdownGrace,
+	} {
+		if n < 0 {
+			errs = append(errs, fmt.Errorf("%s: must not be negative", key))
+		}
+	}
+	if r := c.Logging.SampleRate; r < 0 || r > 1 {
+		errs = append(errs, fmt.Errorf("logging.sample_rate: %v is not between 0 and 1", r))
+	}
+	if c.TLS.Enabled() {
+		if c.TLS.CertFile == "" || c.TLS.KeyFile == "" {
+			errs = append(errs, errors.New("tls: cert_file and key_file must be set together"))
+		} else if _, err := tls.LoadX509KeyPair(c.TLS.CertFile, c.TLS.KeyFile); err != nil {
+			errs = append(errs, fmt.Errorf("tls: %w", err))
+		}
+	}
+	errs = append(errs, c.Auth.validate()...)
+	errs = append(errs, c.ExtAuthz.validate()...)
+	// Maps are unordered
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errors.Join(errs...)
+}
+
+func validPort(port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%q is not a port", port)
+	}
+	return nil
 }
 
 type Handler struct {
 	HTTPServer *HTTPServer
+	GRPCServer *GRPCServer
 
+	http   *http.Server
+	stop   context.CancelFunc
 	config *Config
 	log    *logger.Handler
 }
 
-func New(l *logger.Handler, config *Config, service *service.Handler) (*Handler, error) {
-	// Get OPA URL from environment
-	opaURL := os.Getenv("OPA_URL")
-	if opaURL == "" {
-		opaURL = "http://localhost:8181"
+func New(l *logger.Handler, m *metrics.Handler, config *Config, service *service.Handler, syncer *gitops.Syncer, evaluator authz.Evaluator, bundler *authz.Bundler, auditLog *audit.Log, checks *health.Registry, opts ...pep.Option) (*Handler, error) {
+	stopping, stop := context.WithCancel(context.Background())
+	httpObj := &HTTPServer{
+		stopping:  stopping,
+		service:   service,
+		evaluator: evaluator,
+		bundler:   bundler,
+		syncer:    syncer,
+		audit:     auditLog,
+		metrics:   m,
+		health:    checks,
+		auth:      newAuthenticator(config.Auth),
+	}
+	if len(config.Auth.Tokens) == 0 {
+		l.Warn().Msg("no auth tokens configured, the admin API and OPA endpoints refuse every request")
 	}
 
-	// Create OPA client
-	authzClient := authz.NewClient(opaURL)
+	// Decisions enforced here are recorded in the audit log
+	var recorder pep.Recorder
+	enforcerOpts := []pep.Option{}
+	if auditLog != nil {
+		recorder = auditLog
+		enforcerOpts = append(enforcerOpts, pep.WithRecorder(recorder))
+	}
+	enforcerOpts = append(enforcerOpts, opts...)
 
-	httpObj := &HTTPServer{
-		service:     service,
-		authzClient: authzClient,
+	extAuthz, err := newExtAuthz(&config.ExtAuthz, authz.NewPDP(evaluator, m), recorder)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	httpObj.extAuthz = extAuthz
+	if !config.ExtAuthz.Subject.JWT.set() && !config.ExtAuthz.Subject.Metadata.set() {
+		l.Warn().Msg("no ext_authz subject source configured, ext_authz refuses every request")
+	}
+
+	var grpcOpts []grpc.ServerOption
+	if config.TLS.Enabled() {
+		creds, err := credentials.NewServerTLSFromFile(config.TLS.CertFile, config.TLS.KeyFile)
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("failed to load tls certificate: %w", err)
+		}
+		grpcOpts = append(grpcOpts, grpc.Creds(creds))
 	}
 
 	// Initiate HTTP Server object
 	httpObj.handler = gin.New()
-	// Global middleware
-	httpObj.handler.Use(gin.Recovery())
+	// Global middleware. Logging comes first so recovered panics are logged
+	// as server errors.
+	httpObj.handler.Use(middleware.GinLogging(l, &config.Logging), gin.Recovery(), tracing.GinMiddleware(), monitoring.GinMiddleware(m))
 	gin.SetMode(gin.ReleaseMode)
 
 	// Health and metrics endpoints (no auth required)
 	httpObj.handler.GET("/healthz", httpObj.HealthHandler)
-	httpObj.handler.GET("/readyz", httpObj.HealthHandler)
+	httpObj.handler.GET("/readyz", httpObj.ReadyHandler)
 	httpObj.handler.GET("/metrics", httpObj.MetricsHandler)
 
+	// Policy decision endpoint
+	pdp := httpObj.handler.Group("/pdp/v1")
+	{
+		pdp.POST("/check", httpObj.CheckHandler)
+		pdp.POST("/batch_check", httpObj.BatchCheckHandler)
+		pdp.POST("/explain", httpObj.ExplainHandler)
+		pdp.GET("/objects", httpObj.AccessibleObjectsHandler)
+		pdp.GET("/revision", httpObj.LatestRevisionHandler)
+		pdp.GET("/watch", httpObj.RevisionWatchHandler)
+	}
+
+	// OpenID AuthZEN Authorization API
+	httpObj.handler.GET("/.well-known/authzen-configuration", httpObj.AuthZENConfigurationHandler)
+	access := httpObj.handler.Group("/access/v1", AuthZENMiddleware)
+	{
+		access.POST("/evaluation", httpObj.AuthZENEvaluationHandler)
+		access.POST("/evaluations", httpObj.AuthZENEvaluationsHandler)
+		access.POST("/search/subject", httpObj.AuthZENSearchSubjectHandler)
+		access.POST("/search/resource", httpObj.AuthZENSearchResourceHandler)
+		access.POST("/search/action", httpObj.AuthZENSearchActionHandler)
+	}
+
+	// Kubernetes authorization webhook
+	httpObj.handler.POST("/k8s/v1/subjectaccessreview", httpObj.SubjectAccessReviewHandler)
+
+	// Envoy ext_authz HTTP service
+	httpObj.handler.Any("/ext_authz/*path", httpObj.ExtAuthzHandler)
+
+	// OPA bundle of the policies and the graph
+	if bundler != nil {
+		httpObj.handler.GET("/bundles/authz.tar.gz", httpObj.requireScope(ScopeBundlesRead), httpObj.BundleHandler)
+	}
+
+	// OPA decision log uploads, at the plugin's default resource
+	httpObj.handler.POST("/logs", httpObj.requireScope(ScopeLogsWrite), httpObj.DecisionLogHandler)
+
+	// Graph administration endpoints, authenticated by bearer token
+	admin := httpObj.handler.Group("/admin/v1")
+	read, write := httpObj.requireScope(ScopeAdminRead), httpObj.requireScope(ScopeAdminWrite)
+	{
+		admin.GET("/graph", read, httpObj.GraphHandler)
+		admin.POST("/transactions", write, httpObj.TransactionHandler)
+		admin.POST("/transactions/preview", read, httpObj.TransactionPreviewHandler)
+		admin.POST("/policy", write, httpObj.PolicyHandler)
+		admin.GET("/export", read, httpObj.ExportHandler)
+		admin.POST("/import", write, httpObj.ImportHandler)
+		admin.GET("/revisions", read, httpObj.HistoryHandler)
+		admin.GET("/revisions/:revision", read, httpObj.RevisionHandler)
+		admin.GET("/diff", read, httpObj.DiffHandler)
+		admin.GET("/watch", read, httpObj.WatchHandler)
+		admin.GET("/changes", read, httpObj.ChangesHandler)
+		admin.GET("/sync", read, httpObj.SyncStatusHandler)
+		admin.POST("/sync", write, httpObj.SyncHandler)
+		admin.GET("/audit", read, httpObj.AuditSearchHandler)
+		admin.GET("/audit/export", read, httpObj.AuditExportHandler)
+	}
+
 	// Protected routes with authorization middleware
 	protected := httpObj.handler.Group("/api/v1")
-	protected.Use(authz.Middleware(authzClient))
+	protected.Use(authz.Middleware(evaluator, m, enforcerOpts...))
 	{
 		protected.GET("/users/:resource_id/data", httpObj.UserDataHandler)
 	}
 
 	return &Handler{
 		HTTPServer: httpObj,
-		config:     config,
-		log:        l,
+		GRPCServer: newGRPCServer(service, httpObj.auth, extAuthz, stopping, grpcOpts...),
+		http: &http.Server{
+			Addr:              fmt.Sprintf("0.0.0.0:%s", config.HTTP.Port),
+			Handler:           httpObj.handler,
+			ReadHeaderTimeout: seconds(config.HTTP.ReadTimeout, defaultReadTimeout),
+			ReadTimeout:       seconds(config.HTTP.ReadTimeout, defaultReadTimeout),
+			WriteTimeout:      seconds(config.HTTP.WriteTimeout, 0),
+			IdleTimeout:       seconds(config.HTTP.IdleTimeout, defaultIdleTimeout),
+		},
+		stop:   stop,
+		config: config,
+		log:    l,
 	}, nil
 }
 
+// ShutdownGrace is how long Shutdown should be given to drain requests
+func (h *Handler) ShutdownGrace() time.Duration {
+	return seconds(h.config.ShutdownGrace, defaultShutdownGrace) + seconds(h.config.ShutdownDelay, 0)
+}
+
 func (h *Handler) Run(ch chan struct{}) {
 	go func() {
 		h.log.Info().Msgf("started http server on port: %s", h.config.HTTP.Port)
-		err := h.HTTPServer.handler.Run(fmt.Sprintf("0.0.0.0:%s", h.config.HTTP.Port))
+		var err error
+		if tlsConfig := h.config.TLS; tlsConfig.Enabled() {
+			err = h.http.ListenAndServeTLS(tlsConfig.CertFile, tlsConfig.KeyFile)
+		} else {
+			err = h.http.ListenAndServe()
+		}
+		if errors.Is(err, http.ErrServerClosed) {
+			return
+		}
 		h.log.Error().Err(err).Msg("unable to start http server")
 		ch <- struct{}{}
 	}()
+
+	if h.config.GRPC.Port == "" {
+		return
+	}
+	go func() {
+		lis, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%s", h.config.GRPC.Port))
+		if err != nil {
+			h.log.Error().Err(err).Msg("unable to start grpc server")
+			ch <- struct{}{}
+			return
+		}
+		h.log.Info().Msgf("started grpc server on port: %s", h.config.GRPC.Port)
+		// Serve returns nil once stopped by Shutdown
+		if err := h.GRPCServer.handler.Serve(lis); err != nil {
+			h.log.Error().Err(err).Msg("grpc server stopped")
+			ch <- struct{}{}
+		}
+	}()
+}
+
+// Shutdown reports the server unready, waits the shutdown delay, then stops
+// accepting connections and waits for in-flight requests until ctx is done.
+// Watch streams and long polls are ended so clients resume elsewhere.
+func (h *Handler) Shutdown(ctx context.Context) error {
+	if h.HTTPServer.health != nil {
+		h.HTTPServer.health.Drain()
+	}
+	h.GRPCServer.health.Shutdown()
+	if delay := seconds(h.config.ShutdownDelay, 0); delay > 0 {
+		h.log.Info().Dur("delay", delay).Msg("reported unready, waiting before closing listeners")
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+	}
+	h.stop()
+
+	stopped := make(chan struct{})
+	go func() {
+		h.GRPCServer.handler.GracefulStop()
+		close(stopped)
+	}()
+	err := h.http.Shutdown(ctx)
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		// Cancels the gRPC calls still running
+		h.GRPCServer.handler.Stop()
+		<-stopped
+	}
+	if err != nil {
+		return fmt.Errorf("http requests still running at shutdown: %w", err)
+	}
+	return nil
+}
+
+// streamContext is cancelled with parent or when the server is stopping
+func streamContext(parent, stopping context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	stop := context.AfterFunc(stopping, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// seconds converts a configured number of seconds, using def when unset
+func seconds(n, def int) time.Duration {
+	if n <= 0 {
+		n = def
+	}
+	return time.Duration(n) * time.Second
 }
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/kumarabd/gokit/logger"
+	"github.com/kumarabd/policy-machine/internal/metrics"
+	"github.com/kumarabd/policy-machine/pkg/pml"
+	"github.com/kumarabd/policy-machine/pkg/service"
+	"github.com/kumarabd/policy-machine/pkg/store"
+	"github.com/rs/zerolog"
+)
+
+// newTestServer serves the routes of run over a memory store with the
+// policy file applied
+func newTestServer(t *testing.T, policy string) *httptest.Server {
+	t.Helper()
+	l := &logger.Handler{Logger: zerolog.Nop()}
+	m, err := metrics.New("test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := New(l, m, &Config{}, newTestService(t, l, m, policy), nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(h.HTTPServer.handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestService is a service over a memory store with the policy file applied
+func newTestService(t *testing.T, l *logger.Handler, m *metrics.Handler, policy string) *service.Handler {
+	t.Helper()
+	st, err := store.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := service.New(l, m, st, &service.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.SetHistory(st)
+	src, err := os.ReadFile(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pml.Execute(context.Background(), svc, "test", "fixture", string(src)); err != nil {
+		t.Fatalf("failed to apply %s: %v", policy, err)
+	}
+	return svc
+}
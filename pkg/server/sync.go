@@ -0,0 +1,51 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kumarabd/policy-machine/pkg/service"
+)
+
+// SyncStatusHandler reports the outcome of the last GitOps reconciliation
+func (h *HTTPServer) SyncStatusHandler(c *gin.Context) {
+	if h.syncer == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "gitops sync is not configured"})
+		return
+	}
+	c.JSON(http.StatusOK, h.syncer.Status())
+}
+
+// SyncHandler reconciles the store to the configured policy directory now.
+// With ?dry_run=true it only returns the plan and its access impact, which
+// the user, object and operation query parameters narrow.
+func (h *HTTPServer) SyncHandler(c *gin.Context) {
+	if h.syncer == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "gitops sync is not configured"})
+		return
+	}
+
+	if c.Query("dry_run") == "true" {
+		var filter service.ImpactFilter
+		if err := c.ShouldBindQuery(&filter); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		plan, err := h.syncer.Preview(c.Request.Context(), filter)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, errorBody(err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"plan": plan})
+		return
+	}
+
+	plan, rev, err := h.syncer.Sync(c.Request.Context())
+	if err != nil {
+		body := errorBody(err)
+		body["plan"] = plan
+		c.JSON(http.StatusUnprocessableEntity, body)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"plan": plan, "revision": rev})
+}
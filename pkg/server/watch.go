@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/kumarabd/policy-machine/pkg/model"
+)
+
+const (
+	sseKeepalive    = 15 * time.Second
+	longPollDefault = 30 * time.Second
+	longPollMax     = 5 * time.Minute
+)
+
+// WatchHandler streams graph change events as Server-Sent Events. Clients
+// resume with ?from=<revision> or the Last-Event-ID header.
+func (h *HTTPServer) WatchHandler(c *gin.Context) {
+	h.streamEvents(c, func(ev model.Event) interface{} { return ev })
+}
+
+// RevisionEvent announces a committed revision without its changes
+type RevisionEvent struct {
+	Type      model.EventType `json:"type"`
+	Revision  uint64          `json:"revision,omitempty"`
+	Compacted uint64          `json:"compacted,omitempty"`
+}
+
+// RevisionWatchHandler streams the numbers of committed revisions, so
+// decision clients can invalidate their caches without a token able to
+// read the graph. It reveals no more than /pdp/v1/revision.
+func (h *HTTPServer) RevisionWatchHandler(c *gin.Context) {
+	h.streamEvents(c, func(ev model.Event) interface{} {
+		out := RevisionEvent{Type: ev.Type, Compacted: ev.Compacted}
+		if ev.Revision != nil {
+			out.Revision = ev.Revision.Number
+		}
+		return out
+	})
+}
+
+// streamEvents streams the events after ?from=<revision> or the
+// Last-Event-ID header, rendering each with data
+func (h *HTTPServer) streamEvents(c *gin.Context, data func(model.Event) interface{}) {
+	from, err := queryRevision(c, "from")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if id := c.GetHeader("Last-Event-ID"); id != "" {
+		if from, err = strconv.ParseUint(id, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Last-Event-ID"})
+			return
+		}
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	ctx, cancel := streamContext(c.Request.Context(), h.stopping)
+	defer cancel()
+	events, err := h.service.Watch(ctx, from)
+	if err != nil {
+		var compacted *model.CompactedError
+		if !errors.As(err, &compacted) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// EventSource clients can't read error bodies, so the resync
+		// instruction is delivered as a regular event
+		c.Render(-1, sse.Event{
+			Event: string(model.EventResync),
+			Data:  data(model.Event{Type: model.EventResync, Compacted: compacted.Compacted}),
+		})
+		return
+	}
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return false
+			}
+			msg := sse.Event{Event: string(ev.Type), Data: data(ev)}
+			if ev.Revision != nil {
+				msg.Id = strconv.FormatUint(ev.Revision.Number, 10)
+			}
+			c.Render(-1, msg)
+			return ev.Type != model.EventResync
+		case <-time.After(sseKeepalive):
+			_, err := fmt.Fprint(w, ": keepalive\n\n")
+			return err == nil
+		}
+	})
+}
+
+// ChangesHandler long-polls for revisions after ?from=<revision>, waiting up
+// to ?wait=<duration> for the first one. A 410 response means the client must
+// reload the graph and resume from its revision.
+func (h *HTTPServer) ChangesHandler(c *gin.Context) {
+	from, err := queryRevision(c, "from")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	wait := longPollDefault
+	if v := c.Query("wait"); v != "" {
+		if wait, err = time.ParseDuration(v); err != nil || wait < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid wait duration"})
+			return
+		}
+		if wait > longPollMax {
+			wait = longPollMax
+		}
+	}
+
+	revisions, err := h.service.History(c.Request.Context(), from, 0)
+	if err == nil && len(revisions) == 0 && wait > 0 {
+		revisions, err = h.waitForChanges(c.Request.Context(), from, wait)
+	}
+	if err != nil {
+		var compacted *model.CompactedError
+		if errors.As(err, &compacted) {
+			c.JSON(http.StatusGone, gin.H{
+				"error":     err.Error(),
+				"type":      model.EventResync,
+				"compacted": compacted.Compacted,
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	next := from
+	if len(revisions) > 0 {
+		next = revisions[len(revisions)-1].Number
+	}
+	c.JSON(http.StatusOK, gin.H{"revision": next, "revisions": revisions})
+}
+
+// waitForChanges blocks until a revision after from is committed or the wait expires
+func (h *HTTPServer) waitForChanges(ctx context.Context, from uint64, wait time.Duration) ([]model.Revision, error) {
+	ctx, stop := streamContext(ctx, h.stopping)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	events, err := h.service.Watch(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			return nil, nil
+		}
+		if ev.Type == model.EventResync {
+			return nil, &model.CompactedError{Revision: from, Compacted: ev.Compacted}
+		}
+	case <-ctx.Done():
+		return nil, nil
+	}
+	return h.service.History(ctx, from, 0)
+}
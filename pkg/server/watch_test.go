@@ -0,0 +1,51 @@
+package server
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRevisionWatch(t *testing.T) {
+	srv := newTestServer(t, "../../policies/hospital.pml")
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		// data is the first event's data line, checked when set
+		data string
+	}{
+		{name: "revision numbers without a token", path: "/pdp/v1/watch?from=0", status: http.StatusOK, data: `data:{"type":"change","revision":1}`},
+		{name: "invalid revision", path: "/pdp/v1/watch?from=x", status: http.StatusBadRequest},
+		{name: "graph changes need a token", path: "/admin/v1/watch?from=0", status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.data == "" {
+				return
+			}
+			r := bufio.NewReader(resp.Body)
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					t.Fatal(err)
+				}
+				if strings.HasPrefix(line, "data:") {
+					if got := strings.TrimSpace(line); got != tt.data {
+						t.Fatalf("first event %s, want %s", got, tt.data)
+					}
+					return
+				}
+			}
+		})
+	}
+}
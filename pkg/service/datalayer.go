@@ -1,5 +1,17 @@
 package service
 
+import (
+	"context"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+)
+
 type DataLayer interface {
 	Ping() (bool, error)
+	Graph() (*model.Graph, uint64)
+	GraphAt(revision uint64) (*model.Graph, error)
+	Commit(author, message string, changes []model.Change) (*model.Revision, error)
+	CommitAt(base uint64, author, message string, tags map[string]string, changes []model.Change) (*model.Revision, error)
+	Revisions(from, to uint64) ([]model.Revision, error)
+	Watch(ctx context.Context, from uint64) (<-chan model.Event, error)
 }
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kumarabd/policy-machine/internal/metrics"
+	"github.com/kumarabd/policy-machine/internal/tracing"
+	"github.com/kumarabd/policy-machine/pkg/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	DecisionAllow = "allow"
+	DecisionDeny  = "deny"
+)
+
+// CheckRequest asks whether a user may perform an operation on an object.
+// AsOf evaluates against a past revision; zero means the latest.
+type CheckRequest struct {
+	User      string `json:"user" yaml:"user"`
+	Object    string `json:"object" yaml:"object"`
+	Operation string `json:"operation" yaml:"operation"`
+	AsOf      uint64 `json:"as_of,omitempty" yaml:"as_of,omitempty"`
+}
+
+// Decision is the outcome of a CheckRequest
+type Decision struct {
+	Allowed     bool                     `json:"allowed"`
+	Decision    string                   `json:"decision"`
+	Reason      string                   `json:"reason"`
+	Obligations []map[string]interface{} `json:"obligations"`
+	Revision    uint64                   `json:"revision"`
+	// Dynamic is set when a dynamic separation of duty constraint made the
+	// decision depend on the user's history, so it must not be cached
+	Dynamic bool `json:"dynamic,omitempty"`
+
+	// code classifies the reason with a bounded set of values for metrics
+	code string
+}
+
+// Reason codes of decisions
+const (
+	reasonAllowed       = "allowed"
+	reasonUnknownUser   = "unknown_user"
+	reasonUnknownObject = "unknown_object"
+	reasonProhibited    = "prohibited"
+	reasonNoPolicyClass = "no_policy_class"
+	reasonNoAssociation = "no_association"
+	reasonConstraint    = "constraint"
+)
+
+// Check evaluates the request against the policy graph. It has no side
+// effects: the operation is not recorded for separation of duty.
+func (h *Handler) Check(ctx context.Context, req CheckRequest) (*Decision, error) {
+	return h.check(ctx, "ngac.check", req, false)
+}
+
+// Enforce evaluates the request for a PEP that enforces the decision. An
+// allowed operation named by a dynamic separation of duty constraint is
+// recorded as performed by the user.
+func (h *Handler) Enforce(ctx context.Context, req CheckRequest) (*Decision, error) {
+	return h.check(ctx, "ngac.enforce", req, true)
+}
+
+func (h *Handler) check(ctx context.Context, name string, req CheckRequest, record bool) (*Decision, error) {
+	ctx, span := tracing.Start(ctx, name, checkAttributes(req)...)
+	defer span.End()
+
+	start := time.Now()
+	g, rev, err := h.graph(ctx, req.AsOf)
+	if err != nil {
+		tracing.Error(span, err)
+		return nil, err
+	}
+	d := evaluate(g, req)
+	d.Revision = rev
+	if err := h.separate(ctx, g, req, d, record); err != nil {
+		tracing.Error(span, err)
+		return nil, err
+	}
+	h.observe(g, req, d, time.Since(start))
+	traceDecision(span, d)
+	return d, nil
+}
+
+func checkAttributes(req CheckRequest) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		tracing.AttrPDP.String("ngac"),
+		tracing.AttrSubject.String(req.User),
+		tracing.AttrResource.String(req.Object),
+		tracing.AttrAction.String(req.Operation),
+	}
+}
+
+// traceDecision records the decision on the span
+func traceDecision(span trace.Span, d *Decision) {
+	tracing.Decision(span, d.Decision, d.code)
+	span.SetAttributes(tracing.AttrRevision.Int64(int64(d.Revision)))
+}
+
+// observe records the decision metrics. The action is the operation if an
+// association declares it and "other" otherwise; the resource kind is the
+// object's type property, as in AuthZEN, if it is a configured resource kind
+// and its node type otherwise.
+func (h *Handler) observe(g *model.Graph, req CheckRequest, d *Decision, elapsed time.Duration) {
+	action := "other"
+	if g.HasOperation(req.Operation) {
+		action = req.Operation
+	}
+	kind := "unknown"
+	if n, ok := g.Node(req.Object); ok {
+		kind = h.metric.ResourceKind(n.Properties["type"], string(n.Type))
+	}
+	var obligations []string
+	for _, o := range d.Obligations {
+		if t, ok := o["type"].(string); ok {
+			obligations = append(obligations, t)
+		}
+	}
+	h.metric.ObserveDecision(metrics.Decision{
+		PDP:          "ngac",
+		Decision:     d.Decision,
+		Reason:       d.code,
+		Action:       action,
+		ResourceKind: kind,
+		Obligations:  obligations,
+		Duration:     elapsed,
+	})
+}
+
+// graph resolves the graph to evaluate against
+func (h *Handler) graph(ctx context.Context, asOf uint64) (*model.Graph, uint64, error) {
+	_, span := tracing.Start(ctx, "store.graph", tracing.AttrRevision.Int64(int64(asOf)))
+	defer span.End()
+	if asOf == 0 {
+		g, rev := h.datalayer.Graph()
+		span.SetAttributes(tracing.AttrRevision.Int64(int64(rev)))
+		return g, rev, nil
+	}
+	g, err := h.datalayer.GraphAt(asOf)
+	if err != nil {
+		tracing.Error(span, err)
+		return nil, 0, err
+	}
+	return g, asOf, nil
+}
+
+// evaluate applies the NGAC decision procedure: the operation must be granted
+// through an association under every policy class containing the object, and
+// no prohibition on the user may cover it (deny overrides)
+func evaluate(g *model.Graph, req CheckRequest) *Decision {
+	deny := func(code, reason string) *Decision {
+		return &Decision{Decision: DecisionDeny, Reason: reason, Obligations: []map[string]interface{}{}, code: code}
+	}
+
+	if u, ok := g.Node(req.User); !ok || (u.Type != model.User && u.Type != model.UserAttribute) {
+		return deny(reasonUnknownUser, fmt.Sprintf("unknown user %q", req.User))
+	}
+	if o, ok := g.Node(req.Object); !ok || o.Type == model.PolicyClass || o.Type == model.User {
+		return deny(reasonUnknownObject, fmt.Sprintf("unknown object %q", req.Object))
+	}
+
+	subjects := g.Ancestors(req.User)
+	subjects[req.User] = struct{}{}
+	targets := g.Ancestors(req.Object)
+	targets[req.Object] = struct{}{}
+
+	for _, p := range g.Prohibitions() {
+		if prohibits(g, p, subjects, targets, req.Operation) {
+			return deny(reasonProhibited, fmt.Sprintf("prohibition %q denies %s", p.Name, req.Operation))
+		}
+	}
+
+	// Collect the policy classes containing the object and those satisfied
+	// by an association granting the operation
+	required := map[string]struct{}{}
+	for t := range targets {
+		if n, _ := g.Node(t); n.Type == model.PolicyClass {
+			required[t] = struct{}{}
+		}
+	}
+	if len(required) == 0 {
+		return deny(reasonNoPolicyClass, fmt.Sprintf("object %q is not contained in any policy class", req.Object))
+	}
+
+	satisfied := map[string]struct{}{}
+	for ua := range subjects {
+		for target, ops := range g.AssociationsFrom(ua) {
+			if _, ok := targets[target]; !ok || !hasOperation(ops, req.Operation) {
+				continue
+			}
+			for pc := range required {
+				if _, ok := g.Ancestors(target)[pc]; ok || target == pc {
+					satisfied[pc] = struct{}{}
+				}
+			}
+		}
+	}
+
+	var missing []string
+	for pc := range required {
+		if _, ok := satisfied[pc]; !ok {
+			missing = append(missing, pc)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return deny(reasonNoAssociation, fmt.Sprintf("no association grants %s under policy class %q", req.Operation, missing[0]))
+	}
+
+	obligations := []map[string]interface{}{}
+	for _, o := range g.Obligations() {
+		_, subject := subjects[o.Subject]
+		_, target := targets[o.Target]
+		if subject && target && hasOperation(o.Operations, req.Operation) {
+			obligations = append(obligations, o.Response)
+		}
+	}
+
+	return &Decision{
+		Allowed:     true,
+		Decision:    DecisionAllow,
+		Reason:      "allowed",
+		Obligations: obligations,
+		code:        reasonAllowed,
+	}
+}
+
+func prohibits(g *model.Graph, p model.Prohibition, subjects, targets map[string]struct{}, op string) bool {
+	if _, ok := subjects[p.Subject]; !ok || !hasOperation(p.Operations, op) {
+		return false
+	}
+	for _, c := range p.Containers {
+		_, in := targets[c]
+		if p.Intersection && !in {
+			return false
+		}
+		if !p.Intersection && in {
+			return true
+		}
+	}
+	return p.Intersection
+}
+
+func hasOperation(ops []string, op string) bool {
+	for _, o := range ops {
+		if o == op || o == "*" {
+			return true
+		}
+	}
+	return false
+}
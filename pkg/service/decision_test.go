@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kumarabd/gokit/logger"
+	"github.com/kumarabd/policy-machine/internal/metrics"
+	"github.com/kumarabd/policy-machine/pkg/pml"
+	"github.com/kumarabd/policy-machine/pkg/store"
+	"github.com/rs/zerolog"
+)
+
+// TestObserveLabels checks that request values outside the policy and the
+// configured resource kinds do not become label values
+func TestObserveLabels(t *testing.T) {
+	m, err := metrics.New("test", &metrics.Options{ResourceKinds: []string{"record"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := store.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := New(&logger.Handler{Logger: zerolog.Nop()}, m, st, &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := pml.Execute(ctx, h, "test", "fixture", `create PC "pc"
+create UA "staff" in ["pc"]
+create U "alice" in ["staff"]
+create OA "docs" in ["pc"]
+create O "rec" in ["docs"] with properties {"type": "record"}
+create O "memo" in ["docs"] with properties {"type": "memo-x1"}
+associate "staff" and "docs" with ["read"]`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		req  CheckRequest
+		want string
+	}{
+		{name: "configured kind", req: CheckRequest{User: "alice", Object: "rec", Operation: "read"}, want: `action="read",decision="allow",pdp="ngac",reason="allowed",resource_kind="record"`},
+		{name: "other kind", req: CheckRequest{User: "alice", Object: "memo", Operation: "read"}, want: `action="read",decision="allow",pdp="ngac",reason="allowed",resource_kind="O"`},
+		{name: "undeclared operation", req: CheckRequest{User: "alice", Object: "rec", Operation: "op-x2"}, want: `action="other",decision="deny",pdp="ngac",reason="no_association",resource_kind="record"`},
+		{name: "unknown object", req: CheckRequest{User: "alice", Object: "obj-x3", Operation: "read"}, want: `action="read",decision="deny",pdp="ngac",reason="unknown_object",resource_kind="unknown"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := h.Check(ctx, tt.req); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	m.HTTPHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	var lines []string
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.HasPrefix(line, "authz_decisions_total{") {
+			lines = append(lines, line)
+		}
+	}
+	for _, tt := range tests {
+		found := false
+		for _, line := range lines {
+			found = found || strings.Contains(line, tt.want)
+		}
+		if !found {
+			t.Errorf("%s: no series with %s in %v", tt.name, tt.want, lines)
+		}
+	}
+	for _, value := range []string{"memo-x1", "op-x2", "obj-x3"} {
+		if strings.Contains(strings.Join(lines, "\n"), value) {
+			t.Errorf("request value %s became a label value", value)
+		}
+	}
+}
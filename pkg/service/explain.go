@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/kumarabd/policy-machine/internal/tracing"
+	"github.com/kumarabd/policy-machine/pkg/model"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Explanation is a decision together with the graph paths that produced it
+type Explanation struct {
+	Decision
+	PolicyClasses []PolicyClassExplanation `json:"policy_classes"`
+	Prohibitions  []string                 `json:"prohibitions"`
+}
+
+// PolicyClassExplanation lists the associations granting the operation under
+// one policy class containing the object
+type PolicyClassExplanation struct {
+	PolicyClass string  `json:"policy_class"`
+	Satisfied   bool    `json:"satisfied"`
+	Grants      []Grant `json:"grants"`
+}
+
+// Grant is an association reached from the user and the object
+type Grant struct {
+	UA         string   `json:"ua"`
+	Target     string   `json:"target"`
+	Operations []string `json:"operations"`
+	UserPath   []string `json:"user_path"`
+	ObjectPath []string `json:"object_path"`
+}
+
+// AccessibleObject is an object and the operations a user may perform on it
+type AccessibleObject struct {
+	Object     string   `json:"object"`
+	Operations []string `json:"operations"`
+}
+
+// BatchCheck evaluates several requests like Check. Requests sharing an
+// as_of revision are evaluated against the same graph snapshot.
+func (h *Handler) BatchCheck(ctx context.Context, reqs []CheckRequest) ([]*Decision, error) {
+	return h.BatchCheckUntil(ctx, reqs, nil)
+}
+
+// BatchCheckUntil evaluates the requests in order like BatchCheck and stops
+// after the first decision stop reports true for. The remaining requests are
+// not evaluated.
+func (h *Handler) BatchCheckUntil(ctx context.Context, reqs []CheckRequest, stop func(*Decision) bool) ([]*Decision, error) {
+	ctx, span := tracing.Start(ctx, "ngac.batch_check", attribute.Int("authz.requests", len(reqs)))
+	defer span.End()
+
+	type snapshot struct {
+		g   *model.Graph
+		rev uint64
+	}
+	graphs := map[uint64]snapshot{}
+	out := make([]*Decision, 0, len(reqs))
+	for _, req := range reqs {
+		snap, ok := graphs[req.AsOf]
+		if !ok {
+			g, rev, err := h.graph(ctx, req.AsOf)
+			if err != nil {
+				tracing.Error(span, err)
+				return nil, err
+			}
+			snap = snapshot{g: g, rev: rev}
+			graphs[req.AsOf] = snap
+		}
+		_, check := tracing.Start(ctx, "ngac.check", checkAttributes(req)...)
+		start := time.Now()
+		d := evaluate(snap.g, req)
+		d.Revision = snap.rev
+		if err := h.separate(ctx, snap.g, req, d, false); err != nil {
+			tracing.Error(check, err)
+			check.End()
+			return nil, err
+		}
+		h.observe(snap.g, req, d, time.Since(start))
+		traceDecision(check, d)
+		check.End()
+		out = append(out, d)
+		if stop != nil && stop(d) {
+			break
+		}
+	}
+	span.SetAttributes(attribute.Int("authz.evaluated", len(out)))
+	return out, nil
+}
+
+// Explain evaluates the request and reports, for every policy class
+// containing the object, which associations grant the operation and through
+// which assignments, along with any prohibitions that matched
+func (h *Handler) Explain(ctx context.Context, req CheckRequest) (*Explanation, error) {
+	ctx, span := tracing.Start(ctx, "ngac.explain", checkAttributes(req)...)
+	defer span.End()
+
+	g, rev, err := h.graph(ctx, req.AsOf)
+	if err != nil {
+		tracing.Error(span, err)
+		return nil, err
+	}
+	d := evaluate(g, req)
+	d.Revision = rev
+	if err := h.separate(ctx, g, req, d, false); err != nil {
+		tracing.Error(span, err)
+		return nil, err
+	}
+	traceDecision(span, d)
+	exp := &Explanation{Decision: *d, PolicyClasses: []PolicyClassExplanation{}, Prohibitions: []string{}}
+	if _, ok := g.Node(req.User); !ok {
+		return exp, nil
+	}
+	if _, ok := g.Node(req.Object); !ok {
+		return exp, nil
+	}
+
+	subjects := g.Ancestors(req.User)
+	subjects[req.User] = struct{}{}
+	targets := g.Ancestors(req.Object)
+	targets[req.Object] = struct{}{}
+
+	for _, p := range g.Prohibitions() {
+		if prohibits(g, p, subjects, targets, req.Operation) {
+			exp.Prohibitions = append(exp.Prohibitions, p.Name)
+		}
+	}
+
+	var pcs []string
+	for t := range targets {
+		if n, _ := g.Node(t); n.Type == model.PolicyClass {
+			pcs = append(pcs, t)
+		}
+	}
+	sort.Strings(pcs)
+
+	for _, pc := range pcs {
+		pce := PolicyClassExplanation{PolicyClass: pc, Grants: []Grant{}}
+		for _, a := range g.Associations() {
+			if _, ok := subjects[a.UA]; !ok {
+				continue
+			}
+			if _, ok := targets[a.Target]; !ok || !hasOperation(a.Operations, req.Operation) {
+				continue
+			}
+			if _, ok := g.Ancestors(a.Target)[pc]; !ok && a.Target != pc {
+				continue
+			}
+			pce.Grants = append(pce.Grants, Grant{
+				UA:         a.UA,
+				Target:     a.Target,
+				Operations: a.Operations,
+				UserPath:   g.Path(req.User, a.UA),
+				ObjectPath: g.Path(req.Object, a.Target),
+			})
+		}
+		pce.Satisfied = len(pce.Grants) > 0
+		exp.PolicyClasses = append(exp.PolicyClasses, pce)
+	}
+	return exp, nil
+}
+
+// AccessibleObjects lists every object the user may access and the
+// operations allowed on each
+func (h *Handler) AccessibleObjects(ctx context.Context, user string, asOf uint64) ([]AccessibleObject, uint64, error) {
+	g, rev, err := h.graph(ctx, asOf)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ops := candidateOperations(g, user)
+
+	out := []AccessibleObject{}
+	for _, n := range g.Nodes() {
+		if n.Type != model.Object {
+			continue
+		}
+		var allowed []string
+		for _, op := range ops {
+			if evaluate(g, CheckRequest{User: user, Object: n.Name, Operation: op}).Allowed {
+				allowed = append(allowed, op)
+			}
+		}
+		if len(allowed) > 0 {
+			out = append(out, AccessibleObject{Object: n.Name, Operations: allowed})
+		}
+	}
+	return out, rev, nil
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+)
+
+// Commit applies the changes to the store as a single revision
+func (h *Handler) Commit(ctx context.Context, author, message string, changes []model.Change) (*model.Revision, error) {
+	rev, err := h.datalayer.Commit(author, message, changes)
+	if err != nil {
+		return nil, err
+	}
+	h.log.Info().Uint64("revision", rev.Number).Str("author", author).Int("changes", len(changes)).Msg("policy revision committed")
+	return rev, nil
+}
+
+// Replace makes the stored graph identical to g in a single revision tagged
+// with tags. It returns nil when the graph already matches.
+func (h *Handler) Replace(ctx context.Context, author, message string, tags map[string]string, g *model.Graph) (*model.Revision, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		current, base := h.datalayer.Graph()
+		changes := model.Diff(current, g)
+		if len(changes) == 0 {
+			return nil, nil
+		}
+		rev, err := h.datalayer.CommitAt(base, author, message, tags, changes)
+		var conflict *model.ConflictError
+		if errors.As(err, &conflict) {
+			// Another writer got in first; recompute against the new head
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		h.log.Info().Uint64("revision", rev.Number).Str("author", author).Int("changes", len(changes)).Msg("policy graph replaced")
+		return rev, nil
+	}
+}
+
+// Graph returns the graph at the given revision, or the latest when zero
+func (h *Handler) Graph(ctx context.Context, revision uint64) (*model.Graph, uint64, error) {
+	return h.graph(ctx, revision)
+}
+
+// History returns the revisions in the range (from, to]
+func (h *Handler) History(ctx context.Context, from, to uint64) ([]model.Revision, error) {
+	return h.datalayer.Revisions(from, to)
+}
+
+// Diff returns the changes that transform the graph at revision from into the
+// graph at revision to. Revision zero is the empty graph.
+func (h *Handler) Diff(ctx context.Context, from, to uint64) ([]model.Change, error) {
+	a, err := h.datalayer.GraphAt(from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := h.datalayer.GraphAt(to)
+	if err != nil {
+		return nil, err
+	}
+	return model.Diff(a, b), nil
+}
+
+// Watch streams graph change events for every revision after from
+func (h *Handler) Watch(ctx context.Context, from uint64) (<-chan model.Event, error) {
+	return h.datalayer.Watch(ctx, from)
+}
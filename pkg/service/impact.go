@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"github.com/kumarabd/policy-machine/internal/tracing"
+	"github.com/kumarabd/policy-machine/pkg/model"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Access is an operation a user may perform on an object
+type Access struct {
+	User      string `json:"user"`
+	Object    string `json:"object"`
+	Operation string `json:"operation"`
+}
+
+// ImpactFilter narrows an impact analysis to a user, an object or an
+// operation. Empty fields match everything.
+type ImpactFilter struct {
+	User      string `json:"user,omitempty" form:"user"`
+	Object    string `json:"object,omitempty" form:"object"`
+	Operation string `json:"operation,omitempty" form:"operation"`
+}
+
+// Impact is the access gained and lost when a graph changes
+type Impact struct {
+	Gained  []Access      `json:"gained"`
+	Lost    []Access      `json:"lost"`
+	Summary ImpactSummary `json:"summary"`
+}
+
+// ImpactSummary counts the access changed and who and what it concerns
+type ImpactSummary struct {
+	Gained  int `json:"gained"`
+	Lost    int `json:"lost"`
+	Users   int `json:"users"`
+	Objects int `json:"objects"`
+}
+
+// Preview is the outcome of a transaction that was not committed
+type Preview struct {
+	Base    uint64         `json:"base_revision"`
+	Changes []model.Change `json:"changes"`
+	Impact  *Impact        `json:"impact"`
+}
+
+// Preview applies the changes to a copy of the latest graph and reports the
+// access they would grant and revoke. Nothing is committed.
+func (h *Handler) Preview(ctx context.Context, changes []model.Change, f ImpactFilter) (*Preview, error) {
+	ctx, span := tracing.Start(ctx, "ngac.preview", attribute.Int("authz.changes", len(changes)))
+	defer span.End()
+
+	current, rev, err := h.graph(ctx, 0)
+	if err != nil {
+		tracing.Error(span, err)
+		return nil, err
+	}
+	proposed := current.Clone()
+	for i, ch := range changes {
+		if err := proposed.Apply(ch); err != nil {
+			err = &model.InvalidChangeError{Index: i, Change: ch, Err: err}
+			tracing.Error(span, err)
+			return nil, err
+		}
+	}
+	if err := proposed.CheckConstraints(); err != nil {
+		tracing.Error(span, err)
+		return nil, err
+	}
+	return &Preview{Base: rev, Changes: changes, Impact: AccessImpact(current, proposed, f)}, nil
+}
+
+// AccessImpact compares the access users have to objects in two graphs. Only
+// operations granted by some association of the user in either graph are
+// considered; no other operation can be allowed.
+func AccessImpact(before, after *model.Graph, f ImpactFilter) *Impact {
+	impact := &Impact{Gained: []Access{}, Lost: []Access{}}
+	users := map[string]struct{}{}
+	objects := map[string]struct{}{}
+
+	for _, user := range nodeNames(model.User, f.User, before, after) {
+		ops := map[string]struct{}{}
+		for _, g := range []*model.Graph{before, after} {
+			if _, ok := g.Node(user); !ok {
+				continue
+			}
+			for _, op := range candidateOperations(g, user) {
+				if f.Operation == "" || op == f.Operation {
+					ops[op] = struct{}{}
+				}
+			}
+		}
+		if len(ops) == 0 {
+			continue
+		}
+
+		for _, object := range nodeNames(model.Object, f.Object, before, after) {
+			for _, op := range sortedSet(ops) {
+				req := CheckRequest{User: user, Object: object, Operation: op}
+				was := evaluate(before, req).Allowed
+				is := evaluate(after, req).Allowed
+				if was == is {
+					continue
+				}
+				a := Access{User: user, Object: object, Operation: op}
+				if is {
+					impact.Gained = append(impact.Gained, a)
+				} else {
+					impact.Lost = append(impact.Lost, a)
+				}
+				users[user] = struct{}{}
+				objects[object] = struct{}{}
+			}
+		}
+	}
+
+	impact.Summary = ImpactSummary{
+		Gained:  len(impact.Gained),
+		Lost:    len(impact.Lost),
+		Users:   len(users),
+		Objects: len(objects),
+	}
+	return impact
+}
+
+// nodeNames lists the nodes of a type in either graph, or only name when set
+func nodeNames(t model.NodeType, name string, graphs ...*model.Graph) []string {
+	names := map[string]struct{}{}
+	for _, g := range graphs {
+		if name != "" {
+			if n, ok := g.Node(name); ok && n.Type == t {
+				names[name] = struct{}{}
+			}
+			continue
+		}
+		for _, n := range g.Nodes() {
+			if n.Type == t {
+				names[n.Name] = struct{}{}
+			}
+		}
+	}
+	return sortedSet(names)
+}
+
+func sortedSet(set map[string]struct{}) []string {
+	out := make([]string, 0, len(set))
+	for s := range set {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
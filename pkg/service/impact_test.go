@@ -0,0 +1,134 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+	"github.com/kumarabd/policy-machine/pkg/pml"
+)
+
+const records = `create PC "pc"
+create UA "staff" in ["pc"]
+create UA "doctors" in ["staff"]
+create U "alice" in ["doctors"]
+create U "bob" in ["staff"]
+create OA "records" in ["pc"]
+create O "rec1" in ["records"]
+create O "rec2" in ["records"]
+create OA "notes" in ["pc"]
+create O "note" in ["notes"]
+associate "staff" and "records" with ["read"]
+associate "doctors" and "records" with ["write"]`
+
+// apply compiles the PML source against g and applies it
+func apply(t *testing.T, g *model.Graph, src string) {
+	t.Helper()
+	stmts, err := pml.Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := pml.Compile(g, stmts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ch := range changes {
+		if err := g.Apply(ch); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAccessImpact(t *testing.T) {
+	tests := []struct {
+		name   string
+		change string
+		filter ImpactFilter
+		gained []Access
+		lost   []Access
+		// users and objects counted in the summary
+		users, objects int
+	}{
+		{name: "no change"},
+		{
+			name:   "association added",
+			change: `associate "staff" and "notes" with ["read"]`,
+			gained: []Access{{"alice", "note", "read"}, {"bob", "note", "read"}},
+			users:  2, objects: 1,
+		},
+		{
+			name:   "association removed",
+			change: `dissociate "doctors" and "records"`,
+			lost:   []Access{{"alice", "rec1", "write"}, {"alice", "rec2", "write"}},
+			users:  1, objects: 2,
+		},
+		{
+			name:   "prohibition created",
+			change: `create prohibition "p" deny user "bob" access rights ["read"] on ["records"]`,
+			lost:   []Access{{"bob", "rec1", "read"}, {"bob", "rec2", "read"}},
+			users:  1, objects: 2,
+		},
+		{
+			name:   "user assigned",
+			change: `assign "bob" to ["doctors"]`,
+			gained: []Access{{"bob", "rec1", "write"}, {"bob", "rec2", "write"}},
+			users:  1, objects: 2,
+		},
+		{
+			name:   "object moved",
+			change: `assign "note" to ["records"]; deassign "note" from ["notes"]; deassign "rec2" from ["records"]; assign "rec2" to ["notes"]`,
+			gained: []Access{{"alice", "note", "read"}, {"alice", "note", "write"}, {"bob", "note", "read"}},
+			lost:   []Access{{"alice", "rec2", "read"}, {"alice", "rec2", "write"}, {"bob", "rec2", "read"}},
+			users:  2, objects: 2,
+		},
+		{
+			name:   "user deleted",
+			change: `delete node "bob"`,
+			lost:   []Access{{"bob", "rec1", "read"}, {"bob", "rec2", "read"}},
+			users:  1, objects: 2,
+		},
+		{
+			name:   "filtered by user",
+			change: `associate "staff" and "notes" with ["read"]`,
+			filter: ImpactFilter{User: "bob"},
+			gained: []Access{{"bob", "note", "read"}},
+			users:  1, objects: 1,
+		},
+		{
+			name:   "filtered by object",
+			change: `dissociate "doctors" and "records"`,
+			filter: ImpactFilter{Object: "rec2"},
+			lost:   []Access{{"alice", "rec2", "write"}},
+			users:  1, objects: 1,
+		},
+		{
+			name:   "filtered by operation",
+			change: `assign "bob" to ["doctors"]; create prohibition "p" deny user "alice" access rights ["read"] on ["records"]`,
+			filter: ImpactFilter{Operation: "read"},
+			lost:   []Access{{"alice", "rec1", "read"}, {"alice", "rec2", "read"}},
+			users:  1, objects: 2,
+		},
+		{
+			name:   "filter matching no node",
+			change: `associate "staff" and "notes" with ["read"]`,
+			filter: ImpactFilter{User: "records"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := model.NewGraph()
+			apply(t, before, records)
+			after := before.Clone()
+			apply(t, after, tt.change)
+
+			want := &Impact{
+				Gained:  append([]Access{}, tt.gained...),
+				Lost:    append([]Access{}, tt.lost...),
+				Summary: ImpactSummary{Gained: len(tt.gained), Lost: len(tt.lost), Users: tt.users, Objects: tt.objects},
+			}
+			if got := AccessImpact(before, after, tt.filter); !reflect.DeepEqual(got, want) {
+				t.Fatalf("AccessImpact() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+
+	"github.com/kumarabd/policy-machine/pkg/pep"
+)
+
+var _ pep.PDP = (*Handler)(nil)
+
+// Decide implements pep.PDP, so an Enforcer can decide in process. The
+// Enforcer enforces the decision, so it is made with Enforce.
+func (h *Handler) Decide(ctx context.Context, req pep.Request) (*pep.Decision, error) {
+	d, err := h.Enforce(ctx, CheckRequest{User: req.Subject, Object: req.Resource, Operation: req.Action})
+	if err != nil {
+		return nil, err
+	}
+	return d.PEP(), nil
+}
+
+// PEP converts the decision to the form enforced by pkg/pep
+func (d *Decision) PEP() *pep.Decision {
+	out := &pep.Decision{Allowed: d.Allowed, Reason: d.Reason, Revision: d.Revision}
+	for _, o := range d.Obligations {
+		out.Obligations = append(out.Obligations, pep.Obligation(o))
+	}
+	return out
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+)
+
+// SearchSubjects lists the users allowed to perform the operation on the object
+func (h *Handler) SearchSubjects(ctx context.Context, object, operation string, asOf uint64) ([]model.Node, uint64, error) {
+	g, rev, err := h.graph(ctx, asOf)
+	if err != nil {
+		return nil, 0, err
+	}
+	out := []model.Node{}
+	for _, n := range g.Nodes() {
+		if n.Type != model.User {
+			continue
+		}
+		if evaluate(g, CheckRequest{User: n.Name, Object: object, Operation: operation}).Allowed {
+			out = append(out, n)
+		}
+	}
+	return out, rev, nil
+}
+
+// SearchResources lists the objects the user may perform the operation on
+func (h *Handler) SearchResources(ctx context.Context, user, operation string, asOf uint64) ([]model.Node, uint64, error) {
+	g, rev, err := h.graph(ctx, asOf)
+	if err != nil {
+		return nil, 0, err
+	}
+	out := []model.Node{}
+	for _, n := range g.Nodes() {
+		if n.Type != model.Object {
+			continue
+		}
+		if evaluate(g, CheckRequest{User: user, Object: n.Name, Operation: operation}).Allowed {
+			out = append(out, n)
+		}
+	}
+	return out, rev, nil
+}
+
+// SearchActions lists the operations the user may perform on the object
+func (h *Handler) SearchActions(ctx context.Context, user, object string, asOf uint64) ([]string, uint64, error) {
+	g, rev, err := h.graph(ctx, asOf)
+	if err != nil {
+		return nil, 0, err
+	}
+	out := []string{}
+	for _, op := range candidateOperations(g, user) {
+		if evaluate(g, CheckRequest{User: user, Object: object, Operation: op}).Allowed {
+			out = append(out, op)
+		}
+	}
+	return out, rev, nil
+}
+
+// candidateOperations returns the operations granted by any association of
+// the user's attributes; no other operation can be allowed. The "*"
+// wildcard stands for every operation the policy names.
+func candidateOperations(g *model.Graph, user string) []string {
+	subjects := g.Ancestors(user)
+	subjects[user] = struct{}{}
+	candidates := map[string]struct{}{}
+	for ua := range subjects {
+		for _, ops := range g.AssociationsFrom(ua) {
+			for _, op := range ops {
+				candidates[op] = struct{}{}
+			}
+		}
+	}
+	if _, ok := candidates["*"]; ok {
+		delete(candidates, "*")
+		for _, op := range namedOperations(g) {
+			if op != "*" {
+				candidates[op] = struct{}{}
+			}
+		}
+	}
+	ops := make([]string, 0, len(candidates))
+	for op := range candidates {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+// namedOperations lists the operations named by the associations,
+// prohibitions, obligations and constraints of the graph
+func namedOperations(g *model.Graph) []string {
+	var ops []string
+	for _, a := range g.Associations() {
+		ops = append(ops, a.Operations...)
+	}
+	for _, p := range g.Prohibitions() {
+		ops = append(ops, p.Operations...)
+	}
+	for _, o := range g.Obligations() {
+		ops = append(ops, o.Operations...)
+	}
+	for _, c := range g.Constraints() {
+		ops = append(ops, c.Operations...)
+	}
+	return ops
+}
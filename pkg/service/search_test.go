@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSearchActions(t *testing.T) {
+	h := newTestHandler(t, `create PC "pc"
+create UA "admins" in ["pc"]
+create UA "clerks" in ["pc"]
+create U "root" in ["admins"]
+create U "alice" in ["clerks"]
+create U "eve" in ["admins"]
+create OA "claims" in ["pc"]
+create O "claim" in ["claims"]
+associate "admins" and "claims" with ["*"]
+associate "clerks" and "claims" with ["read", "submit"]
+create prohibition "eve_no_delete" deny user "eve" access rights ["delete"] on union of ["claims"]
+create constraint "claims_sod" separates operations ["submit", "approve"] on ["claims"]`, false)
+
+	tests := []struct {
+		user string
+		want string
+	}{
+		{user: "root", want: "approve,delete,read,submit"},
+		{user: "eve", want: "approve,read,submit"},
+		{user: "alice", want: "read,submit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.user, func(t *testing.T) {
+			got, _, err := h.SearchActions(context.Background(), tt.user, "claim", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Fatalf("SearchActions() = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+)
+
+// History records the operations users were allowed to perform, against
+// which dynamic separation of duty constraints are enforced
+type History interface {
+	// Performed returns the operations the user was allowed to perform on the object
+	Performed(ctx context.Context, user, object string) ([]string, error)
+	// Record adds an operation the user was allowed to perform on the object
+	Record(ctx context.Context, user, object, operation string) error
+}
+
+// SetHistory enables dynamic separation of duty. Without a history dynamic
+// constraints are not enforced.
+func (h *Handler) SetHistory(history History) {
+	h.history = history
+}
+
+// separate turns an allow into a deny when the user has already performed
+// another operation of a dynamic constraint on the same object. Otherwise
+// the operation is recorded when record is set, under the same lock as the
+// history was read so concurrent requests cannot both be allowed. Decisions
+// against past revisions are left alone since the history is not versioned.
+func (h *Handler) separate(ctx context.Context, g *model.Graph, req CheckRequest, d *Decision, record bool) error {
+	if !d.Allowed || h.history == nil || req.AsOf != 0 {
+		return nil
+	}
+
+	var (
+		targets     map[string]struct{}
+		constraints []model.Constraint
+	)
+	for _, c := range g.Constraints() {
+		if c.Type != model.DynamicSoD || !exactOperation(c.Operations, req.Operation) {
+			continue
+		}
+		if len(c.Containers) > 0 {
+			if targets == nil {
+				targets = g.Ancestors(req.Object)
+			}
+			if !containedIn(targets, c.Containers) {
+				continue
+			}
+		}
+		constraints = append(constraints, c)
+	}
+	if len(constraints) == 0 {
+		return nil
+	}
+	d.Dynamic = true
+
+	h.separation.Lock()
+	defer h.separation.Unlock()
+	performed, err := h.history.Performed(ctx, req.User, req.Object)
+	if err != nil {
+		return fmt.Errorf("failed to read separation of duty history: %w", err)
+	}
+	for _, c := range constraints {
+		for _, op := range performed {
+			if op != req.Operation && exactOperation(c.Operations, op) {
+				*d = Decision{
+					Decision:    DecisionDeny,
+					Reason:      fmt.Sprintf("constraint %q: %q already performed %s on %q", c.Name, req.User, op, req.Object),
+					Obligations: []map[string]interface{}{},
+					Revision:    d.Revision,
+					Dynamic:     true,
+					code:        reasonConstraint,
+				}
+				return nil
+			}
+		}
+	}
+	if !record {
+		return nil
+	}
+	if err := h.history.Record(ctx, req.User, req.Object, req.Operation); err != nil {
+		return fmt.Errorf("failed to record %s by %q on %q: %w", req.Operation, req.User, req.Object, err)
+	}
+	return nil
+}
+
+// exactOperation reports whether op is listed, without wildcard matching
+func exactOperation(ops []string, op string) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func containedIn(ancestors map[string]struct{}, containers []string) bool {
+	for _, c := range containers {
+		if _, ok := ancestors[c]; ok {
+			return true
+		}
+	}
+	return false
+}
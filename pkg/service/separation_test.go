@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kumarabd/gokit/logger"
+	"github.com/kumarabd/policy-machine/internal/metrics"
+	"github.com/kumarabd/policy-machine/pkg/pml"
+	"github.com/kumarabd/policy-machine/pkg/store"
+	"github.com/rs/zerolog"
+)
+
+const claims = `create PC "pc"
+create UA "clerks" in ["pc"]
+create U "alice" in ["clerks"]
+create U "bob" in ["clerks"]
+create OA "claims" in ["pc"]
+create O "claim" in ["claims"]
+create O "claim2" in ["claims"]
+create OA "memos" in ["pc"]
+create O "memo" in ["memos"]
+associate "clerks" and "claims" with ["submit", "approve", "read"]
+associate "clerks" and "memos" with ["submit", "approve"]
+create constraint "claims_sod" separates operations ["submit", "approve"] on ["claims"]`
+
+// newTestHandler serves the policy from a memory store, with dynamic
+// separation of duty enforced when history is set
+func newTestHandler(t *testing.T, policy string, history bool) *Handler {
+	t.Helper()
+	m, err := metrics.New("test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := store.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := New(&logger.Handler{Logger: zerolog.Nop()}, m, st, &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history {
+		h.SetHistory(st)
+	}
+	if _, err := pml.Execute(context.Background(), h, "test", "fixture", policy); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestSeparation(t *testing.T) {
+	type step struct {
+		user, object, operation string
+		// check, batch and explain evaluate the step with Check, BatchCheck
+		// or Explain instead of Enforce
+		check, batch, explain bool
+		// past evaluates the step against the latest revision by number
+		past bool
+		want string
+	}
+	tests := []struct {
+		name      string
+		noHistory bool
+		steps     []step
+	}{
+		{
+			name: "other operation denied",
+			steps: []step{
+				{user: "alice", object: "claim", operation: "submit", want: DecisionAllow},
+				{user: "alice", object: "claim", operation: "approve", want: DecisionDeny},
+			},
+		},
+		{
+			name: "same operation repeated",
+			steps: []step{
+				{user: "alice", object: "claim", operation: "submit", want: DecisionAllow},
+				{user: "alice", object: "claim", operation: "submit", want: DecisionAllow},
+			},
+		},
+		{
+			name: "other users unaffected",
+			steps: []step{
+				{user: "alice", object: "claim", operation: "submit", want: DecisionAllow},
+				{user: "bob", object: "claim", operation: "approve", want: DecisionAllow},
+			},
+		},
+		{
+			name: "other objects unaffected",
+			steps: []step{
+				{user: "alice", object: "claim", operation: "submit", want: DecisionAllow},
+				{user: "alice", object: "claim2", operation: "approve", want: DecisionAllow},
+			},
+		},
+		{
+			name: "outside the constraint containers",
+			steps: []step{
+				{user: "alice", object: "memo", operation: "submit", want: DecisionAllow},
+				{user: "alice", object: "memo", operation: "approve", want: DecisionAllow},
+			},
+		},
+		{
+			name: "unconstrained operation",
+			steps: []step{
+				{user: "alice", object: "claim", operation: "submit", want: DecisionAllow},
+				{user: "alice", object: "claim", operation: "read", want: DecisionAllow},
+			},
+		},
+		{
+			name: "check does not record",
+			steps: []step{
+				{user: "alice", object: "claim", operation: "submit", check: true, want: DecisionAllow},
+				{user: "alice", object: "claim", operation: "approve", want: DecisionAllow},
+			},
+		},
+		{
+			name: "batch check does not record",
+			steps: []step{
+				{user: "alice", object: "claim", operation: "submit", batch: true, want: DecisionAllow},
+				{user: "alice", object: "claim", operation: "approve", want: DecisionAllow},
+			},
+		},
+		{
+			name: "check reports the deny",
+			steps: []step{
+				{user: "alice", object: "claim", operation: "submit", want: DecisionAllow},
+				{user: "alice", object: "claim", operation: "approve", check: true, want: DecisionDeny},
+			},
+		},
+		{
+			name: "explain does not record",
+			steps: []step{
+				{user: "alice", object: "claim", operation: "submit", explain: true, want: DecisionAllow},
+				{user: "alice", object: "claim", operation: "approve", want: DecisionAllow},
+			},
+		},
+		{
+			name: "explain reports the deny",
+			steps: []step{
+				{user: "alice", object: "claim", operation: "submit", want: DecisionAllow},
+				{user: "alice", object: "claim", operation: "approve", explain: true, want: DecisionDeny},
+			},
+		},
+		{
+			name: "denied operation not recorded",
+			steps: []step{
+				{user: "alice", object: "claim", operation: "submit", want: DecisionAllow},
+				{user: "alice", object: "claim", operation: "approve", want: DecisionDeny},
+				{user: "alice", object: "claim", operation: "submit", want: DecisionAllow},
+			},
+		},
+		{
+			name: "past revisions not enforced",
+			steps: []step{
+				{user: "alice", object: "claim", operation: "submit", want: DecisionAllow},
+				{user: "alice", object: "claim", operation: "approve", past: true, want: DecisionAllow},
+			},
+		},
+		{
+			name:      "not enforced without a history",
+			noHistory: true,
+			steps: []step{
+				{user: "alice", object: "claim", operation: "submit", want: DecisionAllow},
+				{user: "alice", object: "claim", operation: "approve", want: DecisionAllow},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			h := newTestHandler(t, claims, !tt.noHistory)
+			for i, s := range tt.steps {
+				req := CheckRequest{User: s.user, Object: s.object, Operation: s.operation}
+				if s.past {
+					_, req.AsOf = h.datalayer.Graph()
+				}
+				var (
+					d   *Decision
+					err error
+				)
+				switch {
+				case s.check:
+					d, err = h.Check(ctx, req)
+				case s.batch:
+					var ds []*Decision
+					if ds, err = h.BatchCheck(ctx, []CheckRequest{req}); err == nil {
+						d = ds[0]
+					}
+				case s.explain:
+					var exp *Explanation
+					if exp, err = h.Explain(ctx, req); err == nil {
+						d = &exp.Decision
+					}
+				default:
+					d, err = h.Enforce(ctx, req)
+				}
+				if err != nil {
+					t.Fatalf("step %d: %v", i, err)
+				}
+				if d.Decision != s.want {
+					t.Fatalf("step %d: %s %s on %s = %s (%s), want %s", i, s.user, s.operation, s.object, d.Decision, d.Reason, s.want)
+				}
+			}
+		})
+	}
+}
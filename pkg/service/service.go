@@ -1,6 +1,10 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"sync"
+
 	"github.com/kumarabd/gokit/logger"
 	"github.com/kumarabd/policy-machine/internal/metrics"
 )
@@ -13,6 +17,9 @@ type Handler struct {
 	config    *Config
 	datalayer DataLayer
 	metric    *metrics.Handler
+	history   History
+	// separation serializes reading and recording the history
+	separation sync.Mutex
 }
 
 func New(l *logger.Handler, m *metrics.Handler, datalayer DataLayer, sConfig *Config) (*Handler, error) {
@@ -23,3 +30,24 @@ func New(l *logger.Handler, m *metrics.Handler, datalayer DataLayer, sConfig *Co
 		metric:    m,
 	}, nil
 }
+
+// PingStore checks the store can serve and persist revisions
+func (h *Handler) PingStore(ctx context.Context) error {
+	ok, err := h.datalayer.Ping()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("store is not available")
+	}
+	return nil
+}
+
+// PolicyLoaded checks a policy has been committed, without which every
+// decision is a deny
+func (h *Handler) PolicyLoaded(ctx context.Context) error {
+	if _, rev := h.datalayer.Graph(); rev == 0 {
+		return errors.New("no policy loaded")
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const historyFile = "history.log"
+
+// history records the operations users were allowed to perform on objects,
+// against which dynamic separation of duty is enforced. Each operation is
+// logged once per user and object, one JSON document per line.
+type history struct {
+	mu        sync.RWMutex
+	file      *os.File
+	performed map[performedKey][]string
+}
+
+type performedKey struct {
+	user, object string
+}
+
+// performed is a line of the history log
+type performed struct {
+	User      string    `json:"user"`
+	Object    string    `json:"object"`
+	Operation string    `json:"operation"`
+	Time      time.Time `json:"time"`
+}
+
+func newHistory() *history {
+	return &history{performed: map[performedKey][]string{}}
+}
+
+// openHistory loads the history log in dir, creating it when missing. Like
+// the revision log, a partially written last line is cut and its size
+// returned.
+func openHistory(dir string) (*history, int64, error) {
+	f, err := os.OpenFile(filepath.Join(dir, historyFile), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open history log: %w", err)
+	}
+	h := newHistory()
+	cut, err := readLines(f, func(line int, data []byte) error {
+		var p performed
+		if err := json.Unmarshal(data, &p); err != nil {
+			return fmt.Errorf("history log line %d: %w", line, err)
+		}
+		h.add(p.User, p.Object, p.Operation)
+		return nil
+	})
+	if err != nil {
+		f.Close()
+		return nil, 0, fmt.Errorf("failed to read history log: %w", err)
+	}
+	h.file = f
+	return h, cut, nil
+}
+
+func (h *history) add(user, object, operation string) {
+	key := performedKey{user, object}
+	if !slices.Contains(h.performed[key], operation) {
+		h.performed[key] = append(h.performed[key], operation)
+	}
+}
+
+func (h *history) get(user, object string) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return append([]string(nil), h.performed[performedKey{user, object}]...)
+}
+
+func (h *history) record(user, object, operation string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if slices.Contains(h.performed[performedKey{user, object}], operation) {
+		return nil
+	}
+	if h.file != nil {
+		data, err := json.Marshal(performed{User: user, Object: object, Operation: operation, Time: time.Now().UTC()})
+		if err != nil {
+			return fmt.Errorf("failed to encode history entry: %w", err)
+		}
+		if _, err := h.file.Write(append(data, '\n')); err != nil {
+			return fmt.Errorf("failed to write history log: %w", err)
+		}
+		if err := h.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync history log: %w", err)
+		}
+	}
+	h.add(user, object, operation)
+	return nil
+}
+
+func (h *history) close() error {
+	if h.file == nil {
+		return nil
+	}
+	return h.file.Close()
+}
+
+// Performed implements service.History
+func (p *Handler) Performed(_ context.Context, user, object string) ([]string, error) {
+	return p.history.get(user, object), nil
+}
+
+// Record implements service.History. The operation is persisted before it
+// counts, so it is kept across restarts when the store has a directory.
+func (p *Handler) Record(_ context.Context, user, object, operation string) error {
+	return p.history.record(user, object, operation)
+}
@@ -1,8 +1,288 @@
 package store
 
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+)
+
 type inmem struct {
+	mu    sync.RWMutex
+	graph *model.Graph
+
+	// base is the graph at revision compacted; revisions holds every
+	// revision committed after it
+	base      *model.Graph
+	compacted uint64
+	revisions []model.Revision
+
+	// changed is closed and replaced on every commit to wake watchers
+	changed chan struct{}
 }
 
 func newInMemStore() (*inmem, error) {
-	return &inmem{}, nil
+	return &inmem{
+		graph:   model.NewGraph(),
+		base:    model.NewGraph(),
+		changed: make(chan struct{}),
+	}, nil
+}
+
+func (i *inmem) latest() uint64 {
+	return i.compacted + uint64(len(i.revisions))
+}
+
+// oldest returns the earliest revision still available for history queries
+func (i *inmem) oldest() uint64 {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.compacted
+}
+
+// head returns the current graph and revision. The graph is never mutated
+// after it is published, so callers may read it without holding the lock.
+func (i *inmem) head() (*model.Graph, uint64) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.graph, i.latest()
+}
+
+// commit applies the changes to a copy of the current graph and publishes it
+// as the next revision. Either every change applies or none do. When base is
+// set the commit fails unless it is still the latest revision.
+func (i *inmem) commit(author, message string, tags map[string]string, changes []model.Change, base *uint64, persist func(model.Revision) error) (*model.Revision, error) {
+	if len(changes) == 0 {
+		return nil, model.ErrNoChanges
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if base != nil && *base != i.latest() {
+		return nil, &model.ConflictError{Base: *base, Latest: i.latest()}
+	}
+
+	next := i.graph.Clone()
+	for idx, ch := range changes {
+		if err := next.Apply(ch); err != nil {
+			return nil, &model.InvalidChangeError{Index: idx, Change: ch, Err: err}
+		}
+	}
+	// Static separation of duty holds for the committed graph, so a
+	// transaction may move a user between exclusive attributes
+	if err := next.CheckConstraints(); err != nil {
+		return nil, err
+	}
+
+	rev := model.Revision{
+		Number:    i.latest() + 1,
+		Author:    author,
+		Timestamp: time.Now().UTC(),
+		Message:   message,
+		Tags:      tags,
+		Changes:   changes,
+	}
+	if persist != nil {
+		if err := persist(rev); err != nil {
+			return nil, err
+		}
+	}
+
+	i.publish(rev, next)
+	return &rev, nil
+}
+
+// restore resets the store to a snapshot taken at the given revision
+func (i *inmem) restore(snapshot model.Revision) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	g := model.NewGraph()
+	for idx, ch := range snapshot.Changes {
+		if err := g.Apply(ch); err != nil {
+			return fmt.Errorf("snapshot change %d: %w", idx, err)
+		}
+	}
+	i.base = g
+	i.graph = g
+	i.compacted = snapshot.Number
+	i.revisions = nil
+	return nil
+}
+
+// replay re-applies a previously committed revision, used during recovery.
+// Revisions already covered by the snapshot are skipped.
+func (i *inmem) replay(rev model.Revision) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if rev.Number <= i.compacted {
+		return nil
+	}
+	if rev.Number != i.latest()+1 {
+		return fmt.Errorf("revision %d out of sequence, expected %d", rev.Number, i.latest()+1)
+	}
+	next := i.graph.Clone()
+	for idx, ch := range rev.Changes {
+		if err := next.Apply(ch); err != nil {
+			return fmt.Errorf("revision %d change %d: %w", rev.Number, idx, err)
+		}
+	}
+	i.publish(rev, next)
+	return nil
+}
+
+// publish records the revision and wakes watchers. Callers hold the write lock.
+func (i *inmem) publish(rev model.Revision, next *model.Graph) {
+	i.revisions = append(i.revisions, rev)
+	i.graph = next
+	close(i.changed)
+	i.changed = make(chan struct{})
+}
+
+// compact folds every revision up to and including the given one into the
+// base graph. Point-in-time queries and watches before it are no longer possible.
+func (i *inmem) compact(revision uint64, persist func(model.Revision, []model.Revision) error) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if revision <= i.compacted {
+		return nil
+	}
+	if revision > i.latest() {
+		return fmt.Errorf("revision %d does not exist, latest is %d", revision, i.latest())
+	}
+
+	base, err := i.replayFrom(revision)
+	if err != nil {
+		return err
+	}
+	remaining := append([]model.Revision(nil), i.revisions[revision-i.compacted:]...)
+	if persist != nil {
+		snapshot := model.Revision{
+			Number:    revision,
+			Timestamp: i.revisions[revision-i.compacted-1].Timestamp,
+			Changes:   model.Diff(model.NewGraph(), base),
+		}
+		if err := persist(snapshot, remaining); err != nil {
+			return err
+		}
+	}
+
+	i.base = base
+	i.compacted = revision
+	i.revisions = remaining
+	return nil
+}
+
+// graphAt rebuilds the graph as it was after the given revision
+func (i *inmem) graphAt(revision uint64) (*model.Graph, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if revision > i.latest() {
+		return nil, fmt.Errorf("revision %d does not exist, latest is %d", revision, i.latest())
+	}
+	if revision == i.latest() {
+		return i.graph, nil
+	}
+	return i.replayFrom(revision)
+}
+
+// replayFrom rebuilds the graph at the given revision from the base graph.
+// Callers hold the lock.
+func (i *inmem) replayFrom(revision uint64) (*model.Graph, error) {
+	if revision < i.compacted {
+		return nil, &model.CompactedError{Revision: revision, Compacted: i.compacted}
+	}
+	if revision == i.compacted {
+		return i.base, nil
+	}
+
+	g := i.base.Clone()
+	for _, rev := range i.revisions[:revision-i.compacted] {
+		for _, ch := range rev.Changes {
+			if err := g.Apply(ch); err != nil {
+				return nil, fmt.Errorf("replaying revision %d: %w", rev.Number, err)
+			}
+		}
+	}
+	return g, nil
+}
+
+// history returns the revisions in the range (from, to]
+func (i *inmem) history(from, to uint64) ([]model.Revision, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.historyLocked(from, to)
+}
+
+func (i *inmem) historyLocked(from, to uint64) ([]model.Revision, error) {
+	latest := i.latest()
+	if to == 0 || to > latest {
+		to = latest
+	}
+	if from > to {
+		return nil, fmt.Errorf("invalid revision range %d..%d", from, to)
+	}
+	if from < i.compacted {
+		return nil, &model.CompactedError{Revision: from, Compacted: i.compacted}
+	}
+	out := make([]model.Revision, to-from)
+	copy(out, i.revisions[from-i.compacted:to-i.compacted])
+	return out, nil
+}
+
+// watch streams every revision after from, in order, until the context is done
+func (i *inmem) watch(ctx context.Context, from uint64) (<-chan model.Event, error) {
+	i.mu.RLock()
+	if from > i.latest() {
+		i.mu.RUnlock()
+		return nil, fmt.Errorf("revision %d does not exist, latest is %d", from, i.latest())
+	}
+	if from < i.compacted {
+		i.mu.RUnlock()
+		return nil, &model.CompactedError{Revision: from, Compacted: i.compacted}
+	}
+	i.mu.RUnlock()
+
+	ch := make(chan model.Event)
+	go func() {
+		defer close(ch)
+		for {
+			i.mu.RLock()
+			pending, err := i.historyLocked(from, 0)
+			changed := i.changed
+			compacted := i.compacted
+			i.mu.RUnlock()
+			// The watcher fell behind a compaction and can't be resumed
+			if err != nil {
+				select {
+				case ch <- model.Event{Type: model.EventResync, Compacted: compacted}:
+				case <-ctx.Done():
+				}
+				return
+			}
+
+			for idx := range pending {
+				rev := pending[idx]
+				select {
+				case ch <- model.Event{Type: model.EventChange, Revision: &rev}:
+					from = rev.Number
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
 }
@@ -1,20 +1,203 @@
 package store
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+)
+
+type Config struct {
+	// Dir holds the revision log and the separation of duty history. The
+	// store is memory only when empty.
+	Dir string `json:"dir,omitempty" yaml:"dir,omitempty"`
+	// Retain is the number of revisions kept for history, point-in-time
+	// queries and watch resumption. Zero keeps every revision.
+	Retain int `json:"retain,omitempty" yaml:"retain,omitempty"`
+}
+
+// Validate reports every invalid setting
+func (c *Config) Validate() error {
+	if c.Retain < 0 {
+		return fmt.Errorf("retain: must not be negative")
+	}
+	return nil
+}
+
+// lockFile is locked by the process that has the store directory open
+const lockFile = "lock"
+
+// ErrLocked is returned by New when another process has the store
+// directory open
+var ErrLocked = errors.New("store directory is in use by another process")
+
 type Handler struct {
-	inmem *inmem
+	inmem   *inmem
+	wal     *wal
+	history *history
+	lock    *os.File
+	retain  uint64
+	repairs []string
 }
 
-func New() (*Handler, error) {
+func New(config *Config) (*Handler, error) {
 	i, err := newInMemStore()
 	if err != nil {
 		return nil, err
 	}
 
-	return &Handler{
-		inmem: i,
-	}, nil
+	h := &Handler{
+		inmem:   i,
+		history: newHistory(),
+	}
+	if config == nil {
+		return h, nil
+	}
+	if config.Retain > 0 {
+		h.retain = uint64(config.Retain)
+	}
+	if config.Dir != "" {
+		if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create store directory: %w", err)
+		}
+		if h.lock, err = lockDir(config.Dir); err != nil {
+			return nil, err
+		}
+		w, err := openWAL(config.Dir)
+		if err != nil {
+			h.lock.Close()
+			return nil, err
+		}
+		if err := h.recover(w); err != nil {
+			w.close()
+			h.lock.Close()
+			return nil, err
+		}
+		var cut int64
+		if h.history, cut, err = openHistory(config.Dir); err != nil {
+			w.close()
+			h.lock.Close()
+			return nil, err
+		}
+		if cut > 0 {
+			h.repairs = append(h.repairs, fmt.Sprintf("cut a partially written line of %d bytes from the end of %s", cut, historyFile))
+		}
+		h.wal = w
+	}
+	return h, nil
 }
 
+// Ping reports whether the store can serve and persist revisions. The
+// revision log was recovered when the store was opened, so this checks it
+// is still open and its directory reachable. Compaction swaps the log under
+// the write lock of the revisions, so it is read under the read lock.
 func (p *Handler) Ping() (bool, error) {
+	if p.wal == nil {
+		return true, nil
+	}
+	p.inmem.mu.RLock()
+	_, err := p.wal.file.Stat()
+	p.inmem.mu.RUnlock()
+	if err != nil {
+		return false, fmt.Errorf("revision log unavailable: %w", err)
+	}
+	if _, err := os.Stat(p.wal.dir); err != nil {
+		return false, fmt.Errorf("store directory unavailable: %w", err)
+	}
 	return true, nil
 }
+
+// Graph returns the current graph and its revision. The graph must not be modified.
+func (p *Handler) Graph() (*model.Graph, uint64) {
+	return p.inmem.head()
+}
+
+// GraphAt returns the graph as it was after the given revision
+func (p *Handler) GraphAt(revision uint64) (*model.Graph, error) {
+	return p.inmem.graphAt(revision)
+}
+
+// Commit atomically applies the changes as a new revision
+func (p *Handler) Commit(author, message string, changes []model.Change) (*model.Revision, error) {
+	return p.commit(author, message, nil, changes, nil)
+}
+
+// CommitAt is Commit guarded by optimistic concurrency: it fails with a
+// ConflictError unless base is still the latest revision. The tags are
+// recorded on the revision.
+func (p *Handler) CommitAt(base uint64, author, message string, tags map[string]string, changes []model.Change) (*model.Revision, error) {
+	return p.commit(author, message, tags, changes, &base)
+}
+
+func (p *Handler) commit(author, message string, tags map[string]string, changes []model.Change, base *uint64) (*model.Revision, error) {
+	var persist func(model.Revision) error
+	if p.wal != nil {
+		persist = p.wal.append
+	}
+	rev, err := p.inmem.commit(author, message, tags, changes, base, persist)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compact in batches so the log is not rewritten on every commit
+	if p.retain > 0 && rev.Number-p.inmem.oldest() > 2*p.retain {
+		if err := p.Compact(rev.Number - p.retain); err != nil {
+			return rev, fmt.Errorf("revision %d committed but compaction failed: %w", rev.Number, err)
+		}
+	}
+	return rev, nil
+}
+
+// Compact discards history up to and including the given revision
+func (p *Handler) Compact(revision uint64) error {
+	var persist func(model.Revision, []model.Revision) error
+	if p.wal != nil {
+		persist = p.wal.compact
+	}
+	return p.inmem.compact(revision, persist)
+}
+
+// Watch streams change events for every revision after from. A resync event
+// is sent when from, or the watcher's position, has been compacted away.
+func (p *Handler) Watch(ctx context.Context, from uint64) (<-chan model.Event, error) {
+	return p.inmem.watch(ctx, from)
+}
+
+// Revisions returns the committed revisions in the range (from, to]. A zero
+// upper bound means the latest revision.
+func (p *Handler) Revisions(from, to uint64) ([]model.Revision, error) {
+	return p.inmem.history(from, to)
+}
+
+func (p *Handler) recover(w *wal) error {
+	snapshot, err := w.snapshot()
+	if err != nil {
+		return err
+	}
+	if snapshot != nil {
+		if err := p.inmem.restore(*snapshot); err != nil {
+			return err
+		}
+	}
+	cut, err := w.replay(p.inmem.replay)
+	if cut > 0 {
+		p.repairs = append(p.repairs, fmt.Sprintf("cut a partially written revision of %d bytes from the end of %s", cut, walFile))
+	}
+	return err
+}
+
+// Repairs describes the partially written lines, left by a crash, that were
+// cut from the end of the logs when the store was opened
+func (p *Handler) Repairs() []string {
+	return p.repairs
+}
+
+// Close releases the revision and history logs and the directory lock
+func (p *Handler) Close() error {
+	if p.wal == nil {
+		return nil
+	}
+	return errors.Join(p.wal.close(), p.history.close(), p.lock.Close())
+}
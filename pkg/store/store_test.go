@@ -0,0 +1,401 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+)
+
+func createNode(name string, typ model.NodeType) model.Change {
+	return model.Change{Op: model.OpCreateNode, Node: &model.Node{Name: name, Type: typ}}
+}
+
+func assign(child, parent string) model.Change {
+	return model.Change{Op: model.OpAssign, Assignment: &model.Assignment{Child: child, Parent: parent}}
+}
+
+// commitN commits n revisions, each creating the policy class pc<n>
+func commitN(t *testing.T, st *Handler, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		if _, err := st.Commit("test", "", []model.Change{createNode(fmt.Sprintf("pc%d", i), model.PolicyClass)}); err != nil {
+			t.Fatalf("commit %d: %v", i, err)
+		}
+	}
+}
+
+func open(t *testing.T, config *Config) *Handler {
+	t.Helper()
+	st, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		name    string
+		retain  int
+		commits int
+		// crash restores the revision log as it was before the last
+		// compaction, as if the process stopped before rewriting it
+		crash      bool
+		wantOldest uint64
+	}{
+		{name: "empty", commits: 0},
+		{name: "replay without compaction", commits: 5},
+		{name: "compacted keeping one", retain: 1, commits: 5, wantOldest: 4},
+		{name: "compacted keeping two", retain: 2, commits: 5, wantOldest: 3},
+		{name: "compacted once", retain: 1, commits: 3, wantOldest: 2},
+		{name: "log not rewritten after snapshot", retain: 1, commits: 3, crash: true, wantOldest: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			config := &Config{Dir: dir, Retain: tt.retain}
+			st, err := New(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.crash {
+				commitN(t, st, tt.commits-1)
+				before, err := os.ReadFile(filepath.Join(dir, walFile))
+				if err != nil {
+					t.Fatal(err)
+				}
+				rev, err := st.Commit("test", "", []model.Change{createNode(fmt.Sprintf("pc%d", tt.commits), model.PolicyClass)})
+				if err != nil {
+					t.Fatal(err)
+				}
+				line, err := json.Marshal(rev)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, walFile), append(append(before, line...), '\n'), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				commitN(t, st, tt.commits)
+			}
+			want, wantRev := st.Graph()
+			if err := st.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			st = open(t, config)
+			got, rev := st.Graph()
+			if rev != wantRev || rev != uint64(tt.commits) {
+				t.Fatalf("recovered revision %d, want %d", rev, tt.commits)
+			}
+			if d := model.Diff(want, got); len(d) > 0 {
+				t.Fatalf("recovered graph differs: %+v", d)
+			}
+			if oldest := st.inmem.oldest(); oldest != tt.wantOldest {
+				t.Fatalf("oldest revision %d, want %d", oldest, tt.wantOldest)
+			}
+			revs, err := st.Revisions(tt.wantOldest, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(revs) != tt.commits-int(tt.wantOldest) {
+				t.Fatalf("got %d revisions after %d, want %d", len(revs), tt.wantOldest, tt.commits-int(tt.wantOldest))
+			}
+			if tt.wantOldest > 0 {
+				var compacted *model.CompactedError
+				if _, err := st.GraphAt(tt.wantOldest - 1); !errors.As(err, &compacted) {
+					t.Fatalf("GraphAt(%d) = %v, want CompactedError", tt.wantOldest-1, err)
+				}
+			}
+
+			// The recovered store keeps committing after the replayed revisions
+			next, err := st.Commit("test", "", []model.Change{createNode("next", model.PolicyClass)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if next.Number != uint64(tt.commits)+1 {
+				t.Fatalf("next revision %d, want %d", next.Number, tt.commits+1)
+			}
+		})
+	}
+}
+
+func TestRecoverCorruptLog(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+	}{
+		{name: "truncated line", log: `{"revision":1,"author":"test","changes":[{"op":"create_node"`},
+		{name: "change that does not apply", log: `{"revision":1,"author":"test","changes":[{"op":"assign","assignment":{"child":"a","parent":"b"}}]}`},
+		{name: "revision out of order", log: `{"revision":2,"author":"test","changes":[{"op":"create_node","node":{"name":"pc","type":"PC"}}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, walFile), []byte(tt.log+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if st, err := New(&Config{Dir: dir}); err == nil {
+				st.Close()
+				t.Fatal("opened a store with a corrupt revision log")
+			}
+		})
+	}
+}
+
+func TestRecoverPartialLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		partial string
+	}{
+		{name: "revision cut mid-write", file: walFile, partial: `{"revision":3,"author":"test","changes":[{"op":"create_no`},
+		{name: "complete revision without its newline", file: walFile, partial: `{"revision":3,"author":"test","changes":[{"op":"create_node","node":{"name":"pc3","type":"PC"}}]}`},
+		{name: "history entry cut mid-write", file: historyFile, partial: `{"user":"bob","object":"inv","oper`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			st, err := New(&Config{Dir: dir})
+			if err != nil {
+				t.Fatal(err)
+			}
+			commitN(t, st, 2)
+			if err := st.Record(context.Background(), "alice", "inv", "submit"); err != nil {
+				t.Fatal(err)
+			}
+			st.Close()
+
+			path := filepath.Join(dir, tt.file)
+			before, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, append(before, tt.partial...), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			st = open(t, &Config{Dir: dir})
+			if repairs := st.Repairs(); len(repairs) != 1 {
+				t.Fatalf("Repairs() = %v, want one", repairs)
+			}
+			if _, rev := st.Graph(); rev != 2 {
+				t.Fatalf("recovered revision %d, want 2", rev)
+			}
+			if ops, _ := st.Performed(context.Background(), "alice", "inv"); !reflect.DeepEqual(ops, []string{"submit"}) {
+				t.Fatalf("recovered history %v, want [submit]", ops)
+			}
+			if after, _ := os.ReadFile(path); string(after) != string(before) {
+				t.Fatalf("log not truncated to its last complete line:\n%s", after)
+			}
+
+			// The repaired log takes new revisions and opens cleanly
+			if _, err := st.Commit("test", "", []model.Change{createNode("pc3", model.PolicyClass)}); err != nil {
+				t.Fatal(err)
+			}
+			st.Close()
+			st = open(t, &Config{Dir: dir})
+			if _, rev := st.Graph(); rev != 3 || len(st.Repairs()) != 0 {
+				t.Fatalf("reopened at revision %d with repairs %v, want 3 and none", rev, st.Repairs())
+			}
+		})
+	}
+}
+
+func TestCommitAt(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    uint64
+		changes []model.Change
+		// check inspects the error of the commit, which must be nil when unset
+		check func(error) bool
+	}{
+		{
+			name:    "latest base",
+			base:    2,
+			changes: []model.Change{createNode("ua", model.UserAttribute), assign("ua", "pc1")},
+		},
+		{
+			name:    "stale base",
+			base:    1,
+			changes: []model.Change{createNode("ua", model.UserAttribute), assign("ua", "pc1")},
+			check: func(err error) bool {
+				var conflict *model.ConflictError
+				return errors.As(err, &conflict) && conflict.Base == 1 && conflict.Latest == 2
+			},
+		},
+		{
+			name:    "base ahead of the store",
+			base:    3,
+			changes: []model.Change{createNode("ua", model.UserAttribute), assign("ua", "pc1")},
+			check: func(err error) bool {
+				var conflict *model.ConflictError
+				return errors.As(err, &conflict) && conflict.Base == 3 && conflict.Latest == 2
+			},
+		},
+		{
+			name:  "no changes",
+			base:  2,
+			check: func(err error) bool { return errors.Is(err, model.ErrNoChanges) },
+		},
+		{
+			name:    "invalid change",
+			base:    2,
+			changes: []model.Change{createNode("ua", model.UserAttribute), assign("ua", "missing")},
+			check: func(err error) bool {
+				var invalid *model.InvalidChangeError
+				return errors.As(err, &invalid) && invalid.Index == 1
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := open(t, &Config{Dir: t.TempDir()})
+			commitN(t, st, 2)
+			tags := map[string]string{"source_hash": "abc"}
+
+			rev, err := st.CommitAt(tt.base, "test", "", tags, tt.changes)
+			if tt.check != nil {
+				if !tt.check(err) {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if _, latest := st.Graph(); latest != 2 {
+					t.Fatalf("failed commit published revision %d", latest)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rev.Number != 3 || !reflect.DeepEqual(rev.Tags, tags) {
+				t.Fatalf("committed %+v", rev)
+			}
+			revs, err := st.Revisions(2, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(revs) != 1 || !reflect.DeepEqual(revs[0].Tags, tags) {
+				t.Fatalf("history has %+v", revs)
+			}
+		})
+	}
+}
+
+func TestHistory(t *testing.T) {
+	type record struct{ user, object, operation string }
+	tests := []struct {
+		name    string
+		records []record
+		user    string
+		object  string
+		want    []string
+	}{
+		{name: "none", user: "alice", object: "rec", want: nil},
+		{
+			name:    "in order",
+			records: []record{{"alice", "rec", "submit"}, {"alice", "rec", "approve"}},
+			user:    "alice",
+			object:  "rec",
+			want:    []string{"submit", "approve"},
+		},
+		{
+			name:    "deduplicated",
+			records: []record{{"alice", "rec", "submit"}, {"alice", "rec", "submit"}},
+			user:    "alice",
+			object:  "rec",
+			want:    []string{"submit"},
+		},
+		{
+			name:    "per user and object",
+			records: []record{{"alice", "rec", "submit"}, {"bob", "rec", "approve"}, {"alice", "other", "approve"}},
+			user:    "alice",
+			object:  "rec",
+			want:    []string{"submit"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			config := &Config{Dir: t.TempDir()}
+			st, err := New(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range tt.records {
+				if err := st.Record(ctx, r.user, r.object, r.operation); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := st.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// The history is read back from the log after a restart
+			st = open(t, config)
+			got, err := st.Performed(ctx, tt.user, tt.object)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+				t.Fatalf("Performed(%s, %s) = %v, want %v", tt.user, tt.object, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLock(t *testing.T) {
+	dir := t.TempDir()
+	st, err := New(&Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other, err := New(&Config{Dir: dir}); !errors.Is(err, ErrLocked) {
+		if other != nil {
+			other.Close()
+		}
+		t.Fatalf("second New() error = %v, want %v", err, ErrLocked)
+	}
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Closing releases the directory
+	open(t, &Config{Dir: dir})
+}
+
+// TestPingDuringCompaction pings while compaction swaps the revision log;
+// run with -race to check they do not share the file unlocked
+func TestPingDuringCompaction(t *testing.T) {
+	st := open(t, &Config{Dir: t.TempDir()})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 20; i++ {
+			if _, err := st.Commit("test", "", []model.Change{createNode(fmt.Sprintf("pc%d", i), model.PolicyClass)}); err != nil {
+				t.Errorf("commit %d: %v", i, err)
+				return
+			}
+			if err := st.Compact(uint64(i)); err != nil {
+				t.Errorf("compact %d: %v", i, err)
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if ok, err := st.Ping(); !ok || err != nil {
+			t.Fatalf("Ping() = %v, %v", ok, err)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/kumarabd/policy-machine/pkg/model"
+)
+
+const (
+	walFile      = "revisions.log"
+	snapshotFile = "snapshot.json"
+)
+
+// wal is an append-only log of committed revisions, one JSON document per
+// line, plus an optional snapshot of the graph at the last compaction
+type wal struct {
+	dir  string
+	file *os.File
+}
+
+func openWAL(dir string) (*wal, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, walFile), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open revision log: %w", err)
+	}
+	return &wal{dir: dir, file: f}, nil
+}
+
+// snapshot returns the last compaction snapshot, if any
+func (w *wal) snapshot() (*model.Revision, error) {
+	data, err := os.ReadFile(filepath.Join(w.dir, snapshotFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var snap model.Revision
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	return &snap, nil
+}
+
+// compact writes a new snapshot and rewrites the log with the remaining
+// revisions. Both files are replaced atomically by rename; the snapshot goes
+// first so a crash in between only leaves already-covered revisions in the log.
+func (w *wal) compact(snapshot model.Revision, remaining []model.Revision) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(w.dir, snapshotFile), data); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	var buf []byte
+	for _, rev := range remaining {
+		line, err := json.Marshal(rev)
+		if err != nil {
+			return fmt.Errorf("failed to encode revision: %w", err)
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	path := filepath.Join(w.dir, walFile)
+	if err := writeFileAtomic(path, buf); err != nil {
+		return fmt.Errorf("failed to rewrite revision log: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen revision log: %w", err)
+	}
+	w.file.Close()
+	w.file = f
+	return nil
+}
+
+// replay feeds every logged revision to fn in order. A partially written
+// last line, left by a crash during append, was never acknowledged; it is
+// cut from the log and its size returned.
+func (w *wal) replay(fn func(model.Revision) error) (int64, error) {
+	return readLines(w.file, func(line int, data []byte) error {
+		var rev model.Revision
+		if err := json.Unmarshal(data, &rev); err != nil {
+			return fmt.Errorf("revision log line %d: %w", line, err)
+		}
+		return fn(rev)
+	})
+}
+
+// readLines feeds every complete, non-empty line of f to fn and truncates
+// f after the last one, returning the number of bytes cut
+func readLines(f *os.File, fn func(line int, data []byte) error) (int64, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	r := bufio.NewReader(f)
+	var offset int64
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(data) == 0 {
+				return 0, nil
+			}
+			if err := f.Truncate(offset); err != nil {
+				return 0, fmt.Errorf("failed to truncate partial line: %w", err)
+			}
+			return int64(len(data)), f.Sync()
+		}
+		if err != nil {
+			return 0, err
+		}
+		offset += int64(len(data))
+		if data = bytes.TrimSuffix(data, []byte("\n")); len(data) == 0 {
+			continue
+		}
+		if err := fn(line, data); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (w *wal) append(rev model.Revision) error {
+	data, err := json.Marshal(rev)
+	if err != nil {
+		return fmt.Errorf("failed to encode revision: %w", err)
+	}
+	if _, err := w.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write revision log: %w", err)
+	}
+	return w.file.Sync()
+}
+
+func (w *wal) close() error {
+	return w.file.Close()
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}